@@ -0,0 +1,83 @@
+package abiregistry
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+var ErrUnknownMethod = errors.New("abiregistry: unknown method")
+
+// Call is transaction calldata decoded with a registered ABI.
+type Call struct {
+	// ABI is the name of the ABI that declares the method.
+	ABI       string
+	Method    string
+	Signature string
+	Selector  []byte
+
+	// Args holds the arguments in declaration order. Unnamed arguments are
+	// called arg0, arg1, ... by position.
+	Args []Arg
+
+	// Value is the wei sent with the call.
+	Value *big.Int
+}
+
+// Arg is a decoded method argument. Value has the Go type the abi package
+// unpacks Type into.
+type Arg struct {
+	Name  string
+	Type  string
+	Value interface{}
+}
+
+// DecodeCall decodes the calldata of tx. It only looks at the transaction, so
+// calls that reverted decode the same as successful ones.
+func (r *Registry) DecodeCall(tx *types.Transaction) (*Call, error) {
+	if tx.To() == nil {
+		return nil, fmt.Errorf("%w: contract creation", ErrUnknownMethod)
+	}
+	call, err := r.DecodeCalldata(tx.To(), tx.Data())
+	if err != nil {
+		return nil, err
+	}
+	call.Value = tx.Value()
+	return call, nil
+}
+
+// DecodeCalldata decodes data sent to a contract. The ABI bound to to is
+// tried first, then every registered ABI.
+func (r *Registry) DecodeCalldata(to *common.Address, data []byte) (*Call, error) {
+	if len(data) < 4 {
+		return nil, fmt.Errorf("%w: no method selector", ErrUnknownMethod)
+	}
+	m, name, err := r.MethodByID(to, data[:4])
+	if err != nil {
+		return nil, fmt.Errorf("%w %x", ErrUnknownMethod, data[:4])
+	}
+
+	values, err := m.Inputs.Unpack(data[4:])
+	if err != nil {
+		return nil, fmt.Errorf("decode %v: %w", m.Name, err)
+	}
+	args := make([]Arg, len(m.Inputs))
+	for i, in := range m.Inputs {
+		args[i] = Arg{Name: in.Name, Type: in.Type.String(), Value: values[i]}
+		if args[i].Name == "" {
+			args[i].Name = fmt.Sprintf("arg%d", i)
+		}
+	}
+
+	return &Call{
+		ABI:       name,
+		Method:    m.Name,
+		Signature: m.Sig,
+		Selector:  common.CopyBytes(data[:4]),
+		Args:      args,
+		Value:     new(big.Int),
+	}, nil
+}
@@ -0,0 +1,160 @@
+package abiregistry
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"reflect"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// Event is a log decoded with a registered ABI.
+type Event struct {
+	// ABI is the name of the ABI that declares the event.
+	ABI       string
+	Name      string
+	Signature string
+
+	// Args holds every argument by name. Indexed arguments of dynamic types
+	// hold the keccak256 hash stored in the topic. Unnamed arguments are
+	// called arg0, arg1, ... by position.
+	Args map[string]interface{}
+	Raw  types.Log
+}
+
+// DecodeLog decodes l with the ABI bound to its address. Logs of unbound
+// contracts are decoded with the first registered ABI that declares an event
+// with the same signature and number of indexed arguments.
+func (r *Registry) DecodeLog(l types.Log) (*Event, error) {
+	if len(l.Topics) == 0 {
+		return nil, fmt.Errorf("%w: anonymous log", ErrUnknownEvent)
+	}
+
+	e, ev := r.lookupEvent(l)
+	if ev == nil {
+		return nil, fmt.Errorf("%w %v", ErrUnknownEvent, l.Topics[0].Hex())
+	}
+
+	args := make(map[string]interface{}, len(ev.Inputs))
+	var indexed, data abi.Arguments
+	for i, in := range ev.Inputs {
+		if in.Name == "" {
+			in.Name = fmt.Sprintf("arg%d", i)
+		}
+		if in.Indexed {
+			indexed = append(indexed, in)
+		} else {
+			data = append(data, in)
+		}
+	}
+
+	if err := abi.ParseTopicsIntoMap(args, indexed, l.Topics[1:]); err != nil {
+		return nil, fmt.Errorf("decode %v topics: %w", ev.Name, err)
+	}
+	if len(data) > 0 {
+		values, err := data.UnpackValues(l.Data)
+		if err != nil {
+			return nil, fmt.Errorf("decode %v data: %w", ev.Name, err)
+		}
+		for i, in := range data {
+			args[in.Name] = values[i]
+		}
+	}
+
+	return &Event{
+		ABI:       e.name,
+		Name:      ev.Name,
+		Signature: ev.Sig,
+		Args:      args,
+		Raw:       l,
+	}, nil
+}
+
+func (r *Registry) lookupEvent(l types.Log) (*entry, *abi.Event) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	matches := func(ev *abi.Event) bool {
+		n := 0
+		for _, in := range ev.Inputs {
+			if in.Indexed {
+				n++
+			}
+		}
+		return n == len(l.Topics)-1
+	}
+
+	if e, ok := r.contracts[l.Address]; ok {
+		if ev, ok := e.events[l.Topics[0]]; ok && matches(ev) {
+			return e, ev
+		}
+		return nil, nil
+	}
+	for _, e := range r.abis {
+		if ev, ok := e.events[l.Topics[0]]; ok && matches(ev) {
+			return e, ev
+		}
+	}
+	return nil, nil
+}
+
+// ArgsJSON encodes the arguments of the event as a JSON object. Integers are
+// encoded as decimal strings and byte arrays as hex so no precision is lost.
+func (e *Event) ArgsJSON() ([]byte, error) {
+	return json.Marshal(JSONValue(e.Args))
+}
+
+// JSONValue converts a value unpacked by the abi package into one that
+// encodes to JSON without losing precision.
+func JSONValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case *big.Int:
+		return v.String()
+	case common.Address:
+		return v.Hex()
+	case common.Hash:
+		return v.Hex()
+	case []byte:
+		return hexutil.Encode(v)
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, x := range v {
+			out[k] = JSONValue(x)
+		}
+		return out
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return fmt.Sprint(rv.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return fmt.Sprint(rv.Uint())
+	case reflect.Array:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			b := make([]byte, rv.Len())
+			reflect.Copy(reflect.ValueOf(b), rv)
+			return hexutil.Encode(b)
+		}
+		fallthrough
+	case reflect.Slice:
+		out := make([]interface{}, rv.Len())
+		for i := range out {
+			out[i] = JSONValue(rv.Index(i).Interface())
+		}
+		return out
+	case reflect.Struct:
+		out := make(map[string]interface{}, rv.NumField())
+		for i := 0; i < rv.NumField(); i++ {
+			if f := rv.Type().Field(i); f.PkgPath == "" {
+				out[f.Name] = JSONValue(rv.Field(i).Interface())
+			}
+		}
+		return out
+	}
+	return v
+}
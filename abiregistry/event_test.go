@@ -0,0 +1,153 @@
+package abiregistry
+
+import (
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// namesABI declares an event with dynamic indexed arguments and an unnamed
+// one.
+const namesABI = `[{"type":"event","name":"Named","anonymous":false,"inputs":[
+	{"name":"name","type":"string","indexed":true},
+	{"name":"tags","type":"uint256[]","indexed":true},
+	{"name":"","type":"bytes","indexed":false},
+	{"name":"note","type":"string","indexed":false}]}]`
+
+var (
+	market  = common.HexToAddress("0xaa")
+	nftAddr = common.HexToAddress("0xbb")
+	other   = common.HexToAddress("0xcc")
+	alice   = common.HexToAddress("0xa11ce")
+	bob     = common.HexToAddress("0xb0b")
+)
+
+// eventLog returns a log of the event called name of the ABI called abiName,
+// emitted by addr with args, the indexed ones first. Indexed arrays are
+// given as their hash.
+func eventLog(t *testing.T, r *Registry, abiName, name string, addr common.Address, args ...interface{}) types.Log {
+	t.Helper()
+	a, ok := r.ABI(abiName)
+	if !ok {
+		t.Fatalf("no ABI %s", abiName)
+	}
+	ev := a.Events[name]
+	var indexed [][]interface{}
+	n := 0
+	for _, in := range ev.Inputs {
+		if in.Indexed {
+			indexed = append(indexed, []interface{}{args[n]})
+			n++
+		}
+	}
+	topics, err := abi.MakeTopics(indexed...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ev.Inputs.NonIndexed().Pack(args[n:]...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := types.Log{Address: addr, Topics: []common.Hash{ev.ID}, Data: data}
+	for _, tp := range topics {
+		l.Topics = append(l.Topics, tp[0])
+	}
+	return l
+}
+
+func TestDecodeLog(t *testing.T) {
+	r, err := Default()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.RegisterJSON("Names", strings.NewReader(namesABI)); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Bind(market, MarketABI); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Bind(nftAddr, NftABI); err != nil {
+		t.Fatal(err)
+	}
+
+	tags := crypto.Keccak256Hash(common.LeftPadBytes([]byte{1}, 32), common.LeftPadBytes([]byte{2}, 32))
+	transfer := eventLog(t, r, NftABI, "Transfer", nftAddr, alice, bob, big.NewInt(42))
+	// An ERC-20 Transfer has the same signature with the amount in the data.
+	erc20 := types.Log{Address: other, Topics: transfer.Topics[:3], Data: common.LeftPadBytes(big.NewInt(42).Bytes(), 32)}
+	unbound := transfer
+	unbound.Address = other
+	onMarket := transfer
+	onMarket.Address = market
+	truncated := eventLog(t, r, MarketABI, "MarketItemCreated", market, big.NewInt(7), nftAddr, big.NewInt(42), alice, common.Address{}, big.NewInt(25), false)
+	truncated.Data = truncated.Data[:64]
+
+	tests := []struct {
+		name string
+		log  types.Log
+		abi  string
+		want string // the arguments as JSON
+		err  error
+	}{
+		{
+			name: "indexed and data arguments",
+			log:  eventLog(t, r, MarketABI, "MarketItemCreated", market, big.NewInt(7), nftAddr, big.NewInt(42), alice, common.Address{}, big.NewInt(25), false),
+			abi:  MarketABI,
+			want: `{"itemId":"7","nftContract":"` + nftAddr.Hex() + `","owner":"0x0000000000000000000000000000000000000000","price":"25","seller":"` + alice.Hex() + `","sold":false,"tokenId":"42"}`,
+		},
+		{
+			name: "all indexed",
+			log:  transfer,
+			abi:  NftABI,
+			want: `{"from":"` + alice.Hex() + `","to":"` + bob.Hex() + `","tokenId":"42"}`,
+		},
+		{
+			name: "unbound contract",
+			log:  unbound,
+			abi:  NftABI,
+			want: `{"from":"` + alice.Hex() + `","to":"` + bob.Hex() + `","tokenId":"42"}`,
+		},
+		{
+			name: "indexed and bool data",
+			log:  eventLog(t, r, NftABI, "ApprovalForAll", nftAddr, alice, market, true),
+			abi:  NftABI,
+			want: `{"approved":true,"operator":"` + market.Hex() + `","owner":"` + alice.Hex() + `"}`,
+		},
+		{
+			name: "dynamic indexed arguments are hashes",
+			log:  eventLog(t, r, "Names", "Named", other, "alice", tags, []byte{1, 2}, "hello"),
+			abi:  "Names",
+			want: `{"arg2":"0x0102","name":"` + crypto.Keccak256Hash([]byte("alice")).Hex() + `","note":"hello","tags":"` + tags.Hex() + `"}`,
+		},
+		{name: "fewer indexed arguments", log: erc20, err: ErrUnknownEvent},
+		{name: "fewer indexed arguments on a bound contract", log: types.Log{Address: nftAddr, Topics: erc20.Topics, Data: erc20.Data}, err: ErrUnknownEvent},
+		{name: "event of another ABI than the bound one", log: onMarket, err: ErrUnknownEvent},
+		{name: "unknown topic", log: types.Log{Address: other, Topics: []common.Hash{crypto.Keccak256Hash([]byte("Unknown()"))}}, err: ErrUnknownEvent},
+		{name: "anonymous", log: types.Log{Address: nftAddr, Data: transfer.Data}, err: ErrUnknownEvent},
+		{name: "truncated data", log: truncated},
+	}
+	for _, tt := range tests {
+		ev, err := r.DecodeLog(tt.log)
+		if tt.want == "" {
+			if err == nil || tt.err != nil && !errors.Is(err, tt.err) || tt.err == nil && errors.Is(err, ErrUnknownEvent) {
+				t.Errorf("%s: %+v, %v, want error %v", tt.name, ev, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if ev.ABI != tt.abi || ev.Raw.Address != tt.log.Address {
+			t.Errorf("%s: decoded with %s from %s, want %s", tt.name, ev.ABI, ev.Raw.Address.Hex(), tt.abi)
+		}
+		if got, err := ev.ArgsJSON(); err != nil || string(got) != tt.want {
+			t.Errorf("%s: args %s (%v)\nwant %s", tt.name, got, err, tt.want)
+		}
+	}
+}
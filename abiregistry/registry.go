@@ -0,0 +1,211 @@
+// Package abiregistry keeps the ABIs of indexed contracts and decodes their
+// logs and calldata without generated bindings.
+package abiregistry
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+
+	"blockchain.com/indexer/contracts/marketplace"
+	"blockchain.com/indexer/contracts/nft"
+)
+
+// Names of the ABIs of the generated bindings.
+const (
+	MarketABI = "NFTMarket"
+	NftABI    = "NFT"
+)
+
+var (
+	ErrUnknownABI   = errors.New("abiregistry: unknown ABI")
+	ErrUnknownEvent = errors.New("abiregistry: unknown event")
+)
+
+// entry is a registered ABI with its events indexed by signature.
+type entry struct {
+	name   string
+	abi    *abi.ABI
+	events map[common.Hash]*abi.Event
+}
+
+// Registry maps contract addresses to ABIs. It is safe for concurrent use.
+type Registry struct {
+	mu        sync.RWMutex
+	abis      map[string]*entry
+	contracts map[common.Address]*entry
+}
+
+func New() *Registry {
+	return &Registry{
+		abis:      make(map[string]*entry),
+		contracts: make(map[common.Address]*entry),
+	}
+}
+
+// Default returns a registry holding the ABIs of the generated marketplace
+// and NFT bindings.
+func Default() (*Registry, error) {
+	r := New()
+	if err := r.RegisterJSON(MarketABI, strings.NewReader(marketplace.MainMetaData.ABI)); err != nil {
+		return nil, err
+	}
+	if err := r.RegisterJSON(NftABI, strings.NewReader(nft.MainMetaData.ABI)); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Register adds or replaces the ABI called name.
+func (r *Registry) Register(name string, a *abi.ABI) {
+	e := &entry{name: name, abi: a, events: make(map[common.Hash]*abi.Event)}
+	for _, ev := range a.Events {
+		ev := ev
+		if !ev.Anonymous {
+			e.events[ev.ID] = &ev
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.abis[name] = e
+	for addr, old := range r.contracts {
+		if old.name == name {
+			r.contracts[addr] = e
+		}
+	}
+}
+
+// RegisterJSON parses and registers an ABI. rd holds either the ABI array
+// itself or a compiler artifact with an "abi" field.
+func (r *Registry) RegisterJSON(name string, rd io.Reader) error {
+	data, err := ioutil.ReadAll(rd)
+	if err != nil {
+		return err
+	}
+
+	var artifact struct {
+		ABI json.RawMessage `json:"abi"`
+	}
+	if trimmed := strings.TrimSpace(string(data)); strings.HasPrefix(trimmed, "{") {
+		if err := json.Unmarshal(data, &artifact); err != nil {
+			return fmt.Errorf("abi %v: %w", name, err)
+		}
+		data = artifact.ABI
+	}
+
+	a, err := abi.JSON(strings.NewReader(string(data)))
+	if err != nil {
+		return fmt.Errorf("abi %v: %w", name, err)
+	}
+	r.Register(name, &a)
+	return nil
+}
+
+// LoadDir registers every *.json file of dir under its base name, so
+// NFTMarket.json is registered as "NFTMarket".
+func (r *Registry) LoadDir(dir string) error {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		fd, err := os.Open(f)
+		if err != nil {
+			return err
+		}
+		err = r.RegisterJSON(strings.TrimSuffix(filepath.Base(f), ".json"), fd)
+		fd.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Bind associates a contract address with a registered ABI.
+func (r *Registry) Bind(addr common.Address, name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	e, ok := r.abis[name]
+	if !ok {
+		return fmt.Errorf("%w %q", ErrUnknownABI, name)
+	}
+	r.contracts[addr] = e
+	return nil
+}
+
+// Unbind removes the ABI association of a contract address.
+func (r *Registry) Unbind(addr common.Address) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.contracts, addr)
+}
+
+// ABI returns the ABI called name.
+func (r *Registry) ABI(name string) (*abi.ABI, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	e, ok := r.abis[name]
+	if !ok {
+		return nil, false
+	}
+	return e.abi, true
+}
+
+// Names returns the names of the registered ABIs.
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	names := make([]string, 0, len(r.abis))
+	for name := range r.abis {
+		names = append(names, name)
+	}
+	return names
+}
+
+// ContractABI returns the ABI bound to addr and its name.
+func (r *Registry) ContractABI(addr common.Address) (*abi.ABI, string, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	e, ok := r.contracts[addr]
+	if !ok {
+		return nil, "", false
+	}
+	return e.abi, e.name, true
+}
+
+// MethodByID returns the method with the given 4-byte selector. The ABI
+// bound to the called contract is searched first, then every registered ABI.
+func (r *Registry) MethodByID(to *common.Address, selector []byte) (*abi.Method, string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if to != nil {
+		if e, ok := r.contracts[*to]; ok {
+			if m, err := e.abi.MethodById(selector); err == nil {
+				return m, e.name, nil
+			}
+		}
+	}
+	for _, e := range r.abis {
+		if m, err := e.abi.MethodById(selector); err == nil {
+			return m, e.name, nil
+		}
+	}
+	return nil, "", fmt.Errorf("abiregistry: no method with id %x", selector)
+}
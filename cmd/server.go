@@ -6,672 +6,133 @@ import (
 	"log"
 	"math/big"
 	"net/http"
-	"strings"
+	"os"
 
-	"github.com/ethereum/go-ethereum"
-	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/ethclient"
-	"github.com/joho/godotenv"
 	"github.com/labstack/echo"
 	"github.com/labstack/echo/middleware"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	glog "gorm.io/gorm/logger"
+
+	"blockchain.com/indexer/abiregistry"
+	"blockchain.com/indexer/config"
+	"blockchain.com/indexer/handler"
+	"blockchain.com/indexer/indexer"
+	"blockchain.com/indexer/metadata"
+	"blockchain.com/indexer/mint"
+	"blockchain.com/indexer/model"
+	"blockchain.com/indexer/nonce"
+	"blockchain.com/indexer/signer"
+	"blockchain.com/indexer/snapshot"
+	"blockchain.com/indexer/store"
+	"blockchain.com/indexer/store/memory"
+	"blockchain.com/indexer/store/pg"
+	"blockchain.com/indexer/watchlist"
 )
 
-const (
-	MarketABI = `[
-		{
-		  "inputs": [],
-		  "stateMutability": "nonpayable",
-		  "type": "constructor"
-		},
-		{
-		  "anonymous": false,
-		  "inputs": [
-			{
-			  "indexed": true,
-			  "internalType": "uint256",
-			  "name": "itemId",
-			  "type": "uint256"
-			},
-			{
-			  "indexed": true,
-			  "internalType": "address",
-			  "name": "nftContract",
-			  "type": "address"
-			},
-			{
-			  "indexed": true,
-			  "internalType": "uint256",
-			  "name": "tokenId",
-			  "type": "uint256"
-			},
-			{
-			  "indexed": false,
-			  "internalType": "address",
-			  "name": "seller",
-			  "type": "address"
-			},
-			{
-			  "indexed": false,
-			  "internalType": "address",
-			  "name": "owner",
-			  "type": "address"
-			},
-			{
-			  "indexed": false,
-			  "internalType": "uint256",
-			  "name": "price",
-			  "type": "uint256"
-			},
-			{
-			  "indexed": false,
-			  "internalType": "bool",
-			  "name": "sold",
-			  "type": "bool"
-			}
-		  ],
-		  "name": "MarketItemCreated",
-		  "type": "event"
-		},
-		{
-		  "inputs": [
-			{
-			  "internalType": "address",
-			  "name": "nftContract",
-			  "type": "address"
-			},
-			{
-			  "internalType": "uint256",
-			  "name": "tokenId",
-			  "type": "uint256"
-			},
-			{
-			  "internalType": "uint256",
-			  "name": "price",
-			  "type": "uint256"
-			}
-		  ],
-		  "name": "createMarketItem",
-		  "outputs": [],
-		  "stateMutability": "payable",
-		  "type": "function"
-		},
-		{
-		  "inputs": [
-			{
-			  "internalType": "address",
-			  "name": "nftContract",
-			  "type": "address"
-			},
-			{
-			  "internalType": "uint256",
-			  "name": "itemId",
-			  "type": "uint256"
-			}
-		  ],
-		  "name": "createMarketSale",
-		  "outputs": [],
-		  "stateMutability": "payable",
-		  "type": "function"
-		},
-		{
-		  "inputs": [],
-		  "name": "fetchItemsCreated",
-		  "outputs": [
-			{
-			  "components": [
-				{
-				  "internalType": "uint256",
-				  "name": "itemId",
-				  "type": "uint256"
-				},
-				{
-				  "internalType": "address",
-				  "name": "nftContract",
-				  "type": "address"
-				},
-				{
-				  "internalType": "uint256",
-				  "name": "tokenId",
-				  "type": "uint256"
-				},
-				{
-				  "internalType": "address payable",
-				  "name": "seller",
-				  "type": "address"
-				},
-				{
-				  "internalType": "address payable",
-				  "name": "owner",
-				  "type": "address"
-				},
-				{
-				  "internalType": "uint256",
-				  "name": "price",
-				  "type": "uint256"
-				},
-				{
-				  "internalType": "bool",
-				  "name": "sold",
-				  "type": "bool"
-				}
-			  ],
-			  "internalType": "struct NFTMarket.MarketItem[]",
-			  "name": "",
-			  "type": "tuple[]"
-			}
-		  ],
-		  "stateMutability": "view",
-		  "type": "function"
-		},
-		{
-		  "inputs": [],
-		  "name": "fetchMarketItems",
-		  "outputs": [
-			{
-			  "components": [
-				{
-				  "internalType": "uint256",
-				  "name": "itemId",
-				  "type": "uint256"
-				},
-				{
-				  "internalType": "address",
-				  "name": "nftContract",
-				  "type": "address"
-				},
-				{
-				  "internalType": "uint256",
-				  "name": "tokenId",
-				  "type": "uint256"
-				},
-				{
-				  "internalType": "address payable",
-				  "name": "seller",
-				  "type": "address"
-				},
-				{
-				  "internalType": "address payable",
-				  "name": "owner",
-				  "type": "address"
-				},
-				{
-				  "internalType": "uint256",
-				  "name": "price",
-				  "type": "uint256"
-				},
-				{
-				  "internalType": "bool",
-				  "name": "sold",
-				  "type": "bool"
-				}
-			  ],
-			  "internalType": "struct NFTMarket.MarketItem[]",
-			  "name": "",
-			  "type": "tuple[]"
-			}
-		  ],
-		  "stateMutability": "view",
-		  "type": "function"
-		},
-		{
-		  "inputs": [],
-		  "name": "fetchMyNFTs",
-		  "outputs": [
-			{
-			  "components": [
-				{
-				  "internalType": "uint256",
-				  "name": "itemId",
-				  "type": "uint256"
-				},
-				{
-				  "internalType": "address",
-				  "name": "nftContract",
-				  "type": "address"
-				},
-				{
-				  "internalType": "uint256",
-				  "name": "tokenId",
-				  "type": "uint256"
-				},
-				{
-				  "internalType": "address payable",
-				  "name": "seller",
-				  "type": "address"
-				},
-				{
-				  "internalType": "address payable",
-				  "name": "owner",
-				  "type": "address"
-				},
-				{
-				  "internalType": "uint256",
-				  "name": "price",
-				  "type": "uint256"
-				},
-				{
-				  "internalType": "bool",
-				  "name": "sold",
-				  "type": "bool"
-				}
-			  ],
-			  "internalType": "struct NFTMarket.MarketItem[]",
-			  "name": "",
-			  "type": "tuple[]"
-			}
-		  ],
-		  "stateMutability": "view",
-		  "type": "function"
-		},
-		{
-		  "inputs": [],
-		  "name": "getListingPrice",
-		  "outputs": [
-			{
-			  "internalType": "uint256",
-			  "name": "",
-			  "type": "uint256"
-			}
-		  ],
-		  "stateMutability": "view",
-		  "type": "function"
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "snapshot" {
+		if err := runSnapshot(os.Args[2:]); err != nil {
+			log.Fatal(err)
 		}
-	  ]`
-	NftABI = `
-	[
-    {
-      "inputs": [
-        {
-          "internalType": "address",
-          "name": "marketplaceAddress",
-          "type": "address"
-        }
-      ],
-      "stateMutability": "nonpayable",
-      "type": "constructor"
-    },
-    {
-      "anonymous": false,
-      "inputs": [
-        {
-          "indexed": true,
-          "internalType": "address",
-          "name": "owner",
-          "type": "address"
-        },
-        {
-          "indexed": true,
-          "internalType": "address",
-          "name": "approved",
-          "type": "address"
-        },
-        {
-          "indexed": true,
-          "internalType": "uint256",
-          "name": "tokenId",
-          "type": "uint256"
-        }
-      ],
-      "name": "Approval",
-      "type": "event"
-    },
-    {
-      "anonymous": false,
-      "inputs": [
-        {
-          "indexed": true,
-          "internalType": "address",
-          "name": "owner",
-          "type": "address"
-        },
-        {
-          "indexed": true,
-          "internalType": "address",
-          "name": "operator",
-          "type": "address"
-        },
-        {
-          "indexed": false,
-          "internalType": "bool",
-          "name": "approved",
-          "type": "bool"
-        }
-      ],
-      "name": "ApprovalForAll",
-      "type": "event"
-    },
-    {
-      "anonymous": false,
-      "inputs": [
-        {
-          "indexed": true,
-          "internalType": "address",
-          "name": "from",
-          "type": "address"
-        },
-        {
-          "indexed": true,
-          "internalType": "address",
-          "name": "to",
-          "type": "address"
-        },
-        {
-          "indexed": true,
-          "internalType": "uint256",
-          "name": "tokenId",
-          "type": "uint256"
-        }
-      ],
-      "name": "Transfer",
-      "type": "event"
-    },
-    {
-      "inputs": [
-        {
-          "internalType": "address",
-          "name": "to",
-          "type": "address"
-        },
-        {
-          "internalType": "uint256",
-          "name": "tokenId",
-          "type": "uint256"
-        }
-      ],
-      "name": "approve",
-      "outputs": [],
-      "stateMutability": "nonpayable",
-      "type": "function"
-    },
-    {
-      "inputs": [
-        {
-          "internalType": "address",
-          "name": "owner",
-          "type": "address"
-        }
-      ],
-      "name": "balanceOf",
-      "outputs": [
-        {
-          "internalType": "uint256",
-          "name": "",
-          "type": "uint256"
-        }
-      ],
-      "stateMutability": "view",
-      "type": "function"
-    },
-    {
-      "inputs": [
-        {
-          "internalType": "string",
-          "name": "tokenURI",
-          "type": "string"
-        }
-      ],
-      "name": "createToken",
-      "outputs": [
-        {
-          "internalType": "uint256",
-          "name": "",
-          "type": "uint256"
-        }
-      ],
-      "stateMutability": "nonpayable",
-      "type": "function"
-    },
-    {
-      "inputs": [
-        {
-          "internalType": "uint256",
-          "name": "tokenId",
-          "type": "uint256"
-        }
-      ],
-      "name": "getApproved",
-      "outputs": [
-        {
-          "internalType": "address",
-          "name": "",
-          "type": "address"
-        }
-      ],
-      "stateMutability": "view",
-      "type": "function"
-    },
-    {
-      "inputs": [
-        {
-          "internalType": "address",
-          "name": "owner",
-          "type": "address"
-        },
-        {
-          "internalType": "address",
-          "name": "operator",
-          "type": "address"
-        }
-      ],
-      "name": "isApprovedForAll",
-      "outputs": [
-        {
-          "internalType": "bool",
-          "name": "",
-          "type": "bool"
-        }
-      ],
-      "stateMutability": "view",
-      "type": "function"
-    },
-    {
-      "inputs": [],
-      "name": "name",
-      "outputs": [
-        {
-          "internalType": "string",
-          "name": "",
-          "type": "string"
-        }
-      ],
-      "stateMutability": "view",
-      "type": "function"
-    },
-    {
-      "inputs": [
-        {
-          "internalType": "uint256",
-          "name": "tokenId",
-          "type": "uint256"
-        }
-      ],
-      "name": "ownerOf",
-      "outputs": [
-        {
-          "internalType": "address",
-          "name": "",
-          "type": "address"
-        }
-      ],
-      "stateMutability": "view",
-      "type": "function"
-    },
-    {
-      "inputs": [
-        {
-          "internalType": "address",
-          "name": "from",
-          "type": "address"
-        },
-        {
-          "internalType": "address",
-          "name": "to",
-          "type": "address"
-        },
-        {
-          "internalType": "uint256",
-          "name": "tokenId",
-          "type": "uint256"
-        }
-      ],
-      "name": "safeTransferFrom",
-      "outputs": [],
-      "stateMutability": "nonpayable",
-      "type": "function"
-    },
-    {
-      "inputs": [
-        {
-          "internalType": "address",
-          "name": "from",
-          "type": "address"
-        },
-        {
-          "internalType": "address",
-          "name": "to",
-          "type": "address"
-        },
-        {
-          "internalType": "uint256",
-          "name": "tokenId",
-          "type": "uint256"
-        },
-        {
-          "internalType": "bytes",
-          "name": "_data",
-          "type": "bytes"
-        }
-      ],
-      "name": "safeTransferFrom",
-      "outputs": [],
-      "stateMutability": "nonpayable",
-      "type": "function"
-    },
-    {
-      "inputs": [
-        {
-          "internalType": "address",
-          "name": "operator",
-          "type": "address"
-        },
-        {
-          "internalType": "bool",
-          "name": "approved",
-          "type": "bool"
-        }
-      ],
-      "name": "setApprovalForAll",
-      "outputs": [],
-      "stateMutability": "nonpayable",
-      "type": "function"
-    },
-    {
-      "inputs": [
-        {
-          "internalType": "bytes4",
-          "name": "interfaceId",
-          "type": "bytes4"
-        }
-      ],
-      "name": "supportsInterface",
-      "outputs": [
-        {
-          "internalType": "bool",
-          "name": "",
-          "type": "bool"
-        }
-      ],
-      "stateMutability": "view",
-      "type": "function"
-    },
-    {
-      "inputs": [],
-      "name": "symbol",
-      "outputs": [
-        {
-          "internalType": "string",
-          "name": "",
-          "type": "string"
-        }
-      ],
-      "stateMutability": "view",
-      "type": "function"
-    },
-    {
-      "inputs": [
-        {
-          "internalType": "uint256",
-          "name": "tokenId",
-          "type": "uint256"
-        }
-      ],
-      "name": "tokenURI",
-      "outputs": [
-        {
-          "internalType": "string",
-          "name": "",
-          "type": "string"
-        }
-      ],
-      "stateMutability": "view",
-      "type": "function"
-    },
-    {
-      "inputs": [
-        {
-          "internalType": "address",
-          "name": "from",
-          "type": "address"
-        },
-        {
-          "internalType": "address",
-          "name": "to",
-          "type": "address"
-        },
-        {
-          "internalType": "uint256",
-          "name": "tokenId",
-          "type": "uint256"
-        }
-      ],
-      "name": "transferFrom",
-      "outputs": [],
-      "stateMutability": "nonpayable",
-      "type": "function"
-    }
-  ]
-	`
-)
+		return
+	}
 
-func main() {
-	if err := godotenv.Load(); err != nil {
-		panic(fmt.Errorf("cannot read .env, err: %v", err.Error()))
+	cfg, err := config.Load(os.Args[1:])
+	if err != nil {
+		log.Fatal(err)
 	}
+	network := cfg.Current()
+	log.Printf("running against network %s (chain %d)", cfg.Network, network.ChainID)
 
 	// init logger
 	// undo := Log()
 	// defer zap.L().Sync()
 	// defer undo()
 
-	// db, err := gorm.Open(postgres.New(postgres.Config{
-	// 	DSN: fmt.Sprintf("user=%v password=%v dbname=%v host=%v port=%v sslmode=disable",
-	// 		os.Getenv("DB_USER"), os.Getenv("DB_PASSWORD"), os.Getenv("DB_NAME"), os.Getenv("DB_HOST"), os.Getenv("DB_PORT")),
-	// 	PreferSimpleProtocol: true, // disables implicit prepared statement usage
-	// }), &gorm.Config{
-	// 	Logger: glog.Default.LogMode(glog.Info),
-	// })
-	// if err != nil {
-	// 	zap.L().Panic("cannot connect to db", zap.Error(err))
-	// }
+	s, err := openStore(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	client, err := ethclient.Dial(network.RPCWSURL)
+	if err != nil {
+		log.Fatal(err)
+	}
+	chainID := network.ChainID
+	if id, err := client.ChainID(context.Background()); err != nil {
+		log.Fatalf("cannot read chain id: %v", err)
+	} else if id.Uint64() != chainID {
+		log.Fatalf("node is on chain %d, network %s expects chain %d", id, cfg.Network, chainID)
+	}
+
+	registry, err := abiregistry.Default()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if cfg.ABIDir != "" {
+		if err := registry.LoadDir(cfg.ABIDir); err != nil {
+			log.Fatalf("cannot load ABIs: %v", err)
+		}
+	}
+
+	ix, err := indexer.New(client, s, registry, indexer.Config{
+		ChainID:       chainID,
+		Confirmations: network.Confirmations,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	seeds := []model.Contract{{
+		Address:    network.Contracts.Market,
+		ABI:        abiregistry.MarketABI,
+		StartBlock: network.Contracts.DeployBlock,
+		Label:      "marketplace",
+	}}
+	if network.Contracts.NFT != "" {
+		seeds = append(seeds, model.Contract{
+			Address:    network.Contracts.NFT,
+			ABI:        abiregistry.NftABI,
+			StartBlock: network.Contracts.DeployBlock,
+			Label:      "nft",
+		})
+	}
+	if cfg.ContractsFile != "" {
+		contracts, err := watchlist.LoadFile(cfg.ContractsFile)
+		if err != nil {
+			log.Fatalf("cannot load contracts: %v", err)
+		}
+		seeds = append(seeds, contracts...)
+	}
 
-	// if err := db.AutoMigrate(
-	// 	&model.Transaction{},
-	// ); err != nil {
-	// 	zap.L().Panic("cannot migrate db", zap.Error(err))
-	// }
+	wl := watchlist.New(chainID, s, registry, ix)
+	if err := wl.Load(context.Background(), seeds); err != nil {
+		log.Fatalf("cannot load watch list: %v", err)
+	}
+
+	go func() {
+		if err := ix.Run(context.Background()); err != nil {
+			log.Fatalf("indexer stopped: %v", err)
+		}
+	}()
 
-	// transactionSvc := transaction.NewPGService(db)
+	m := snapshot.NewMaterializer(s, snapshot.Config{ChainID: chainID})
+	go func() {
+		if err := m.Run(context.Background(), ix); err != nil {
+			log.Printf("snapshots stopped: %v", err)
+		}
+	}()
+
+	resolver := metadata.NewResolver(cfg.Metadata.IPFSGateways, cfg.Metadata.ArweaveGateways)
+	mw := metadata.NewWorker(s, client, resolver, metadata.Config{ChainID: chainID})
+	go func() {
+		if err := mw.Run(context.Background(), ix); err != nil {
+			log.Printf("metadata stopped: %v", err)
+		}
+	}()
 
 	e := echo.New()
 
@@ -682,54 +143,75 @@ func main() {
 	e.GET("/healthz", func(c echo.Context) error {
 		return c.String(http.StatusOK, "ok")
 	})
-	e.GET("/test", ReadLogData)
 
-	// handler.NewTransactionHandler(e, transactionSvc)
+	handler.NewBlockchainHandler(e, s, chainID)
+	handler.NewIndexerHandler(e, ix)
+	handler.NewMarketHandler(e, s, chainID, common.HexToAddress(network.Contracts.Market).Hex())
+	handler.NewNFTHandler(e, s, chainID)
+	handler.NewAccountHandler(e, s, chainID)
+	handler.NewTxHandler(e, client, registry)
+	handler.NewAdminHandler(e, wl, cfg.AdminToken)
 
-	e.Logger.Fatal(e.Start(":8080"))
-}
+	if cfg.Minter.Enabled() {
+		sig, err := openSigner(cfg.Minter, chainID)
+		if err != nil {
+			log.Fatalf("cannot open minter signer: %v", err)
+		}
+		nonces := nonce.NewManager(s, client, sig, nonce.Config{ChainID: chainID})
+		go func() {
+			if err := nonces.Run(context.Background()); err != nil {
+				log.Printf("nonce manager stopped: %v", err)
+			}
+		}()
+		minter := mint.NewService(s, client, nonces, mint.Config{
+			ChainID:     chainID,
+			Market:      network.Contracts.Market,
+			NftContract: network.Contracts.NFT,
+		})
+		log.Printf("mint API enabled, sending from %s", minter.Sender())
+		go func() {
+			if err := minter.Run(context.Background()); err != nil {
+				log.Printf("minting stopped: %v", err)
+			}
+		}()
+		handler.NewMintHandler(e, minter, s, chainID, cfg.AdminToken)
+	}
 
-type Response struct {
-	TotalVolume big.Int `json:"totalVolume"`
+	e.Logger.Fatal(e.Start(cfg.HTTP.Listen))
 }
 
-func ReadLogData(c echo.Context) error {
-	// wss://ropsten.infura.io/ws/v3/4a71ec7b7e324b4b94c4f1dc7811f260
-	client, err := ethclient.Dial("wss://ropsten.infura.io/ws/v3/4a71ec7b7e324b4b94c4f1dc7811f260")
-	if err != nil {
-		log.Fatal(err)
-	}
-	// fmt.Println(client)
-
-	contractAddress := common.HexToAddress("0x6a5ad6704a511d8B1e953076A63A6b1077814C32")
-	query := ethereum.FilterQuery{
-		FromBlock: big.NewInt(11307119),
-		ToBlock:   big.NewInt(11347669),
-		Addresses: []common.Address{
-			contractAddress,
-		},
+// openSigner returns the signer of the minter account for chainID.
+func openSigner(m config.Minter, chainID uint64) (signer.Signer, error) {
+	id := new(big.Int).SetUint64(chainID)
+	switch {
+	case m.SignerURL != "":
+		return signer.NewRemote(context.Background(), m.SignerURL, m.SignerMethod, common.HexToAddress(m.Address), id)
+	case m.Keystore != "":
+		return signer.FromKeystore(m.Keystore, m.KeystorePassword, id)
+	default:
+		log.Println("signing with a raw private key, for development only")
+		return signer.FromHex(m.PrivateKey, id, m.AllowRawKey)
 	}
+}
 
-	logs, err := client.FilterLogs(context.Background(), query)
-	if err != nil {
-		log.Fatal(err)
+// openStore opens the Postgres store, migrated, when a DSN is configured and
+// an in-memory store otherwise.
+func openStore(cfg *config.Config) (store.Store, error) {
+	if cfg.DB.DSN == "" {
+		log.Println("no database configured, keeping indexed data in memory")
+		return memory.New(), nil
 	}
-
-	contractAbi, err := abi.JSON(strings.NewReader(MarketABI))
+	db, err := gorm.Open(postgres.New(postgres.Config{
+		DSN:                  cfg.DB.DSN,
+		PreferSimpleProtocol: true, // disables implicit prepared statement usage
+	}), &gorm.Config{
+		Logger: glog.Default.LogMode(glog.Warn),
+	})
 	if err != nil {
-		log.Fatal(err)
+		return nil, fmt.Errorf("cannot connect to db: %w", err)
 	}
-
-	totalVolume := big.NewInt(0)
-	for _, vLog := range logs {
-		a, err := contractAbi.Unpack("MarketItemCreated", vLog.Data)
-		if err != nil {
-			log.Fatal(err)
-		}
-
-		totalVolume = totalVolume.Add(totalVolume, a[2].(*big.Int))
+	if err := pg.Migrate(db); err != nil {
+		return nil, fmt.Errorf("cannot migrate db: %w", err)
 	}
-	fmt.Println(totalVolume)
-
-	return c.JSON(http.StatusOK, &Response{TotalVolume: *totalVolume})
+	return pg.New(db), nil
 }
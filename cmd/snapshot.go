@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"blockchain.com/indexer/config"
+	"blockchain.com/indexer/snapshot"
+)
+
+// runSnapshot exports the holders of a contract at a past block from the
+// database, without connecting to a node:
+//
+//	go run ./cmd snapshot --contract 0x... --block 11400000 --format csv --out holders.csv
+func runSnapshot(args []string) error {
+	var (
+		contract, timestamp, format, out string
+		block                            uint64
+	)
+	set := flag.NewFlagSet("snapshot", flag.ContinueOnError)
+	set.StringVar(&contract, "contract", "", "NFT contract address")
+	set.Uint64Var(&block, "block", 0, "block number, the latest indexed block when unset")
+	set.StringVar(&timestamp, "timestamp", "", "unix seconds or RFC 3339 time, instead of --block")
+	set.StringVar(&format, "format", snapshot.FormatJSON, "output format, json or csv")
+	set.StringVar(&out, "out", "", "output file, stdout when empty")
+	cfg, err := config.LoadFlags(set, args)
+	if err != nil {
+		return err
+	}
+	if !common.IsHexAddress(contract) {
+		return fmt.Errorf("invalid --contract %q", contract)
+	}
+	if cfg.DB.DSN == "" {
+		return errors.New("snapshots are read from the database, set --db-dsn or DATABASE_URL")
+	}
+
+	var at snapshot.At
+	set.Visit(func(f *flag.Flag) {
+		if f.Name == "block" {
+			at.Block = &block
+		}
+	})
+	if timestamp != "" {
+		t, err := parseTime(timestamp)
+		if err != nil {
+			return fmt.Errorf("invalid --timestamp: %w", err)
+		}
+		at.Time = &t
+	}
+
+	s, err := openStore(cfg)
+	if err != nil {
+		return err
+	}
+	snap, err := snapshot.Take(context.Background(), s, cfg.Current().ChainID, common.HexToAddress(contract).Hex(), at)
+	if err != nil {
+		return err
+	}
+
+	w := os.Stdout
+	if out != "" {
+		if w, err = os.Create(out); err != nil {
+			return err
+		}
+	}
+	if err := snapshot.Write(w, snap, format); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+func parseTime(s string) (time.Time, error) {
+	if sec, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(sec, 0).UTC(), nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
@@ -0,0 +1,334 @@
+// Package config loads the server configuration.
+//
+// Settings are layered, each layer overriding the previous one: the built-in
+// network profiles, an optional JSON file, environment variables (including a
+// .env file in the working directory, when present) and finally command line
+// flags.
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/joho/godotenv"
+)
+
+// Config is the configuration of the server.
+type Config struct {
+	// Network selects the entry of Networks the server runs against.
+	Network  string              `json:"network"`
+	Networks map[string]*Network `json:"networks"`
+
+	DB   DB   `json:"db"`
+	HTTP HTTP `json:"http"`
+
+	// AdminToken guards the admin API, which rejects every request when it
+	// is empty.
+	AdminToken string `json:"adminToken"`
+
+	// ABIDir is a directory of extra ABI files to register.
+	ABIDir string `json:"abiDir"`
+
+	// ContractsFile is a JSON file of extra contracts to watch.
+	ContractsFile string `json:"contractsFile"`
+
+	Metadata Metadata `json:"metadata"`
+	Minter   Minter   `json:"minter"`
+}
+
+// Network describes a chain and the marketplace deployment on it.
+type Network struct {
+	RPCHTTPURL string `json:"rpcHttpUrl"`
+	RPCWSURL   string `json:"rpcWsUrl"`
+	ChainID    uint64 `json:"chainId"`
+
+	// Confirmations is the number of blocks a block must be buried under
+	// before it is indexed.
+	Confirmations uint64 `json:"confirmations"`
+
+	Contracts Contracts `json:"contracts"`
+}
+
+// Contracts are the addresses of the marketplace deployment.
+type Contracts struct {
+	Market string `json:"market"`
+	NFT    string `json:"nft"`
+
+	// DeployBlock is the block the contracts were deployed in. Indexing
+	// starts there.
+	DeployBlock uint64 `json:"deployBlock"`
+}
+
+// DB configures the database. Records are kept in memory when DSN is empty.
+type DB struct {
+	DSN string `json:"dsn"`
+}
+
+// Metadata configures the token metadata resolver. ipfs:// and ar:// URIs
+// are fetched through the gateways, in order until one answers.
+type Metadata struct {
+	IPFSGateways    []string `json:"ipfsGateways"`
+	ArweaveGateways []string `json:"arweaveGateways"`
+}
+
+// Minter configures the account the mint API sends transactions from. Its
+// key is held by one of a remote signer, an encrypted keystore file or, on
+// development networks only, a raw hex key. The mint API is disabled when
+// none is set.
+type Minter struct {
+	// SignerURL is the JSON-RPC endpoint of a remote signer holding the key
+	// of Address. SignerMethod defaults to eth_signTransaction.
+	SignerURL    string `json:"signerUrl"`
+	SignerMethod string `json:"signerMethod"`
+	Address      string `json:"address"`
+
+	Keystore         string `json:"keystore"`
+	KeystorePassword string `json:"keystorePassword"`
+
+	// PrivateKey is a hex-encoded key. It is refused on other chains than
+	// local development nodes unless AllowRawKey is set, and on mainnet
+	// regardless.
+	PrivateKey  string `json:"privateKey"`
+	AllowRawKey bool   `json:"allowRawKey"`
+}
+
+// Enabled reports whether a minter key is configured.
+func (m *Minter) Enabled() bool {
+	return m.SignerURL != "" || m.Keystore != "" || m.PrivateKey != ""
+}
+
+// HTTP configures the API server.
+type HTTP struct {
+	Listen string `json:"listen"`
+}
+
+// Default returns the configuration used when nothing else is set.
+func Default() *Config {
+	return &Config{
+		Network: "ropsten",
+		Networks: map[string]*Network{
+			// local is a Hardhat node with the market and the NFT contract
+			// deployed, in that order, by the first default account.
+			"local": {
+				RPCHTTPURL:    "http://127.0.0.1:8545",
+				RPCWSURL:      "ws://127.0.0.1:8545",
+				ChainID:       31337,
+				Confirmations: 0,
+				Contracts: Contracts{
+					Market: "0x5FbDB2315678afecb367f032d93F642f64180aa3",
+					NFT:    "0xe7f1725E7734CE288F8367e1Bb143E90bb3F0512",
+				},
+			},
+			// ropsten has no public endpoint; set RPC_URL to a provider URL.
+			"ropsten": {
+				ChainID:       3,
+				Confirmations: 12,
+				Contracts: Contracts{
+					Market:      "0x6a5ad6704a511d8B1e953076A63A6b1077814C32",
+					DeployBlock: 11307119,
+				},
+			},
+		},
+		HTTP: HTTP{Listen: ":8080"},
+		Metadata: Metadata{
+			IPFSGateways:    []string{"https://ipfs.io", "https://cloudflare-ipfs.com"},
+			ArweaveGateways: []string{"https://arweave.net"},
+		},
+	}
+}
+
+// Load builds the configuration from the defaults, the config file, the
+// environment and args, in increasing order of precedence, and validates it.
+func Load(args []string) (*Config, error) {
+	return LoadFlags(flag.NewFlagSet("server", flag.ContinueOnError), args)
+}
+
+// LoadFlags is Load with the config flags added to set, which may define
+// flags of its own.
+func LoadFlags(set *flag.FlagSet, args []string) (*Config, error) {
+	if err := godotenv.Load(); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("config: cannot read .env: %w", err)
+	}
+
+	var f flags
+	set.StringVar(&f.file, "config", "", "path of a JSON config file (env CONFIG_FILE)")
+	set.StringVar(&f.network, "network", "", "network profile to run against (env NETWORK)")
+	set.StringVar(&f.rpcURL, "rpc-url", "", "node RPC URL, http(s) or ws(s) (env RPC_URL)")
+	set.StringVar(&f.dsn, "db-dsn", "", "Postgres DSN, in-memory store when empty (env DATABASE_URL)")
+	set.StringVar(&f.listen, "listen", "", "HTTP listen address (env LISTEN_ADDR)")
+	if err := set.Parse(args); err != nil {
+		return nil, err
+	}
+
+	cfg := Default()
+	file := f.file
+	if file == "" {
+		file = os.Getenv("CONFIG_FILE")
+	}
+	if file != "" {
+		if err := cfg.loadFile(file); err != nil {
+			return nil, err
+		}
+	}
+	// The network is picked first so that network settings from the
+	// environment and the flags apply to it.
+	if v := os.Getenv("NETWORK"); v != "" {
+		cfg.Network = v
+	}
+	if f.network != "" {
+		cfg.Network = f.network
+	}
+	if err := cfg.loadEnv(); err != nil {
+		return nil, err
+	}
+	cfg.apply(f)
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Current returns the selected network.
+func (c *Config) Current() *Network {
+	return c.Networks[c.Network]
+}
+
+// loadFile reads path over c. Networks defined in the file replace the
+// built-in profile of the same name.
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("config: %w", err)
+	}
+	if err := json.Unmarshal(data, c); err != nil {
+		return fmt.Errorf("config: %s: %w", path, err)
+	}
+	return nil
+}
+
+// loadEnv applies the environment. Network settings apply to the selected
+// network.
+func (c *Config) loadEnv() error {
+	if v := os.Getenv("DATABASE_URL"); v != "" {
+		c.DB.DSN = v
+	} else if os.Getenv("DB_HOST") != "" {
+		c.DB.DSN = fmt.Sprintf("user=%v password=%v dbname=%v host=%v port=%v sslmode=disable",
+			os.Getenv("DB_USER"), os.Getenv("DB_PASSWORD"), os.Getenv("DB_NAME"), os.Getenv("DB_HOST"), os.Getenv("DB_PORT"))
+	}
+	setString(&c.HTTP.Listen, "LISTEN_ADDR")
+	setString(&c.AdminToken, "ADMIN_TOKEN")
+	setString(&c.ABIDir, "ABI_DIR")
+	setString(&c.ContractsFile, "CONTRACTS_FILE")
+	setList(&c.Metadata.IPFSGateways, "IPFS_GATEWAYS")
+	setList(&c.Metadata.ArweaveGateways, "ARWEAVE_GATEWAYS")
+	setString(&c.Minter.SignerURL, "MINTER_SIGNER_URL")
+	setString(&c.Minter.SignerMethod, "MINTER_SIGNER_METHOD")
+	setString(&c.Minter.Address, "MINTER_ADDRESS")
+	setString(&c.Minter.Keystore, "MINTER_KEYSTORE")
+	setString(&c.Minter.KeystorePassword, "MINTER_KEYSTORE_PASSWORD")
+	setString(&c.Minter.PrivateKey, "MINTER_PRIVATE_KEY")
+	if v := os.Getenv("MINTER_ALLOW_RAW_KEY"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("config: invalid MINTER_ALLOW_RAW_KEY: %w", err)
+		}
+		c.Minter.AllowRawKey = b
+	}
+
+	n := c.Current()
+	if n == nil {
+		return nil // reported by Validate
+	}
+	if v := os.Getenv("RPC_URL"); v != "" {
+		n.setRPCURL(v)
+	}
+	setString(&n.RPCHTTPURL, "RPC_HTTP_URL")
+	setString(&n.RPCWSURL, "RPC_WS_URL")
+	setString(&n.Contracts.Market, "MARKET_ADDRESS")
+	setString(&n.Contracts.NFT, "NFT_ADDRESS")
+	for _, v := range []struct {
+		dst *uint64
+		key string
+	}{
+		{&n.ChainID, "CHAIN_ID"},
+		{&n.Confirmations, "CONFIRMATIONS"},
+		{&n.Contracts.DeployBlock, "DEPLOY_BLOCK"},
+	} {
+		s := os.Getenv(v.key)
+		if s == "" {
+			continue
+		}
+		u, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return fmt.Errorf("config: invalid %s: %w", v.key, err)
+		}
+		*v.dst = u
+	}
+	return nil
+}
+
+type flags struct {
+	file    string
+	network string
+	rpcURL  string
+	dsn     string
+	listen  string
+}
+
+func (c *Config) apply(f flags) {
+	if f.dsn != "" {
+		c.DB.DSN = f.dsn
+	}
+	if f.listen != "" {
+		c.HTTP.Listen = f.listen
+	}
+	if n := c.Current(); n != nil && f.rpcURL != "" {
+		n.setRPCURL(f.rpcURL)
+	}
+}
+
+// setRPCURL sets the HTTP or the WebSocket URL depending on the scheme of u.
+func (n *Network) setRPCURL(u string) {
+	if strings.HasPrefix(u, "ws://") || strings.HasPrefix(u, "wss://") {
+		n.RPCWSURL = u
+	} else {
+		n.RPCHTTPURL = u
+	}
+}
+
+func setString(dst *string, key string) {
+	if v := os.Getenv(key); v != "" {
+		*dst = v
+	}
+}
+
+// setList sets dst to the comma-separated values of key.
+func setList(dst *[]string, key string) {
+	v := os.Getenv(key)
+	if v == "" {
+		return
+	}
+	*dst = (*dst)[:0]
+	for _, s := range strings.Split(v, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			*dst = append(*dst, s)
+		}
+	}
+}
+
+// Names returns the names of the configured networks, sorted.
+func (c *Config) Names() []string {
+	names := make([]string, 0, len(c.Networks))
+	for name := range c.Networks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,208 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// envKeys are the environment variables LoadFlags reads.
+var envKeys = []string{
+	"CONFIG_FILE", "NETWORK", "RPC_URL", "RPC_HTTP_URL", "RPC_WS_URL", "CHAIN_ID", "CONFIRMATIONS",
+	"MARKET_ADDRESS", "NFT_ADDRESS", "DEPLOY_BLOCK", "DATABASE_URL", "DB_HOST", "DB_USER", "DB_PASSWORD",
+	"DB_NAME", "DB_PORT", "LISTEN_ADDR", "ADMIN_TOKEN", "ABI_DIR", "CONTRACTS_FILE", "IPFS_GATEWAYS",
+	"ARWEAVE_GATEWAYS", "MINTER_SIGNER_URL", "MINTER_SIGNER_METHOD", "MINTER_ADDRESS", "MINTER_KEYSTORE",
+	"MINTER_KEYSTORE_PASSWORD", "MINTER_PRIVATE_KEY", "MINTER_ALLOW_RAW_KEY",
+}
+
+func TestLoadFlags(t *testing.T) {
+	const market = "0x6a5ad6704a511d8B1e953076A63A6b1077814C32"
+	file := `{
+		"network": "ropsten",
+		"http": {"listen": ":9000"},
+		"networks": {
+			"ropsten": {"rpcWsUrl": "wss://file", "chainId": 3, "contracts": {"market": "` + market + `"}},
+			"local": {"rpcWsUrl": "ws://file", "chainId": 31337, "contracts": {"market": "` + market + `"}}
+		}
+	}`
+	listen := func(c *Config) interface{} { return c.HTTP.Listen }
+	network := func(c *Config) interface{} {
+		n := c.Current()
+		return fmt.Sprintf("%s %s %s %d %s", c.Network, n.RPCHTTPURL, n.RPCWSURL, n.ChainID, n.Contracts.NFT)
+	}
+
+	tests := []struct {
+		name string
+		file string // written to a temporary file, named $FILE in env and args
+		env  map[string]string
+		args []string
+		got  func(*Config) interface{}
+		want interface{}
+		err  string
+	}{
+		{
+			name: "defaults",
+			args: []string{"-network", "local"},
+			got:  network,
+			want: "local http://127.0.0.1:8545 ws://127.0.0.1:8545 31337 0xe7f1725E7734CE288F8367e1Bb143E90bb3F0512",
+		},
+		{
+			name: "file",
+			file: file,
+			args: []string{"-config", "$FILE"},
+			got:  func(c *Config) interface{} { return fmt.Sprint(listen(c), " ", network(c)) },
+			want: ":9000 ropsten  wss://file 3 ",
+		},
+		{
+			name: "file from the environment",
+			file: file,
+			env:  map[string]string{"CONFIG_FILE": "$FILE"},
+			got:  listen,
+			want: ":9000",
+		},
+		{
+			name: "file profiles replace the built-in ones",
+			file: file,
+			args: []string{"-config", "$FILE", "-network", "local"},
+			got:  network,
+			want: "local  ws://file 31337 ",
+		},
+		{
+			name: "environment over file",
+			file: file,
+			env:  map[string]string{"CONFIG_FILE": "$FILE", "LISTEN_ADDR": ":9001"},
+			got:  listen,
+			want: ":9001",
+		},
+		{
+			name: "flags over environment",
+			file: file,
+			env:  map[string]string{"CONFIG_FILE": "$FILE", "LISTEN_ADDR": ":9001"},
+			args: []string{"-listen", ":9002"},
+			got:  listen,
+			want: ":9002",
+		},
+		{
+			name: "config flag over environment",
+			file: file,
+			env:  map[string]string{"CONFIG_FILE": "missing.json"},
+			args: []string{"-config", "$FILE"},
+			got:  listen,
+			want: ":9000",
+		},
+		{
+			name: "network from the environment takes the network settings",
+			file: file,
+			env:  map[string]string{"CONFIG_FILE": "$FILE", "NETWORK": "local", "RPC_URL": "ws://env", "CHAIN_ID": "1337"},
+			got: func(c *Config) interface{} {
+				return fmt.Sprint(network(c), ", ropsten ", c.Networks["ropsten"].RPCWSURL, " ", c.Networks["ropsten"].ChainID)
+			},
+			want: "local  ws://env 1337 , ropsten wss://file 3",
+		},
+		{
+			name: "network flag takes the network settings",
+			file: file,
+			env:  map[string]string{"CONFIG_FILE": "$FILE", "NETWORK": "ropsten", "RPC_WS_URL": "ws://env", "NFT_ADDRESS": market},
+			args: []string{"-network", "local", "-rpc-url", "http://flag"},
+			got: func(c *Config) interface{} {
+				return fmt.Sprint(network(c), ", ropsten ", c.Networks["ropsten"].RPCWSURL, " ", c.Networks["ropsten"].Contracts.NFT)
+			},
+			want: "local http://flag ws://env 31337 " + market + ", ropsten wss://file ",
+		},
+		{
+			name: "database from parts",
+			env:  map[string]string{"NETWORK": "local", "DB_HOST": "db", "DB_PORT": "5432", "DB_USER": "u", "DB_PASSWORD": "p", "DB_NAME": "n"},
+			got:  func(c *Config) interface{} { return c.DB.DSN },
+			want: "user=u password=p dbname=n host=db port=5432 sslmode=disable",
+		},
+		{
+			name: "database URL over parts",
+			env:  map[string]string{"NETWORK": "local", "DB_HOST": "db", "DATABASE_URL": "postgres://env"},
+			got:  func(c *Config) interface{} { return c.DB.DSN },
+			want: "postgres://env",
+		},
+		{
+			name: "database flag",
+			env:  map[string]string{"NETWORK": "local", "DATABASE_URL": "postgres://env"},
+			args: []string{"-db-dsn", "postgres://flag"},
+			got:  func(c *Config) interface{} { return c.DB.DSN },
+			want: "postgres://flag",
+		},
+		{
+			name: "gateways",
+			env:  map[string]string{"NETWORK": "local", "IPFS_GATEWAYS": " https://a, ,https://b"},
+			got:  func(c *Config) interface{} { return c.Metadata.IPFSGateways },
+			want: []string{"https://a", "https://b"},
+		},
+		{
+			name: "missing file",
+			args: []string{"-config", "missing.json"},
+			err:  "missing.json",
+		},
+		{
+			name: "invalid file",
+			file: `{"network": 3}`,
+			args: []string{"-config", "$FILE"},
+			err:  "config.json",
+		},
+		{
+			name: "invalid number",
+			env:  map[string]string{"NETWORK": "local", "CONFIRMATIONS": "ten"},
+			err:  "invalid CONFIRMATIONS",
+		},
+		{
+			name: "unknown network",
+			args: []string{"-network", "goerli"},
+			err:  `unknown network "goerli", expected one of local, ropsten`,
+		},
+		{
+			name: "default network needs a node",
+			err:  "network ropsten: rpcWsUrl is required",
+		},
+		{
+			name: "unknown flag",
+			args: []string{"-verbose"},
+			err:  "-verbose",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, k := range envKeys {
+				t.Setenv(k, "")
+			}
+			path := filepath.Join(t.TempDir(), "config.json")
+			if tt.file != "" {
+				if err := os.WriteFile(path, []byte(tt.file), 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for k, v := range tt.env {
+				t.Setenv(k, strings.ReplaceAll(v, "$FILE", path))
+			}
+			args := make([]string, len(tt.args))
+			for i, a := range tt.args {
+				args[i] = strings.ReplaceAll(a, "$FILE", path)
+			}
+
+			set := flag.NewFlagSet("test", flag.ContinueOnError)
+			set.SetOutput(io.Discard)
+			c, err := LoadFlags(set, args)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("error %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := tt.got(c); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"blockchain.com/indexer/signer"
+)
+
+// Validate reports every invalid setting of c at once.
+func (c *Config) Validate() error {
+	var errs []string
+	fail := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Sprintf(format, args...))
+	}
+
+	n := c.Current()
+	if n == nil {
+		fail("unknown network %q, expected one of %s", c.Network, strings.Join(c.Names(), ", "))
+	} else {
+		if n.RPCWSURL == "" {
+			fail("network %s: rpcWsUrl is required to follow new heads (set RPC_URL or RPC_WS_URL)", c.Network)
+		} else if err := checkURL(n.RPCWSURL, "ws", "wss"); err != nil {
+			fail("network %s: rpcWsUrl: %v", c.Network, err)
+		}
+		if n.RPCHTTPURL != "" {
+			if err := checkURL(n.RPCHTTPURL, "http", "https"); err != nil {
+				fail("network %s: rpcHttpUrl: %v", c.Network, err)
+			}
+		}
+		if n.ChainID == 0 {
+			fail("network %s: chainId is required", c.Network)
+		}
+		if n.Contracts.Market == "" {
+			fail("network %s: contracts.market is required", c.Network)
+		} else if !common.IsHexAddress(n.Contracts.Market) {
+			fail("network %s: contracts.market: invalid address %q", c.Network, n.Contracts.Market)
+		}
+		if n.Contracts.NFT != "" && !common.IsHexAddress(n.Contracts.NFT) {
+			fail("network %s: contracts.nft: invalid address %q", c.Network, n.Contracts.NFT)
+		}
+	}
+
+	if c.HTTP.Listen == "" {
+		fail("http.listen is required")
+	} else if _, _, err := net.SplitHostPort(c.HTTP.Listen); err != nil {
+		fail("http.listen: %v", err)
+	}
+
+	for i, gw := range c.Metadata.IPFSGateways {
+		if err := checkURL(gw, "http", "https"); err != nil {
+			fail("metadata.ipfsGateways[%d]: %v", i, err)
+		}
+	}
+	for i, gw := range c.Metadata.ArweaveGateways {
+		if err := checkURL(gw, "http", "https"); err != nil {
+			fail("metadata.arweaveGateways[%d]: %v", i, err)
+		}
+	}
+
+	m := &c.Minter
+	keys := 0
+	for _, v := range []string{m.SignerURL, m.Keystore, m.PrivateKey} {
+		if v != "" {
+			keys++
+		}
+	}
+	if keys > 1 {
+		fail("minter: set only one of signerUrl, keystore and privateKey")
+	}
+	if m.SignerURL != "" {
+		if err := checkURL(m.SignerURL, "http", "https", "ws", "wss"); err != nil {
+			fail("minter.signerUrl: %v", err)
+		}
+		if !common.IsHexAddress(m.Address) {
+			fail("minter.address: %q is not an address, it is required with signerUrl", m.Address)
+		}
+	}
+	if m.PrivateKey != "" {
+		if _, err := crypto.HexToECDSA(strings.TrimPrefix(m.PrivateKey, "0x")); err != nil {
+			// The error does not echo the key.
+			fail("minter.privateKey: %v", err)
+		}
+		if n != nil && n.ChainID == 1 {
+			fail("minter.privateKey: raw keys are for development networks, use a keystore or a remote signer on mainnet")
+		} else if n != nil && !signer.IsDevChain(n.ChainID) && !m.AllowRawKey {
+			fail("minter.privateKey: raw keys are only allowed on chains %v, set minter.allowRawKey to use one on testnet %d", signer.DevChainIDs, n.ChainID)
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("config: invalid configuration:\n\t%s", strings.Join(errs, "\n\t"))
+	}
+	return nil
+}
+
+func checkURL(raw string, schemes ...string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return err
+	}
+	for _, s := range schemes {
+		if u.Scheme == s {
+			if u.Host == "" {
+				return fmt.Errorf("%q has no host", raw)
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("%q: scheme must be one of %s", raw, strings.Join(schemes, ", "))
+}
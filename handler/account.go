@@ -0,0 +1,49 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo"
+
+	"blockchain.com/indexer/store"
+	"blockchain.com/indexer/wallet"
+)
+
+type AccountHandler struct {
+	store   store.Store
+	chainID uint64
+}
+
+// NewAccountHandler registers the account activity endpoints.
+func NewAccountHandler(e *echo.Echo, s store.Store, chainID uint64) {
+	h := &AccountHandler{store: s, chainID: chainID}
+	e.GET("/v1/accounts/:addr/summary", h.Summary)
+	e.GET("/v1/accounts/:addr/summary/tokens", h.Tokens)
+}
+
+// Summary returns the tokens an account minted, bought, sold and listed, what
+// it spent, received and paid in listing fees, and its realized profit.
+func (h *AccountHandler) Summary(c echo.Context) error {
+	r, err := h.report(c)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, &r.Summary)
+}
+
+// Tokens returns the summary of an account with its breakdown per token.
+func (h *AccountHandler) Tokens(c echo.Context) error {
+	r, err := h.report(c)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, r)
+}
+
+func (h *AccountHandler) report(c echo.Context) (*wallet.Report, error) {
+	addr, err := parseAddress("addr", c.Param("addr"))
+	if err != nil {
+		return nil, err
+	}
+	return wallet.Build(c.Request().Context(), h.store, h.chainID, addr)
+}
@@ -0,0 +1,78 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo"
+	"github.com/labstack/echo/middleware"
+
+	"blockchain.com/indexer/model"
+	"blockchain.com/indexer/store"
+	"blockchain.com/indexer/watchlist"
+)
+
+type AdminHandler struct {
+	watchlist *watchlist.Watchlist
+}
+
+// NewAdminHandler registers the admin endpoints. Requests must carry token
+// as a bearer token.
+func NewAdminHandler(e *echo.Echo, wl *watchlist.Watchlist, token string) {
+	h := &AdminHandler{watchlist: wl}
+
+	g := e.Group("/v1/admin", adminAuth(token))
+	g.GET("/contracts", h.ListContracts)
+	g.POST("/contracts", h.AddContract)
+	g.DELETE("/contracts/:address", h.RemoveContract)
+}
+
+// adminAuth accepts the requests carrying token as a bearer token, and none
+// if token is empty.
+func adminAuth(token string) echo.MiddlewareFunc {
+	return middleware.KeyAuth(func(key string, c echo.Context) (bool, error) {
+		return token != "" && subtle.ConstantTimeCompare([]byte(key), []byte(token)) == 1, nil
+	})
+}
+
+// ListContracts returns the watch list.
+func (h *AdminHandler) ListContracts(c echo.Context) error {
+	contracts, err := h.watchlist.List(c.Request().Context())
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, contracts)
+}
+
+// AddContract adds a contract to the watch list; the indexer backfills it
+// from its start block.
+func (h *AdminHandler) AddContract(c echo.Context) error {
+	var contract model.Contract
+	if err := c.Bind(&contract); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	err := h.watchlist.Add(c.Request().Context(), &contract)
+	if errors.Is(err, watchlist.ErrInvalidContract) {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusCreated, &contract)
+}
+
+// RemoveContract stops indexing a contract.
+func (h *AdminHandler) RemoveContract(c echo.Context) error {
+	err := h.watchlist.Remove(c.Request().Context(), c.Param("address"))
+	switch {
+	case errors.Is(err, watchlist.ErrInvalidContract):
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	case errors.Is(err, store.ErrNotFound):
+		return echo.ErrNotFound
+	case err != nil:
+		return err
+	}
+	return c.NoContent(http.StatusNoContent)
+}
@@ -1,18 +1,34 @@
 package handler
 
 import (
-	"fmt"
-	"log"
+	"net/http"
 
-	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/labstack/echo"
+
+	"blockchain.com/indexer/model"
+	"blockchain.com/indexer/store"
 )
 
-func ReadLogData(c echo.Context) {
-	// wss://ropsten.infura.io/ws/v3/4a71ec7b7e324b4b94c4f1dc7811f260
-	client, err := ethclient.Dial("wss://ropsten.infura.io/ws")
+type BlockchainHandler struct {
+	store   store.Store
+	chainID uint64
+}
+
+func NewBlockchainHandler(e *echo.Echo, s store.Store, chainID uint64) {
+	h := &BlockchainHandler{store: s, chainID: chainID}
+	e.GET("/test", h.TotalVolume)
+}
+
+type Response struct {
+	TotalVolume model.Amount `json:"totalVolume"`
+}
+
+// TotalVolume returns the sum of the listing prices of every indexed
+// MarketItemCreated event.
+func (h *BlockchainHandler) TotalVolume(c echo.Context) error {
+	total, err := h.store.TotalVolume(c.Request().Context(), h.chainID)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
-	fmt.Println(client)
+	return c.JSON(http.StatusOK, &Response{TotalVolume: model.NewAmount(total)})
 }
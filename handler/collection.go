@@ -0,0 +1,95 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo"
+
+	"blockchain.com/indexer/model"
+	"blockchain.com/indexer/store"
+)
+
+type CollectionsResponse struct {
+	Collections []model.CollectionStats `json:"collections"`
+}
+
+type FloorHistoryResponse struct {
+	Market      string             `json:"market"`
+	NftContract string             `json:"nft_contract"`
+	History     []model.FloorPrice `json:"history"`
+}
+
+// Collections returns the floor price, active listings and listed share of
+// the supply of every NFT contract listed on the market.
+func (h *MarketHandler) Collections(c echo.Context) error {
+	market, err := h.marketParam(c)
+	if err != nil {
+		return err
+	}
+	cs, err := h.store.Collections(c.Request().Context(), h.chainID, market)
+	if err != nil {
+		return err
+	}
+	if cs == nil {
+		cs = []model.CollectionStats{}
+	}
+	return c.JSON(http.StatusOK, &CollectionsResponse{Collections: cs})
+}
+
+// Collection returns the listing state of one NFT contract on the market.
+func (h *MarketHandler) Collection(c echo.Context) error {
+	market, err := h.marketParam(c)
+	if err != nil {
+		return err
+	}
+	contract, err := parseAddress("contract", c.Param("contract"))
+	if err != nil {
+		return err
+	}
+	cs, err := h.store.Collection(c.Request().Context(), h.chainID, market, contract)
+	if errors.Is(err, store.ErrNotFound) {
+		return echo.ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, cs)
+}
+
+// FloorHistory returns the floor price of an NFT contract on the market after
+// every block that listed or sold one of its tokens, optionally between the
+// from and to times (unix seconds or RFC 3339).
+func (h *MarketHandler) FloorHistory(c echo.Context) error {
+	q := store.FloorHistoryQuery{ChainID: h.chainID}
+	var err error
+	if q.Market, err = h.marketParam(c); err != nil {
+		return err
+	}
+	if q.NftContract, err = parseAddress("contract", c.Param("contract")); err != nil {
+		return err
+	}
+	from, err := queryTime(c, "from")
+	if err != nil {
+		return err
+	}
+	to, err := queryTime(c, "to")
+	if err != nil {
+		return err
+	}
+	if from != nil {
+		q.From = *from
+	}
+	if to != nil {
+		q.To = *to
+	}
+
+	history, err := h.store.FloorHistory(c.Request().Context(), q)
+	if err != nil {
+		return err
+	}
+	if history == nil {
+		history = []model.FloorPrice{}
+	}
+	return c.JSON(http.StatusOK, &FloorHistoryResponse{Market: q.Market, NftContract: q.NftContract, History: history})
+}
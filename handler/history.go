@@ -0,0 +1,152 @@
+package handler
+
+import (
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/labstack/echo"
+
+	"blockchain.com/indexer/model"
+	"blockchain.com/indexer/store"
+)
+
+// Token history entry types.
+const (
+	TokenEventMint     = "mint"
+	TokenEventTransfer = "transfer"
+	TokenEventBurn     = "burn"
+	TokenEventApproval = "approval"
+	TokenEventListing  = "listing"
+	TokenEventSale     = "sale"
+)
+
+// TokenEvent is an entry of the history of a token. From and To are the
+// counterparties: the sender and recipient of a transfer, the owner and the
+// approved account of an approval, the seller and the market of a listing
+// and the seller and buyer of a sale.
+type TokenEvent struct {
+	Type string `json:"type"`
+	From string `json:"from"`
+	To   string `json:"to"`
+
+	// Price is set on listings and sales.
+	Price *model.Amount `json:"price,omitempty"`
+
+	// Market and ItemID identify the market item of listings and sales.
+	Market string        `json:"market,omitempty"`
+	ItemID *model.BigInt `json:"item_id,omitempty"`
+
+	BlockNumber uint64 `json:"block_number"`
+
+	// BlockTime is missing for blocks that were not indexed.
+	BlockTime *time.Time `json:"block_time,omitempty"`
+
+	TxHash   string `json:"tx_hash"`
+	LogIndex uint   `json:"log_index"`
+}
+
+type TokenHistoryResponse struct {
+	ChainID  uint64       `json:"chain_id"`
+	Contract string       `json:"contract"`
+	TokenID  model.BigInt `json:"token_id"`
+	Events   []TokenEvent `json:"events"`
+}
+
+// History returns the Transfers, Approvals, listings and sales of a token in
+// chain order. The Transfer out of the market that settles a sale is
+// reported as the sale.
+func (h *NFTHandler) History(c echo.Context) error {
+	contract, tokenID, err := tokenParams(c)
+	if err != nil {
+		return err
+	}
+	b, err := h.store.TokenRecords(c.Request().Context(), h.chainID, contract, tokenID)
+	if err != nil {
+		return err
+	}
+	if len(b.Transfers) == 0 && len(b.MarketItems) == 0 {
+		return echo.ErrNotFound
+	}
+	return c.JSON(http.StatusOK, &TokenHistoryResponse{
+		ChainID:  h.chainID,
+		Contract: contract,
+		TokenID:  model.NewBigInt(tokenID),
+		Events:   tokenEvents(b),
+	})
+}
+
+// tokenEvents merges the records of a token into one timeline.
+func tokenEvents(b *store.Batch) []TokenEvent {
+	times := make(map[uint64]time.Time, len(b.Blocks))
+	for _, blk := range b.Blocks {
+		times[blk.Number] = blk.Time
+	}
+	// Sales are inferred from a Transfer and share its position.
+	type logKey struct {
+		tx    string
+		index uint
+	}
+	sold := make(map[logKey]bool, len(b.Sales))
+	for _, sale := range b.Sales {
+		sold[logKey{sale.TxHash, sale.LogIndex}] = true
+	}
+
+	events := make([]TokenEvent, 0, len(b.Transfers)+len(b.Approvals)+len(b.MarketItems))
+	add := func(e TokenEvent) {
+		if t, ok := times[e.BlockNumber]; ok {
+			e.BlockTime = &t
+		}
+		events = append(events, e)
+	}
+	for _, t := range b.Transfers {
+		if sold[logKey{t.TxHash, t.LogIndex}] {
+			continue
+		}
+		typ := TokenEventTransfer
+		switch {
+		case t.FromAddress == store.UnsoldOwner:
+			typ = TokenEventMint
+		case t.ToAddress == store.UnsoldOwner:
+			typ = TokenEventBurn
+		}
+		add(TokenEvent{
+			Type: typ, From: t.FromAddress, To: t.ToAddress,
+			BlockNumber: t.BlockNumber, TxHash: t.TxHash, LogIndex: t.LogIndex,
+		})
+	}
+	for _, a := range b.Approvals {
+		add(TokenEvent{
+			Type: TokenEventApproval, From: a.Owner, To: a.Approved,
+			BlockNumber: a.BlockNumber, TxHash: a.TxHash, LogIndex: a.LogIndex,
+		})
+	}
+	for _, it := range b.MarketItems {
+		itemID := it.ItemID
+		add(TokenEvent{
+			Type: TokenEventListing, From: it.Seller, To: it.Market,
+			Price: amount(it.Price), Market: it.Market, ItemID: &itemID,
+			BlockNumber: it.BlockNumber, TxHash: it.TxHash, LogIndex: it.LogIndex,
+		})
+	}
+	for _, sale := range b.Sales {
+		itemID := sale.ItemID
+		add(TokenEvent{
+			Type: TokenEventSale, From: sale.Seller, To: sale.Buyer,
+			Price: amount(sale.Price), Market: sale.Market, ItemID: &itemID,
+			BlockNumber: sale.BlockNumber, TxHash: sale.TxHash, LogIndex: sale.LogIndex,
+		})
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		if events[i].BlockNumber != events[j].BlockNumber {
+			return events[i].BlockNumber < events[j].BlockNumber
+		}
+		return events[i].LogIndex < events[j].LogIndex
+	})
+	return events
+}
+
+func amount(v model.BigInt) *model.Amount {
+	a := model.NewAmount(v.Big())
+	return &a
+}
@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo"
+
+	"blockchain.com/indexer/indexer"
+)
+
+type IndexerHandler struct {
+	indexer *indexer.Indexer
+}
+
+func NewIndexerHandler(e *echo.Echo, ix *indexer.Indexer) {
+	h := &IndexerHandler{indexer: ix}
+	e.GET("/v1/indexer/progress", h.Progress)
+}
+
+type Progress struct {
+	From            uint64  `json:"from"`
+	To              uint64  `json:"to"`
+	Current         uint64  `json:"current"`
+	BlocksPerSecond float64 `json:"blocks_per_second"`
+	ETASeconds      float64 `json:"eta_seconds"`
+}
+
+type ProgressResponse struct {
+	Progress
+
+	// Backfills holds the contracts that are catching up separately, by
+	// address.
+	Backfills map[string]Progress `json:"backfills"`
+}
+
+func newProgress(p indexer.Progress) Progress {
+	return Progress{
+		From:            p.From,
+		To:              p.To,
+		Current:         p.Current,
+		BlocksPerSecond: p.BlocksPerSecond,
+		ETASeconds:      p.ETA.Seconds(),
+	}
+}
+
+// Progress reports how far the indexer got through the range it is syncing.
+func (h *IndexerHandler) Progress(c echo.Context) error {
+	res := &ProgressResponse{
+		Progress:  newProgress(h.indexer.Progress()),
+		Backfills: make(map[string]Progress),
+	}
+	for addr, p := range h.indexer.ContractProgress() {
+		res.Backfills[addr.Hex()] = newProgress(p)
+	}
+	return c.JSON(http.StatusOK, res)
+}
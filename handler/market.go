@@ -0,0 +1,274 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo"
+
+	"blockchain.com/indexer/model"
+	"blockchain.com/indexer/store"
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 500
+)
+
+type MarketHandler struct {
+	store   store.Store
+	chainID uint64
+	market  string
+}
+
+// NewMarketHandler registers the listing endpoints. Items are looked up in
+// market unless the request names another market contract.
+func NewMarketHandler(e *echo.Echo, s store.Store, chainID uint64, market string) {
+	h := &MarketHandler{store: s, chainID: chainID, market: market}
+	e.GET("/v1/market/items", h.ListItems)
+	e.GET("/v1/market/items/:itemId", h.GetItem)
+	e.GET("/v1/market/stats", h.Stats)
+	e.GET("/v1/market/collections", h.Collections)
+	e.GET("/v1/market/collections/:contract", h.Collection)
+	e.GET("/v1/market/collections/:contract/floor", h.FloorHistory)
+}
+
+type MarketItemsResponse struct {
+	Items []model.MarketItem `json:"items"`
+
+	// NextCursor fetches the next page. It is empty on the last page.
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// MarketItemResponse is a market item with its creation transaction and,
+// once sold, its sale.
+type MarketItemResponse struct {
+	model.MarketItem
+
+	BlockTime   time.Time          `json:"block_time"`
+	Transaction *model.Transaction `json:"transaction"`
+	Sale        *model.MarketSale  `json:"sale,omitempty"`
+}
+
+var itemSorts = map[string]store.MarketItemSort{
+	"itemId":      store.SortByItemID,
+	"price":       store.SortByPrice,
+	"blockNumber": store.SortByBlockNumber,
+}
+
+// ListItems returns market items, filtered by the nftContract, seller, owner,
+// sold, minPrice, maxPrice, fromBlock, toBlock and trait parameters and
+// sorted by sort (itemId, price or blockNumber) in order (asc or desc).
+// trait (type:value) may be repeated: values of one type are alternatives,
+// different types must all match.
+func (h *MarketHandler) ListItems(c echo.Context) error {
+	q := store.MarketItemQuery{ChainID: h.chainID}
+	var err error
+	if q.Market, err = h.marketParam(c); err != nil {
+		return err
+	}
+	if q.NftContract, err = queryAddress(c, "nftContract"); err != nil {
+		return err
+	}
+	if q.Seller, err = queryAddress(c, "seller"); err != nil {
+		return err
+	}
+	if q.Owner, err = queryAddress(c, "owner"); err != nil {
+		return err
+	}
+	if q.Sold, err = queryBool(c, "sold"); err != nil {
+		return err
+	}
+	if q.MinPrice, err = queryBigInt(c, "minPrice"); err != nil {
+		return err
+	}
+	if q.MaxPrice, err = queryBigInt(c, "maxPrice"); err != nil {
+		return err
+	}
+	if q.FromBlock, err = queryUint(c, "fromBlock"); err != nil {
+		return err
+	}
+	if q.ToBlock, err = queryUint(c, "toBlock"); err != nil {
+		return err
+	}
+	if q.Traits, err = queryTraits(c); err != nil {
+		return err
+	}
+
+	q.Sort = store.SortByItemID
+	if s := c.QueryParam("sort"); s != "" {
+		sort, ok := itemSorts[s]
+		if !ok {
+			return badParam("sort", fmt.Sprintf("%q, expected itemId, price or blockNumber", s))
+		}
+		q.Sort = sort
+	}
+	switch order := c.QueryParam("order"); order {
+	case "", "asc":
+	case "desc":
+		q.Desc = true
+	default:
+		return badParam("order", fmt.Sprintf("%q, expected asc or desc", order))
+	}
+
+	if q.After, err = queryCursor(c); err != nil {
+		return err
+	}
+	if q.Limit, err = queryLimit(c, defaultPageSize, maxPageSize); err != nil {
+		return err
+	}
+
+	items, err := h.store.MarketItems(c.Request().Context(), q)
+	if err != nil {
+		return err
+	}
+	res := &MarketItemsResponse{Items: items}
+	if res.Items == nil {
+		res.Items = []model.MarketItem{}
+	}
+	if len(items) == q.Limit {
+		last := &items[len(items)-1]
+		res.NextCursor = encodeCursor(store.Cursor{Key: q.SortKey(last), ID: last.ID})
+	}
+	return c.JSON(http.StatusOK, res)
+}
+
+// GetItem returns a market item with the transaction that created it, the
+// time of its block and its sale.
+func (h *MarketHandler) GetItem(c echo.Context) error {
+	ctx := c.Request().Context()
+	market, err := h.marketParam(c)
+	if err != nil {
+		return err
+	}
+	itemID, err := parseBigInt("itemId", c.Param("itemId"))
+	if err != nil {
+		return err
+	}
+
+	it, err := h.store.MarketItem(ctx, h.chainID, market, itemID)
+	if errors.Is(err, store.ErrNotFound) {
+		return echo.ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	res := &MarketItemResponse{MarketItem: *it}
+	blk, err := h.store.Block(ctx, h.chainID, it.BlockNumber)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		return err
+	}
+	if blk != nil {
+		res.BlockTime = blk.Time
+	}
+	res.Transaction, err = h.store.Transaction(ctx, h.chainID, it.TxHash)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		return err
+	}
+	res.Sale, err = h.store.Sale(ctx, h.chainID, market, itemID)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		return err
+	}
+	return c.JSON(http.StatusOK, res)
+}
+
+// maxStatsBuckets bounds the range of a statistics request.
+const maxStatsBuckets = 1000
+
+type MarketStatsResponse struct {
+	Market      string              `json:"market"`
+	NftContract string              `json:"nft_contract,omitempty"`
+	Interval    string              `json:"interval"`
+	Buckets     []model.MarketStats `json:"buckets"`
+}
+
+// Stats returns the listing and sale statistics of the market, or of the
+// nftContract parameter on it, bucketed by interval (hour, day or week) from
+// from to to (unix seconds or RFC 3339). The range defaults to the 30
+// buckets up to now; buckets without activity are reported empty.
+func (h *MarketHandler) Stats(c echo.Context) error {
+	q := store.MarketStatsQuery{ChainID: h.chainID, Interval: store.IntervalDay}
+	var err error
+	if q.Market, err = h.marketParam(c); err != nil {
+		return err
+	}
+	if q.NftContract, err = queryAddress(c, "nftContract"); err != nil {
+		return err
+	}
+	switch v := store.StatsInterval(c.QueryParam("interval")); v {
+	case "":
+	case store.IntervalHour, store.IntervalDay, store.IntervalWeek:
+		q.Interval = v
+	default:
+		return badParam("interval", fmt.Sprintf("%q, expected hour, day or week", v))
+	}
+	from, err := queryTime(c, "from")
+	if err != nil {
+		return err
+	}
+	to, err := queryTime(c, "to")
+	if err != nil {
+		return err
+	}
+
+	// The range is widened to whole buckets.
+	q.To = q.Interval.Next(q.Interval.Truncate(time.Now()))
+	if to != nil {
+		if q.To = q.Interval.Truncate(*to); !q.To.Equal(*to) {
+			q.To = q.Interval.Next(q.To)
+		}
+	}
+	var buckets []time.Time
+	if from != nil {
+		q.From = q.Interval.Truncate(*from)
+		if !q.From.Before(q.To) {
+			return badParam("from", "not before to")
+		}
+		for t := q.From; t.Before(q.To); t = q.Interval.Next(t) {
+			if len(buckets) == maxStatsBuckets {
+				return badParam("from", fmt.Sprintf("range spans more than %d buckets", maxStatsBuckets))
+			}
+			buckets = append(buckets, t)
+		}
+	} else {
+		q.From = q.To
+		for i := 0; i < 30; i++ {
+			q.From = q.Interval.Truncate(q.From.Add(-time.Nanosecond))
+			buckets = append([]time.Time{q.From}, buckets...)
+		}
+	}
+
+	stats, err := h.store.MarketStats(c.Request().Context(), q)
+	if err != nil {
+		return err
+	}
+	res := &MarketStatsResponse{
+		Market:      q.Market,
+		NftContract: q.NftContract,
+		Interval:    string(q.Interval),
+		Buckets:     make([]model.MarketStats, len(buckets)),
+	}
+	for i, start := range buckets {
+		res.Buckets[i].Start = start
+		for len(stats) > 0 && stats[0].Start.Before(start) {
+			stats = stats[1:]
+		}
+		if len(stats) > 0 && stats[0].Start.Equal(start) {
+			res.Buckets[i] = stats[0]
+		}
+	}
+	return c.JSON(http.StatusOK, res)
+}
+
+// marketParam returns the market named by the market parameter, or the
+// default market.
+func (h *MarketHandler) marketParam(c echo.Context) (string, error) {
+	market, err := queryAddress(c, "market")
+	if market == "" && err == nil {
+		market = h.market
+	}
+	return market, err
+}
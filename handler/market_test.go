@@ -0,0 +1,128 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/labstack/echo"
+
+	"blockchain.com/indexer/model"
+	"blockchain.com/indexer/store"
+	"blockchain.com/indexer/store/memory"
+)
+
+const testChainID = 1337
+
+var (
+	testMarket = common.HexToAddress("0x00000000000000000000000000000000000000aa").Hex()
+	testNFT    = common.HexToAddress("0x00000000000000000000000000000000000000bb").Hex()
+)
+
+// get serves a GET of target and decodes the JSON response into v.
+func get(t *testing.T, e *echo.Echo, target string, v interface{}) int {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+	if rec.Code == http.StatusOK && v != nil {
+		if err := json.Unmarshal(rec.Body.Bytes(), v); err != nil {
+			t.Fatalf("GET %s: %v", target, err)
+		}
+	}
+	return rec.Code
+}
+
+func TestListItemsPages(t *testing.T) {
+	s := memory.New()
+	// Prices repeat so that pages split runs of equal sort keys.
+	prices := []int64{300, 100, 200, 100, 300, 100, 200}
+	b := &store.Batch{}
+	for i, p := range prices {
+		b.MarketItems = append(b.MarketItems, model.MarketItem{
+			ChainID:     testChainID,
+			Market:      testMarket,
+			ItemID:      model.NewBigInt(big.NewInt(int64(i + 1))),
+			NftContract: testNFT,
+			TokenID:     model.NewBigInt(big.NewInt(int64(i + 1))),
+			Owner:       store.UnsoldOwner,
+			Price:       model.NewBigInt(big.NewInt(p)),
+			BlockNumber: uint64(10 - i%3),
+			TxHash:      common.BigToHash(big.NewInt(int64(i))).Hex(),
+		})
+	}
+	if err := s.Commit(context.Background(), b); err != nil {
+		t.Fatal(err)
+	}
+	e := echo.New()
+	NewMarketHandler(e, s, testChainID, testMarket)
+
+	tests := []struct {
+		sort, order string
+		want        []int64
+	}{
+		{"itemId", "asc", []int64{1, 2, 3, 4, 5, 6, 7}},
+		{"itemId", "desc", []int64{7, 6, 5, 4, 3, 2, 1}},
+		{"price", "asc", []int64{2, 4, 6, 3, 7, 1, 5}},
+		{"price", "desc", []int64{5, 1, 7, 3, 6, 4, 2}},
+		{"blockNumber", "asc", []int64{3, 6, 2, 5, 1, 4, 7}},
+	}
+	for _, tt := range tests {
+		for _, limit := range []int{1, 2, 3, 7, 10} {
+			t.Run(fmt.Sprintf("%s %s by %d", tt.sort, tt.order, limit), func(t *testing.T) {
+				var got []int64
+				cursor := ""
+				for pages := 0; ; pages++ {
+					if pages > len(prices) {
+						t.Fatalf("no last page after %d pages", pages)
+					}
+					q := url.Values{"sort": {tt.sort}, "order": {tt.order}, "limit": {fmt.Sprint(limit)}}
+					if cursor != "" {
+						q.Set("cursor", cursor)
+					}
+					var res MarketItemsResponse
+					if code := get(t, e, "/v1/market/items?"+q.Encode(), &res); code != http.StatusOK {
+						t.Fatalf("status %d", code)
+					}
+					if len(res.Items) > limit {
+						t.Fatalf("page of %d items, limit %d", len(res.Items), limit)
+					}
+					for _, it := range res.Items {
+						got = append(got, it.ItemID.Int64())
+					}
+					if res.NextCursor == "" {
+						break
+					}
+					cursor = res.NextCursor
+				}
+				if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+					t.Errorf("items %v, want %v", got, tt.want)
+				}
+			})
+		}
+	}
+}
+
+func TestListItemsBadParams(t *testing.T) {
+	e := echo.New()
+	NewMarketHandler(e, memory.New(), testChainID, testMarket)
+	for _, q := range []string{
+		"cursor=not-base64!",
+		"cursor=" + url.QueryEscape(encodeCursor(store.Cursor{})[:2]),
+		"sort=seller",
+		"order=up",
+		"limit=0",
+		"minPrice=ten",
+		"seller=0x1234",
+		"trait=Background",
+	} {
+		if code := get(t, e, "/v1/market/items?"+q, nil); code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", q, code)
+		}
+	}
+}
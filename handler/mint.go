@@ -0,0 +1,119 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo"
+
+	"blockchain.com/indexer/mint"
+	"blockchain.com/indexer/model"
+	"blockchain.com/indexer/store"
+)
+
+type MintHandler struct {
+	service *mint.Service
+	store   store.Store
+	chainID uint64
+}
+
+// NewMintHandler registers the mint endpoints with the admin endpoints:
+// requests must carry token as a bearer token.
+func NewMintHandler(e *echo.Echo, svc *mint.Service, s store.Store, chainID uint64, token string) {
+	h := &MintHandler{service: svc, store: s, chainID: chainID}
+
+	g := e.Group("/v1/admin/mints", adminAuth(token))
+	g.POST("", h.Submit)
+	g.GET("", h.ListJobs)
+	g.GET("/:id", h.GetJob)
+	g.POST("/:id/resume", h.Resume)
+}
+
+type MintRequest struct {
+	TokenURI string       `json:"token_uri"`
+	Price    model.BigInt `json:"price"`
+
+	// NftContract defaults to the configured NFT contract.
+	NftContract string `json:"nft_contract"`
+}
+
+// Submit queues a job minting a token and listing it at price on the
+// market. The job runs in the background; its progress is read back with
+// GetJob.
+func (h *MintHandler) Submit(c echo.Context) error {
+	var req MintRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	j, err := h.service.Submit(c.Request().Context(), req.TokenURI, req.Price.Big(), req.NftContract)
+	if errors.Is(err, mint.ErrInvalidJob) {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusAccepted, j)
+}
+
+// ListJobs returns the mint jobs, optionally with the status parameter
+// (pending, failed or done) only.
+func (h *MintHandler) ListJobs(c echo.Context) error {
+	status := model.MintStatus(c.QueryParam("status"))
+	switch status {
+	case "", model.MintPending, model.MintFailed, model.MintDone:
+	default:
+		return badParam("status", fmt.Sprintf("%q, expected pending, failed or done", status))
+	}
+	jobs, err := h.store.MintJobs(c.Request().Context(), h.chainID, status)
+	if err != nil {
+		return err
+	}
+	if jobs == nil {
+		jobs = []model.MintJob{}
+	}
+	return c.JSON(http.StatusOK, jobs)
+}
+
+// GetJob returns a mint job with the transactions of the steps it ran.
+func (h *MintHandler) GetJob(c echo.Context) error {
+	id, err := jobID(c)
+	if err != nil {
+		return err
+	}
+	j, err := h.store.MintJob(c.Request().Context(), h.chainID, id)
+	if errors.Is(err, store.ErrNotFound) {
+		return echo.ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, j)
+}
+
+// Resume retries a failed job from the step it failed at.
+func (h *MintHandler) Resume(c echo.Context) error {
+	id, err := jobID(c)
+	if err != nil {
+		return err
+	}
+	j, err := h.service.Resume(c.Request().Context(), id)
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		return echo.ErrNotFound
+	case errors.Is(err, mint.ErrNotFailed):
+		return echo.NewHTTPError(http.StatusConflict, err.Error())
+	case err != nil:
+		return err
+	}
+	return c.JSON(http.StatusAccepted, j)
+}
+
+func jobID(c echo.Context) (int, error) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		return 0, badParam("id", fmt.Sprintf("%q is not a job id", c.Param("id")))
+	}
+	return id, nil
+}
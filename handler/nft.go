@@ -0,0 +1,232 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+
+	"github.com/labstack/echo"
+
+	"blockchain.com/indexer/model"
+	"blockchain.com/indexer/snapshot"
+	"blockchain.com/indexer/store"
+)
+
+type NFTHandler struct {
+	store   store.Store
+	chainID uint64
+}
+
+// NewNFTHandler registers the token ownership and history endpoints, served
+// from the index instead of OwnerOf and BalanceOf calls.
+func NewNFTHandler(e *echo.Echo, s store.Store, chainID uint64) {
+	h := &NFTHandler{store: s, chainID: chainID}
+	e.GET("/v1/nft/:contract/tokens/:id/owner", h.Owner)
+	e.GET("/v1/nft/:contract/tokens/:id/history", h.History)
+	e.GET("/v1/nft/:contract/tokens/:id/metadata", h.Metadata)
+	e.GET("/v1/nft/:contract/tokens/:id/rarity", h.Rarity)
+	e.GET("/v1/nft/:contract/traits", h.Traits)
+	e.GET("/v1/nft/:contract/holders", h.Holders)
+	e.GET("/v1/accounts/:addr/nfts", h.AccountNFTs)
+}
+
+type AccountNFTsResponse struct {
+	Balances []model.NFTBalance `json:"balances"`
+	Tokens   []model.NFTOwner   `json:"tokens"`
+
+	// NextCursor fetches the next page of tokens. It is empty on the last
+	// page.
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// Owner returns the current owner of a token. Burned tokens are owned by the
+// zero address.
+func (h *NFTHandler) Owner(c echo.Context) error {
+	contract, tokenID, err := tokenParams(c)
+	if err != nil {
+		return err
+	}
+	o, err := h.store.TokenOwner(c.Request().Context(), h.chainID, contract, tokenID)
+	if errors.Is(err, store.ErrNotFound) {
+		return echo.ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, o)
+}
+
+// TokenMetadataResponse is the metadata of a token with its attributes and
+// document as JSON, and its rarity once scored.
+type TokenMetadataResponse struct {
+	model.TokenMetadata
+
+	Attributes json.RawMessage    `json:"attributes"`
+	Raw        json.RawMessage    `json:"raw"`
+	Rarity     *model.TokenRarity `json:"rarity,omitempty"`
+}
+
+// Metadata returns the resolved metadata of a token, or its resolution
+// status while it is pending or failed.
+func (h *NFTHandler) Metadata(c echo.Context) error {
+	contract, tokenID, err := tokenParams(c)
+	if err != nil {
+		return err
+	}
+	ctx := c.Request().Context()
+	m, err := h.store.TokenMetadata(ctx, h.chainID, contract, tokenID)
+	if errors.Is(err, store.ErrNotFound) {
+		return echo.ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	res := &TokenMetadataResponse{TokenMetadata: *m, Attributes: json.RawMessage(m.Attributes)}
+	if m.Status == model.MetadataResolved {
+		res.Raw = json.RawMessage(m.Raw)
+	}
+	res.Rarity, err = h.store.TokenRarity(ctx, h.chainID, contract, tokenID)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		return err
+	}
+	return c.JSON(http.StatusOK, res)
+}
+
+// Rarity returns the rarity score and rank of a token within its
+// collection.
+func (h *NFTHandler) Rarity(c echo.Context) error {
+	contract, tokenID, err := tokenParams(c)
+	if err != nil {
+		return err
+	}
+	r, err := h.store.TokenRarity(c.Request().Context(), h.chainID, contract, tokenID)
+	if errors.Is(err, store.ErrNotFound) {
+		return echo.ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, r)
+}
+
+type TraitsResponse struct {
+	Contract string             `json:"contract"`
+	Traits   []model.TraitCount `json:"traits"`
+}
+
+// Traits returns the trait frequency table of a contract: the number of
+// tokens with each value of each trait type, tokens without a trait type
+// being counted under its empty value.
+func (h *NFTHandler) Traits(c echo.Context) error {
+	contract, err := parseAddress("contract", c.Param("contract"))
+	if err != nil {
+		return err
+	}
+	counts, err := h.store.TraitCounts(c.Request().Context(), h.chainID, contract)
+	if err != nil {
+		return err
+	}
+	res := &TraitsResponse{Contract: contract, Traits: counts}
+	if res.Traits == nil {
+		res.Traits = []model.TraitCount{}
+	}
+	return c.JSON(http.StatusOK, res)
+}
+
+// Holders exports the holders of a contract at the block parameter, at the
+// timestamp parameter (unix seconds or RFC 3339) or, by default, at the
+// latest indexed block. format selects json (default) or csv.
+func (h *NFTHandler) Holders(c echo.Context) error {
+	contract, err := parseAddress("contract", c.Param("contract"))
+	if err != nil {
+		return err
+	}
+	var at snapshot.At
+	if at.Block, err = queryUint(c, "block"); err != nil {
+		return err
+	}
+	if at.Time, err = queryTime(c, "timestamp"); err != nil {
+		return err
+	}
+	format := c.QueryParam("format")
+	if format != "" && format != snapshot.FormatJSON && format != snapshot.FormatCSV {
+		return badParam("format", fmt.Sprintf("%q, expected json or csv", format))
+	}
+
+	snap, err := snapshot.Take(c.Request().Context(), h.store, h.chainID, contract, at)
+	if errors.Is(err, snapshot.ErrNotIndexed) || errors.Is(err, snapshot.ErrNoBlock) {
+		return echo.NewHTTPError(http.StatusNotFound, err.Error())
+	}
+	if err != nil {
+		return err
+	}
+
+	res := c.Response()
+	if format == snapshot.FormatCSV {
+		res.Header().Set(echo.HeaderContentType, "text/csv")
+		res.Header().Set(echo.HeaderContentDisposition,
+			fmt.Sprintf("attachment; filename=%q", fmt.Sprintf("holders-%s-%d.csv", contract, snap.BlockNumber)))
+	} else {
+		res.Header().Set(echo.HeaderContentType, echo.MIMEApplicationJSONCharsetUTF8)
+	}
+	res.WriteHeader(http.StatusOK)
+	return snapshot.Write(res, snap, format)
+}
+
+// AccountNFTs returns the balances of an account per contract and the
+// tokens it owns, optionally of one contract only, in the order it acquired
+// them.
+func (h *NFTHandler) AccountNFTs(c echo.Context) error {
+	ctx := c.Request().Context()
+	q := store.OwnedTokenQuery{ChainID: h.chainID}
+	var err error
+	if q.Owner, err = parseAddress("addr", c.Param("addr")); err != nil {
+		return err
+	}
+	if q.Contract, err = queryAddress(c, "contract"); err != nil {
+		return err
+	}
+	if q.After, err = queryCursor(c); err != nil {
+		return err
+	}
+	if q.Limit, err = queryLimit(c, defaultPageSize, maxPageSize); err != nil {
+		return err
+	}
+
+	res := &AccountNFTsResponse{}
+	if res.Balances, err = h.store.NFTBalances(ctx, h.chainID, q.Owner); err != nil {
+		return err
+	}
+	if res.Tokens, err = h.store.OwnedTokens(ctx, q); err != nil {
+		return err
+	}
+	if res.Balances == nil {
+		res.Balances = []model.NFTBalance{}
+	}
+	if res.Tokens == nil {
+		res.Tokens = []model.NFTOwner{}
+	}
+	if len(res.Tokens) == q.Limit {
+		last := &res.Tokens[len(res.Tokens)-1]
+		res.NextCursor = encodeCursor(store.Cursor{
+			Key: model.NewBigInt(new(big.Int).SetUint64(last.BlockNumber)),
+			ID:  int(last.LogIndex),
+		})
+	}
+	return c.JSON(http.StatusOK, res)
+}
+
+// tokenParams parses the contract and id path parameters.
+func tokenParams(c echo.Context) (string, *big.Int, error) {
+	contract, err := parseAddress("contract", c.Param("contract"))
+	if err != nil {
+		return "", nil, err
+	}
+	tokenID, err := parseBigInt("id", c.Param("id"))
+	if err != nil {
+		return "", nil, err
+	}
+	return contract, tokenID, nil
+}
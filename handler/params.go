@@ -0,0 +1,164 @@
+package handler
+
+import (
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/labstack/echo"
+
+	"blockchain.com/indexer/model"
+	"blockchain.com/indexer/store"
+)
+
+// Query parameter parsers. A missing parameter yields the zero value; an
+// invalid one a 400 error naming it.
+
+func badParam(name string, err interface{}) error {
+	return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("invalid %s: %v", name, err))
+}
+
+// parseAddress returns s as a checksummed address, the form addresses are
+// stored in.
+func parseAddress(name, s string) (string, error) {
+	if !common.IsHexAddress(s) {
+		return "", badParam(name, fmt.Sprintf("%q is not an address", s))
+	}
+	return common.HexToAddress(s).Hex(), nil
+}
+
+func queryAddress(c echo.Context, name string) (string, error) {
+	s := c.QueryParam(name)
+	if s == "" {
+		return "", nil
+	}
+	return parseAddress(name, s)
+}
+
+func queryBool(c echo.Context, name string) (*bool, error) {
+	s := c.QueryParam(name)
+	if s == "" {
+		return nil, nil
+	}
+	v, err := strconv.ParseBool(s)
+	if err != nil {
+		return nil, badParam(name, err)
+	}
+	return &v, nil
+}
+
+func queryUint(c echo.Context, name string) (*uint64, error) {
+	s := c.QueryParam(name)
+	if s == "" {
+		return nil, nil
+	}
+	v, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return nil, badParam(name, err)
+	}
+	return &v, nil
+}
+
+// queryBigInt accepts decimal and 0x-prefixed hex integers.
+func queryBigInt(c echo.Context, name string) (*big.Int, error) {
+	s := c.QueryParam(name)
+	if s == "" {
+		return nil, nil
+	}
+	return parseBigInt(name, s)
+}
+
+func parseBigInt(name, s string) (*big.Int, error) {
+	v, err := model.ParseBigInt(s)
+	if err != nil {
+		return nil, badParam(name, err)
+	}
+	return v.Big(), nil
+}
+
+// queryTime accepts unix seconds and RFC 3339 times.
+func queryTime(c echo.Context, name string) (*time.Time, error) {
+	s := c.QueryParam(name)
+	if s == "" {
+		return nil, nil
+	}
+	if sec, err := strconv.ParseInt(s, 10, 64); err == nil {
+		t := time.Unix(sec, 0).UTC()
+		return &t, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return nil, badParam(name, "expected unix seconds or an RFC 3339 time")
+	}
+	return &t, nil
+}
+
+// queryLimit returns the page size, def when missing, capped at max.
+func queryLimit(c echo.Context, def, max int) (int, error) {
+	s := c.QueryParam("limit")
+	if s == "" {
+		return def, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v <= 0 {
+		return 0, badParam("limit", fmt.Sprintf("%q is not a positive integer", s))
+	}
+	if v > max {
+		v = max
+	}
+	return v, nil
+}
+
+// Cursors are opaque to clients: the sort key and record id of the last
+// record of a page, base64 encoded.
+
+func encodeCursor(cur store.Cursor) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(cur.Key.String() + ":" + strconv.Itoa(cur.ID)))
+}
+
+func queryCursor(c echo.Context) (*store.Cursor, error) {
+	s := c.QueryParam("cursor")
+	if s == "" {
+		return nil, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, badParam("cursor", err)
+	}
+	parts := strings.SplitN(string(raw), ":", 2)
+	if len(parts) != 2 {
+		return nil, badParam("cursor", "malformed")
+	}
+	key, err := model.ParseBigInt(parts[0])
+	if err != nil {
+		return nil, badParam("cursor", err)
+	}
+	id, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return nil, badParam("cursor", err)
+	}
+	return &store.Cursor{Key: key, ID: id}, nil
+}
+
+// queryTraits parses the repeated trait parameter, each a trait type and a
+// value separated by the first colon, into the values selected per type.
+func queryTraits(c echo.Context) (map[string][]string, error) {
+	params := c.QueryParams()["trait"]
+	if len(params) == 0 {
+		return nil, nil
+	}
+	traits := make(map[string][]string)
+	for _, p := range params {
+		i := strings.IndexByte(p, ':')
+		if i <= 0 {
+			return nil, badParam("trait", fmt.Sprintf("%q, expected type:value", p))
+		}
+		traits[p[:i]] = append(traits[p[:i]], p[i+1:])
+	}
+	return traits, nil
+}
@@ -0,0 +1,118 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/labstack/echo"
+
+	"blockchain.com/indexer/abiregistry"
+	"blockchain.com/indexer/model"
+)
+
+// TxBackend fetches transactions from the node.
+type TxBackend interface {
+	TransactionByHash(ctx context.Context, hash common.Hash) (*types.Transaction, bool, error)
+	TransactionReceipt(ctx context.Context, hash common.Hash) (*types.Receipt, error)
+}
+
+type TxHandler struct {
+	backend  TxBackend
+	registry *abiregistry.Registry
+}
+
+func NewTxHandler(e *echo.Echo, backend TxBackend, registry *abiregistry.Registry) {
+	h := &TxHandler{backend: backend, registry: registry}
+	e.GET("/v1/tx/:hash/decoded", h.Decoded)
+}
+
+type DecodedArg struct {
+	Name  string      `json:"name"`
+	Type  string      `json:"type"`
+	Value interface{} `json:"value"`
+}
+
+type DecodedTxResponse struct {
+	Hash        string                  `json:"hash"`
+	From        string                  `json:"from"`
+	To          string                  `json:"to"`
+	Value       model.Amount            `json:"value"`
+	Status      model.TransactionStatus `json:"status"`
+	BlockNumber *uint64                 `json:"block_number,omitempty"`
+
+	ABI       string       `json:"abi,omitempty"`
+	Method    string       `json:"method,omitempty"`
+	Signature string       `json:"signature,omitempty"`
+	Selector  string       `json:"selector,omitempty"`
+	Args      []DecodedArg `json:"args,omitempty"`
+
+	// Error tells why the calldata did not decode.
+	Error string `json:"error,omitempty"`
+}
+
+// Decoded returns the method and arguments a transaction called, whether it
+// succeeded, reverted or is still pending.
+func (h *TxHandler) Decoded(c echo.Context) error {
+	ctx := c.Request().Context()
+	raw, err := hexutil.Decode(c.Param("hash"))
+	if err != nil || len(raw) != common.HashLength {
+		return badParam("hash", "not a transaction hash")
+	}
+	hash := common.BytesToHash(raw)
+
+	tx, _, err := h.backend.TransactionByHash(ctx, hash)
+	if errors.Is(err, ethereum.NotFound) {
+		return echo.ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	res := &DecodedTxResponse{
+		Hash:   hash.Hex(),
+		Value:  model.NewAmount(tx.Value()),
+		Status: model.TransactionStatusPending,
+	}
+	if from, err := types.Sender(types.LatestSignerForChainID(tx.ChainId()), tx); err == nil {
+		res.From = from.Hex()
+	}
+	if tx.To() != nil {
+		res.To = tx.To().Hex()
+	}
+	if len(tx.Data()) >= 4 {
+		res.Selector = hexutil.Encode(tx.Data()[:4])
+	}
+
+	receipt, err := h.backend.TransactionReceipt(ctx, hash)
+	switch {
+	case errors.Is(err, ethereum.NotFound):
+	case err != nil:
+		return err
+	default:
+		res.Status = model.TransactionStatusSuccess
+		if receipt.Status != types.ReceiptStatusSuccessful {
+			res.Status = model.TransactionStatusFailed
+		}
+		n := receipt.BlockNumber.Uint64()
+		res.BlockNumber = &n
+	}
+
+	call, err := h.registry.DecodeCall(tx)
+	if err != nil {
+		res.Error = err.Error()
+		return c.JSON(http.StatusOK, res)
+	}
+	res.ABI = call.ABI
+	res.Method = call.Method
+	res.Signature = call.Signature
+	res.Args = make([]DecodedArg, len(call.Args))
+	for i, a := range call.Args {
+		res.Args[i] = DecodedArg{Name: a.Name, Type: a.Type, Value: abiregistry.JSONValue(a.Value)}
+	}
+	return c.JSON(http.StatusOK, res)
+}
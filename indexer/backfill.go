@@ -0,0 +1,252 @@
+package indexer
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/rpc"
+
+	"blockchain.com/indexer/store"
+)
+
+// Progress describes the block range currently being indexed.
+type Progress struct {
+	From    uint64
+	To      uint64
+	Current uint64
+
+	BlocksPerSecond float64
+	ETA             time.Duration
+}
+
+// Progress returns the state of the followed contracts' running backfill.
+// When they are following the head it describes the last indexed range.
+func (ix *Indexer) Progress() Progress {
+	return ix.live.progress.get()
+}
+
+// tooLargeMessages are fragments of the errors providers return when a log
+// query covers too many blocks or results.
+var tooLargeMessages = []string{
+	"query returned more than",
+	"response size exceeded",
+	"log response size exceeded",
+	"block range is too large",
+	"block range too large",
+	"exceed maximum block range",
+	"too many results",
+	"limit exceeded",
+}
+
+// isRangeTooLarge reports whether err rejects a log query for its size.
+func isRangeTooLarge(err error) bool {
+	var rpcErr rpc.Error
+	if errors.As(err, &rpcErr) && rpcErr.ErrorCode() == -32005 {
+		return true
+	}
+	msg := strings.ToLower(err.Error())
+	for _, m := range tooLargeMessages {
+		if strings.Contains(msg, m) {
+			return true
+		}
+	}
+	return false
+}
+
+// chunk is a block range fetched by a backfill worker.
+type chunk struct {
+	seq   int
+	from  uint64
+	to    uint64
+	batch *store.Batch
+	err   error
+}
+
+// backfill indexes blocks from through to for the contracts of g. Ranges are
+// fetched by Concurrency workers and committed in block order.
+func (ix *Indexer) backfill(ctx context.Context, g *group, from, to uint64) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	skip := g.skip()
+	g.progress.start(from, to)
+
+	// window bounds the number of ranges fetched ahead of the oldest
+	// uncommitted one.
+	window := make(chan struct{}, 2*ix.cfg.Concurrency)
+	jobs := make(chan chunk)
+	results := make(chan chunk)
+
+	go func() {
+		defer close(jobs)
+		seq := 0
+		for start := from; start <= to; seq++ {
+			end := start + ix.sizer.size() - 1
+			if end > to || end < start {
+				end = to
+			}
+			select {
+			case window <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			select {
+			case jobs <- chunk{seq: seq, from: start, to: end}:
+			case <-ctx.Done():
+				return
+			}
+			start = end + 1
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < ix.cfg.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for c := range jobs {
+				var logs int
+				c.batch, logs, c.err = ix.fetch(ctx, g, c.from, c.to, skip)
+				if c.err == nil {
+					ix.sizer.observe(logs)
+				}
+				select {
+				case results <- c:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	pending := make(map[int]chunk)
+	next := 0
+	for c := range results {
+		if c.err != nil {
+			return c.err
+		}
+		pending[c.seq] = c
+
+		for {
+			c, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			next++
+
+			if err := ix.commit(ctx, g, c.batch); err != nil {
+				return err
+			}
+			<-window
+
+			p := g.progress.advance(c.to)
+			log.Printf("indexer: indexed blocks %d-%d (%d/%d, %.1f blocks/s, eta %v)",
+				c.from, c.to, c.to-p.From+1, p.To-p.From+1, p.BlocksPerSecond, p.ETA.Round(time.Second))
+		}
+	}
+	return ctx.Err()
+}
+
+// chunkSizer adapts the size of fetched ranges to the number of logs they
+// return.
+type chunkSizer struct {
+	mu      sync.Mutex
+	current uint64
+	min     uint64
+	max     uint64
+	target  int
+}
+
+func newChunkSizer(cfg Config) *chunkSizer {
+	return &chunkSizer{
+		current: cfg.ChunkSize,
+		min:     cfg.MinChunkSize,
+		max:     cfg.MaxChunkSize,
+		target:  cfg.TargetLogs,
+	}
+}
+
+func (s *chunkSizer) size() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.current
+}
+
+// shrink halves the size after the node rejected a range.
+func (s *chunkSizer) shrink() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.current /= 2
+	if s.current < s.min {
+		s.current = s.min
+	}
+}
+
+// observe grows the size when a range returned few logs and shrinks it when
+// it returned too many.
+func (s *chunkSizer) observe(logs int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch {
+	case logs > s.target:
+		s.current /= 2
+		if s.current < s.min {
+			s.current = s.min
+		}
+	case logs < s.target/4:
+		s.current *= 2
+		if s.current > s.max {
+			s.current = s.max
+		}
+	}
+}
+
+type progressTracker struct {
+	mu      sync.Mutex
+	p       Progress
+	started time.Time
+}
+
+func (t *progressTracker) start(from, to uint64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.p = Progress{From: from, To: to}
+	if from > 0 {
+		t.p.Current = from - 1
+	}
+	t.started = time.Now()
+}
+
+func (t *progressTracker) advance(current uint64) Progress {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.p.Current = current
+	done := float64(current - t.p.From + 1)
+	if elapsed := time.Since(t.started).Seconds(); elapsed > 0 {
+		t.p.BlocksPerSecond = done / elapsed
+	}
+	if t.p.BlocksPerSecond > 0 {
+		remaining := float64(t.p.To - current)
+		t.p.ETA = time.Duration(remaining / t.p.BlocksPerSecond * float64(time.Second))
+	}
+	return t.p
+}
+
+func (t *progressTracker) get() Progress {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.p
+}
@@ -0,0 +1,116 @@
+package indexer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestChunkSizerObserve(t *testing.T) {
+	cfg := Config{ChunkSize: 100, MinChunkSize: 10, MaxChunkSize: 300, TargetLogs: 1000}
+	tests := []struct {
+		name string
+		logs []int
+		want uint64
+	}{
+		{"on target", []int{1000}, 100},
+		{"few logs grow", []int{100}, 200},
+		{"growth is capped", []int{0, 0, 0}, 300},
+		{"too many logs shrink", []int{1001}, 50},
+		{"shrinking stops at the minimum", []int{5000, 5000, 5000, 5000}, 10},
+		{"a quarter of the target keeps the size", []int{250}, 100},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newChunkSizer(cfg)
+			for _, n := range tt.logs {
+				s.observe(n)
+			}
+			if got := s.size(); got != tt.want {
+				t.Errorf("size after %v = %d, want %d", tt.logs, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChunkSizerShrink(t *testing.T) {
+	s := newChunkSizer(Config{ChunkSize: 100, MinChunkSize: 30, MaxChunkSize: 1000, TargetLogs: 10})
+	for _, want := range []uint64{50, 30, 30} {
+		s.shrink()
+		if got := s.size(); got != want {
+			t.Fatalf("size after shrink = %d, want %d", got, want)
+		}
+	}
+}
+
+func TestIsRangeTooLarge(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{rangeTooLarge{}, true},
+		{errors.New("Log response size exceeded. You can make eth_getLogs requests with up to a 2K block range"), true},
+		{fmt.Errorf("filter: %w", errors.New("block range is too large")), true},
+		{errors.New("connection refused"), false},
+	}
+	for _, tt := range tests {
+		if got := isRangeTooLarge(tt.err); got != tt.want {
+			t.Errorf("isRangeTooLarge(%q) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestFilterLogsBisects(t *testing.T) {
+	f := newFixture(t, Config{ChunkSize: 64, MinChunkSize: 1})
+	for i := 0; i < 8; i++ {
+		f.mint(t, f.seller, int64(i))
+		f.chain.mine()
+	}
+	f.chain.rejectAbove = 2
+
+	logs, err := f.ix.filterLogs(context.Background(), nil, nil, 1, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(logs) != 8 {
+		t.Fatalf("got %d logs, want 8", len(logs))
+	}
+	for i, l := range logs {
+		if l.BlockNumber != uint64(i+1) {
+			t.Fatalf("log %d in block %d, want the logs in block order", i, l.BlockNumber)
+		}
+	}
+	// 1-8 and 1-4, 5-8 are rejected; the four 2-block halves are not.
+	if f.chain.filterCalls != 7 {
+		t.Errorf("%d FilterLogs calls, want 7", f.chain.filterCalls)
+	}
+	if got := f.ix.sizer.size(); got != 8 {
+		t.Errorf("chunk size after 3 rejections = %d, want 8", got)
+	}
+}
+
+func TestBackfillCommitsInOrder(t *testing.T) {
+	f := newFixture(t, Config{ChunkSize: 1, MaxChunkSize: 1, Concurrency: 4})
+	for i := 0; i < 20; i++ {
+		f.mint(t, f.seller, int64(i))
+		f.chain.mine()
+	}
+	events := make(chan ChainEvent, 32)
+	sub := f.ix.SubscribeChainEvents(events)
+	defer sub.Unsubscribe()
+
+	f.sync(t)
+	for want := uint64(1); want <= 20; want++ {
+		ev := <-events
+		if ev.Batch.FromBlock != want {
+			t.Fatalf("committed blocks %d-%d, want %d next", ev.Batch.FromBlock, ev.Batch.ToBlock, want)
+		}
+	}
+	if p := f.ix.Progress(); p.Current != 20 {
+		t.Errorf("progress at block %d, want 20", p.Current)
+	}
+	if cp := f.checkpoint(t, f.nft); cp.BlockNumber != 20 {
+		t.Errorf("checkpoint at block %d, want 20", cp.BlockNumber)
+	}
+}
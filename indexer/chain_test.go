@@ -0,0 +1,264 @@
+package indexer
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"math/big"
+	"sync"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/event"
+)
+
+const testChainID = 1337
+
+// chain is a Backend serving blocks mined in memory. Fork replaces the
+// blocks above a number, as a reorganization does.
+type chain struct {
+	mu       sync.Mutex
+	headers  []*types.Header
+	logs     map[common.Hash][]types.Log
+	txs      map[common.Hash]*types.Transaction
+	receipts map[common.Hash]*types.Receipt
+	pending  []pendingTx
+	nonces   map[common.Address]uint64
+	forks    byte
+
+	// filterCalls counts FilterLogs calls; rejectAbove makes it reject
+	// ranges of more blocks.
+	filterCalls int
+	rejectAbove uint64
+}
+
+type pendingTx struct {
+	tx   *types.Transaction
+	logs []types.Log
+}
+
+func newChain() *chain {
+	c := &chain{
+		logs:     make(map[common.Hash][]types.Log),
+		txs:      make(map[common.Hash]*types.Transaction),
+		receipts: make(map[common.Hash]*types.Receipt),
+		nonces:   make(map[common.Address]uint64),
+	}
+	c.headers = []*types.Header{{Number: new(big.Int), Difficulty: common.Big1}}
+	return c
+}
+
+// send queues a transaction from key emitting logs for the next block.
+func (c *chain) send(t *testing.T, key *ecdsa.PrivateKey, to common.Address, value *big.Int, data []byte, logs ...types.Log) *types.Transaction {
+	t.Helper()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	tx, err := types.SignTx(types.NewTx(&types.LegacyTx{
+		Nonce:    c.nonces[from],
+		To:       &to,
+		Value:    value,
+		Gas:      100000,
+		GasPrice: big.NewInt(1),
+		Data:     data,
+	}), types.LatestSignerForChainID(big.NewInt(testChainID)), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.nonces[from]++
+	c.pending = append(c.pending, pendingTx{tx, logs})
+	return tx
+}
+
+// mine mines the queued transactions in a new block.
+func (c *chain) mine() *types.Header {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	parent := c.headers[len(c.headers)-1]
+	h := &types.Header{
+		ParentHash: parent.Hash(),
+		Number:     new(big.Int).Add(parent.Number, common.Big1),
+		Difficulty: common.Big1,
+		Time:       parent.Time + 12,
+		Extra:      []byte{c.forks},
+	}
+	var logs []types.Log
+	for i, p := range c.pending {
+		for _, l := range p.logs {
+			l.BlockNumber = h.Number.Uint64()
+			l.BlockHash = h.Hash()
+			l.TxHash = p.tx.Hash()
+			l.TxIndex = uint(i)
+			l.Index = uint(len(logs))
+			logs = append(logs, l)
+		}
+		c.txs[p.tx.Hash()] = p.tx
+		c.receipts[p.tx.Hash()] = &types.Receipt{
+			Status:           types.ReceiptStatusSuccessful,
+			TxHash:           p.tx.Hash(),
+			BlockHash:        h.Hash(),
+			BlockNumber:      h.Number,
+			TransactionIndex: uint(i),
+			GasUsed:          21000,
+		}
+	}
+	c.pending = nil
+	c.headers = append(c.headers, h)
+	c.logs[h.Hash()] = logs
+	return h
+}
+
+// fork drops the blocks above number and their transactions. Blocks mined
+// afterwards differ from the dropped ones.
+func (c *chain) fork(number uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, h := range c.headers[number+1:] {
+		for _, l := range c.logs[h.Hash()] {
+			delete(c.txs, l.TxHash)
+			delete(c.receipts, l.TxHash)
+		}
+		delete(c.logs, h.Hash())
+	}
+	c.headers = c.headers[:number+1]
+	c.forks++
+}
+
+func (c *chain) FilterLogs(ctx context.Context, q ethereum.FilterQuery) ([]types.Log, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.filterCalls++
+	from, to := q.FromBlock.Uint64(), q.ToBlock.Uint64()
+	if c.rejectAbove > 0 && to-from+1 > c.rejectAbove {
+		return nil, rangeTooLarge{}
+	}
+	var logs []types.Log
+	for n := from; n <= to && n < uint64(len(c.headers)); n++ {
+		for _, l := range c.logs[c.headers[n].Hash()] {
+			if matchLog(q, l) {
+				logs = append(logs, l)
+			}
+		}
+	}
+	return logs, nil
+}
+
+type rangeTooLarge struct{}
+
+func (rangeTooLarge) Error() string  { return "query returned more than 10000 results" }
+func (rangeTooLarge) ErrorCode() int { return -32005 }
+
+func matchLog(q ethereum.FilterQuery, l types.Log) bool {
+	if len(q.Addresses) > 0 {
+		found := false
+		for _, a := range q.Addresses {
+			found = found || a == l.Address
+		}
+		if !found {
+			return false
+		}
+	}
+	for i, set := range q.Topics {
+		if len(set) == 0 {
+			continue
+		}
+		if i >= len(l.Topics) {
+			return false
+		}
+		found := false
+		for _, topic := range set {
+			found = found || topic == l.Topics[i]
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *chain) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if number == nil {
+		return c.headers[len(c.headers)-1], nil
+	}
+	if n := number.Uint64(); n < uint64(len(c.headers)) {
+		return c.headers[n], nil
+	}
+	return nil, ethereum.NotFound
+}
+
+func (c *chain) SubscribeNewHead(ctx context.Context, ch chan<- *types.Header) (ethereum.Subscription, error) {
+	return event.NewSubscription(func(quit <-chan struct{}) error {
+		<-quit
+		return nil
+	}), nil
+}
+
+func (c *chain) TransactionByHash(ctx context.Context, hash common.Hash) (*types.Transaction, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	tx, ok := c.txs[hash]
+	if !ok {
+		return nil, false, ethereum.NotFound
+	}
+	return tx, false, nil
+}
+
+func (c *chain) TransactionReceipt(ctx context.Context, hash common.Hash) (*types.Receipt, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	r, ok := c.receipts[hash]
+	if !ok {
+		return nil, ethereum.NotFound
+	}
+	return r, nil
+}
+
+// eventLog returns the log of event name of a emitted by addr with args, in
+// the order of the event inputs.
+func eventLog(t *testing.T, a *abi.ABI, name string, addr common.Address, args ...interface{}) types.Log {
+	t.Helper()
+	ev := a.Events[name]
+	l := types.Log{Address: addr, Topics: []common.Hash{ev.ID}}
+	var data []interface{}
+	for i, in := range ev.Inputs {
+		if !in.Indexed {
+			data = append(data, args[i])
+			continue
+		}
+		switch v := args[i].(type) {
+		case common.Address:
+			l.Topics = append(l.Topics, common.BytesToHash(v.Bytes()))
+		case *big.Int:
+			l.Topics = append(l.Topics, common.BigToHash(v))
+		default:
+			t.Fatalf("cannot index %T", v)
+		}
+	}
+	packed, err := ev.Inputs.NonIndexed().Pack(data...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Data = packed
+	return l
+}
+
+func newKey(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
@@ -0,0 +1,240 @@
+package indexer
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"blockchain.com/indexer/abiregistry"
+	"blockchain.com/indexer/contracts/marketplace"
+	"blockchain.com/indexer/contracts/nft"
+	"blockchain.com/indexer/model"
+	"blockchain.com/indexer/store"
+)
+
+// eventDecoder decodes a log whose topic0 matches its event into b.
+type eventDecoder struct {
+	name string
+	// topics is the number of topics of the event including topic0.
+	// ERC-20 Transfer and Approval share their signature with ERC-721 but
+	// index one argument less.
+	topics int
+	decode func(b *store.Batch, l types.Log) error
+}
+
+// decoder turns raw logs into store records. Events with a dedicated record
+// type are decoded with the generated bindings, any other event with the ABI
+// registry.
+type decoder struct {
+	chainID  uint64
+	registry *abiregistry.Registry
+	market   *marketplace.MainFilterer
+	nft      *nft.MainFilterer
+	events   map[common.Hash]eventDecoder
+
+	// transferID and createMarketSale are used to infer sales.
+	transferID       common.Hash
+	createMarketSale abi.Method
+}
+
+func newDecoder(chainID uint64, registry *abiregistry.Registry) (*decoder, error) {
+	market, err := marketplace.NewMainFilterer(common.Address{}, nil)
+	if err != nil {
+		return nil, err
+	}
+	token, err := nft.NewMainFilterer(common.Address{}, nil)
+	if err != nil {
+		return nil, err
+	}
+	marketABI, ok := registry.ABI(abiregistry.MarketABI)
+	if !ok {
+		return nil, fmt.Errorf("%w %q", abiregistry.ErrUnknownABI, abiregistry.MarketABI)
+	}
+	nftABI, ok := registry.ABI(abiregistry.NftABI)
+	if !ok {
+		return nil, fmt.Errorf("%w %q", abiregistry.ErrUnknownABI, abiregistry.NftABI)
+	}
+
+	d := &decoder{
+		chainID:          chainID,
+		registry:         registry,
+		market:           market,
+		nft:              token,
+		transferID:       nftABI.Events["Transfer"].ID,
+		createMarketSale: marketABI.Methods["createMarketSale"],
+	}
+	d.events = map[common.Hash]eventDecoder{
+		marketABI.Events["MarketItemCreated"].ID: {"MarketItemCreated", 4, d.marketItemCreated},
+		nftABI.Events["Transfer"].ID:             {"Transfer", 4, d.transfer},
+		nftABI.Events["Approval"].ID:             {"Approval", 4, d.approval},
+		nftABI.Events["ApprovalForAll"].ID:       {"ApprovalForAll", 3, d.approvalForAll},
+	}
+	return d, nil
+}
+
+// methodName returns the name of the contract method with the given
+// selector, or "" if no registered ABI declares it.
+func (d *decoder) methodName(to *common.Address, selector []byte) string {
+	m, _, err := d.registry.MethodByID(to, selector)
+	if err != nil {
+		return ""
+	}
+	return m.Name
+}
+
+// decode appends the record for l to b, dispatching on the event signature
+// in topic0. Events without a dedicated record type are decoded with the ABI
+// registry. Logs that still do not decode are kept as unknown logs instead of
+// failing the batch. Removed logs are ignored: their records are rolled back
+// when the reorganization is detected.
+func (d *decoder) decode(b *store.Batch, l types.Log) {
+	if l.Removed {
+		return
+	}
+	if len(l.Topics) == 0 {
+		d.unknown(b, l, "anonymous event")
+		return
+	}
+
+	ev, ok := d.events[l.Topics[0]]
+	if !ok {
+		d.generic(b, l)
+		return
+	}
+	if len(l.Topics) != ev.topics {
+		d.unknown(b, l, fmt.Sprintf("%v with %d topics, want %d", ev.name, len(l.Topics), ev.topics))
+		return
+	}
+	if err := ev.decode(b, l); err != nil {
+		log.Printf("indexer: cannot decode %v in %v/%d: %v", ev.name, l.TxHash.Hex(), l.Index, err)
+		d.unknown(b, l, fmt.Sprintf("decode %v: %v", ev.name, err))
+	}
+}
+
+func (d *decoder) generic(b *store.Batch, l types.Log) {
+	ev, err := d.registry.DecodeLog(l)
+	if err != nil {
+		d.unknown(b, l, err.Error())
+		return
+	}
+	args, err := ev.ArgsJSON()
+	if err != nil {
+		d.unknown(b, l, fmt.Sprintf("encode %v: %v", ev.Name, err))
+		return
+	}
+	b.ContractEvents = append(b.ContractEvents, model.ContractEvent{
+		ChainID:     d.chainID,
+		Contract:    l.Address.Hex(),
+		ABI:         ev.ABI,
+		Event:       ev.Name,
+		Signature:   ev.Signature,
+		Args:        string(args),
+		BlockNumber: l.BlockNumber,
+		BlockHash:   l.BlockHash.Hex(),
+		TxHash:      l.TxHash.Hex(),
+		LogIndex:    l.Index,
+	})
+}
+
+func (d *decoder) unknown(b *store.Batch, l types.Log, reason string) {
+	topics := make([]string, len(l.Topics))
+	for i, t := range l.Topics {
+		topics[i] = t.Hex()
+	}
+	b.UnknownLogs = append(b.UnknownLogs, model.UnknownLog{
+		ChainID:     d.chainID,
+		Contract:    l.Address.Hex(),
+		Topics:      strings.Join(topics, ","),
+		Data:        hexutil.Encode(l.Data),
+		Reason:      reason,
+		BlockNumber: l.BlockNumber,
+		BlockHash:   l.BlockHash.Hex(),
+		TxHash:      l.TxHash.Hex(),
+		LogIndex:    l.Index,
+	})
+}
+
+func (d *decoder) marketItemCreated(b *store.Batch, l types.Log) error {
+	ev, err := d.market.ParseMarketItemCreated(l)
+	if err != nil {
+		return err
+	}
+	b.MarketItems = append(b.MarketItems, model.MarketItem{
+		ChainID:     d.chainID,
+		Market:      l.Address.Hex(),
+		ItemID:      model.NewBigInt(ev.ItemId),
+		NftContract: ev.NftContract.Hex(),
+		TokenID:     model.NewBigInt(ev.TokenId),
+		Seller:      ev.Seller.Hex(),
+		Owner:       ev.Owner.Hex(),
+		Price:       model.NewBigInt(ev.Price),
+		Sold:        ev.Sold,
+		BlockNumber: l.BlockNumber,
+		BlockHash:   l.BlockHash.Hex(),
+		TxHash:      l.TxHash.Hex(),
+		LogIndex:    l.Index,
+	})
+	return nil
+}
+
+func (d *decoder) transfer(b *store.Batch, l types.Log) error {
+	ev, err := d.nft.ParseTransfer(l)
+	if err != nil {
+		return err
+	}
+	b.Transfers = append(b.Transfers, model.NFTTransfer{
+		ChainID:     d.chainID,
+		Contract:    l.Address.Hex(),
+		FromAddress: ev.From.Hex(),
+		ToAddress:   ev.To.Hex(),
+		TokenID:     model.NewBigInt(ev.TokenId),
+		BlockNumber: l.BlockNumber,
+		BlockHash:   l.BlockHash.Hex(),
+		TxHash:      l.TxHash.Hex(),
+		LogIndex:    l.Index,
+	})
+	return nil
+}
+
+func (d *decoder) approval(b *store.Batch, l types.Log) error {
+	ev, err := d.nft.ParseApproval(l)
+	if err != nil {
+		return err
+	}
+	b.Approvals = append(b.Approvals, model.NFTApproval{
+		ChainID:     d.chainID,
+		Contract:    l.Address.Hex(),
+		Owner:       ev.Owner.Hex(),
+		Approved:    ev.Approved.Hex(),
+		TokenID:     model.NewBigInt(ev.TokenId),
+		BlockNumber: l.BlockNumber,
+		BlockHash:   l.BlockHash.Hex(),
+		TxHash:      l.TxHash.Hex(),
+		LogIndex:    l.Index,
+	})
+	return nil
+}
+
+func (d *decoder) approvalForAll(b *store.Batch, l types.Log) error {
+	ev, err := d.nft.ParseApprovalForAll(l)
+	if err != nil {
+		return err
+	}
+	b.ApprovalsForAll = append(b.ApprovalsForAll, model.NFTApprovalForAll{
+		ChainID:     d.chainID,
+		Contract:    l.Address.Hex(),
+		Owner:       ev.Owner.Hex(),
+		Operator:    ev.Operator.Hex(),
+		Approved:    ev.Approved,
+		BlockNumber: l.BlockNumber,
+		BlockHash:   l.BlockHash.Hex(),
+		TxHash:      l.TxHash.Hex(),
+		LogIndex:    l.Index,
+	})
+	return nil
+}
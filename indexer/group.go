@@ -0,0 +1,231 @@
+package indexer
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"blockchain.com/indexer/store"
+)
+
+// group is a set of contracts that are synced together.
+type group struct {
+	addresses []common.Address
+	start     map[common.Address]uint64
+
+	// next is the first block not yet indexed for each contract.
+	next map[common.Address]uint64
+
+	progress progressTracker
+}
+
+func newGroup() *group {
+	return &group{
+		start: make(map[common.Address]uint64),
+		next:  make(map[common.Address]uint64),
+	}
+}
+
+func (g *group) add(c Contract, next uint64) {
+	if _, ok := g.next[c.Address]; !ok {
+		g.addresses = append(g.addresses, c.Address)
+	}
+	g.start[c.Address] = c.StartBlock
+	g.next[c.Address] = next
+}
+
+func (g *group) remove(addr common.Address) {
+	if _, ok := g.next[addr]; !ok {
+		return
+	}
+	delete(g.start, addr)
+	delete(g.next, addr)
+	for i, a := range g.addresses {
+		if a == addr {
+			g.addresses = append(g.addresses[:i], g.addresses[i+1:]...)
+			break
+		}
+	}
+}
+
+// cursor returns the first block not yet indexed for at least one contract.
+// It reports false if the group is empty.
+func (g *group) cursor() (uint64, bool) {
+	if len(g.addresses) == 0 {
+		return 0, false
+	}
+	min := g.next[g.addresses[0]]
+	for _, n := range g.next {
+		if n < min {
+			min = n
+		}
+	}
+	return min, true
+}
+
+// advance marks every block up to and including to as indexed.
+func (g *group) advance(to uint64) {
+	for _, addr := range g.addresses {
+		if g.next[addr] <= to {
+			g.next[addr] = to + 1
+		}
+	}
+}
+
+// skip returns a copy of next for the fetchers of a backfill.
+func (g *group) skip() map[common.Address]uint64 {
+	skip := make(map[common.Address]uint64, len(g.next))
+	for addr, n := range g.next {
+		skip[addr] = n
+	}
+	return skip
+}
+
+// catchUp is a contract being backfilled up to target by its own goroutine.
+type catchUp struct {
+	group  *group
+	target uint64
+	cancel context.CancelFunc
+}
+
+// join is sent by a catch-up goroutine that finished its backfill.
+type join struct {
+	contract Contract
+	catchUp  *catchUp
+}
+
+// applyChanges adds and removes the contracts queued by AddContract and
+// RemoveContract.
+func (ix *Indexer) applyChanges(ctx context.Context, head uint64) error {
+	ix.mu.Lock()
+	changes := ix.changes
+	ix.changes = nil
+	ix.mu.Unlock()
+
+	for i, c := range changes {
+		if c.add == nil {
+			ix.live.remove(c.remove)
+			ix.mu.Lock()
+			if cu, ok := ix.catchUps[c.remove]; ok {
+				cu.cancel()
+				delete(ix.catchUps, c.remove)
+			}
+			ix.mu.Unlock()
+			log.Printf("indexer: stopped indexing %v", c.remove.Hex())
+			continue
+		}
+
+		if err := ix.admit(ctx, *c.add, head); err != nil {
+			// Keep the remaining changes for the next attempt.
+			ix.mu.Lock()
+			ix.changes = append(changes[i:], ix.changes...)
+			ix.mu.Unlock()
+			return err
+		}
+	}
+	return nil
+}
+
+// admit starts indexing c. A contract that is close to the followed
+// contracts joins them directly; one that is further behind is backfilled by
+// its own goroutine so the others keep following the head meanwhile.
+func (ix *Indexer) admit(ctx context.Context, c Contract, head uint64) error {
+	next := c.StartBlock
+	cp, err := ix.store.Checkpoint(ctx, ix.cfg.ChainID, c.Address.Hex())
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		return err
+	}
+	if err == nil && cp.BlockNumber+1 > next {
+		next = cp.BlockNumber + 1
+		log.Printf("indexer: resuming %v from block %d", c.Address.Hex(), next)
+	}
+
+	// The catch-up stops ReorgWindow blocks below the head so reorganizations
+	// only ever touch blocks indexed by the followed group.
+	cursor, ok := ix.live.cursor()
+	if !ok || head < uint64(ix.cfg.ReorgWindow) {
+		ix.live.add(c, next)
+		return nil
+	}
+	target := cursor - 1
+	if safe := head - uint64(ix.cfg.ReorgWindow); safe < target {
+		target = safe
+	}
+	if next+ix.cfg.ChunkSize > target {
+		ix.live.add(c, next)
+		return nil
+	}
+
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	if _, ok := ix.catchUps[c.Address]; ok {
+		return nil
+	}
+	g := newGroup()
+	g.add(c, next)
+	cctx, cancel := context.WithCancel(ctx)
+	cu := &catchUp{group: g, target: target, cancel: cancel}
+	ix.catchUps[c.Address] = cu
+
+	log.Printf("indexer: backfilling %v from block %d to %d", c.Address.Hex(), next, target)
+	go ix.runCatchUp(cctx, c, cu)
+	return nil
+}
+
+// runCatchUp backfills a contract up to its target and then hands it over
+// to the followed group.
+func (ix *Indexer) runCatchUp(ctx context.Context, c Contract, cu *catchUp) {
+	for {
+		from, _ := cu.group.cursor()
+		err := ix.backfill(ctx, cu.group, from, cu.target)
+		if err == nil {
+			break
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("indexer: backfilling %v failed: %v, retrying in %v", c.Address.Hex(), err, ix.cfg.RetryDelay)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(ix.cfg.RetryDelay):
+		}
+	}
+
+	select {
+	case ix.joins <- join{contract: c, catchUp: cu}:
+	case <-ctx.Done():
+	}
+}
+
+// join moves a contract that finished catching up into the followed group.
+func (ix *Indexer) join(j join) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	// The contract was removed while catching up.
+	if ix.catchUps[j.contract.Address] != j.catchUp {
+		return
+	}
+	delete(ix.catchUps, j.contract.Address)
+
+	next := j.catchUp.target + 1
+	ix.live.add(j.contract, next)
+	log.Printf("indexer: %v caught up, following from block %d", j.contract.Address.Hex(), next)
+}
+
+// ContractProgress returns the progress of the contracts being backfilled
+// separately from the followed ones.
+func (ix *Indexer) ContractProgress() map[common.Address]Progress {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	progress := make(map[common.Address]Progress, len(ix.catchUps))
+	for addr, cu := range ix.catchUps {
+		progress[addr] = cu.group.progress.get()
+	}
+	return progress
+}
@@ -0,0 +1,425 @@
+package indexer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/event"
+
+	"blockchain.com/indexer/abiregistry"
+	"blockchain.com/indexer/model"
+	"blockchain.com/indexer/store"
+)
+
+// Backend is the subset of ethclient.Client the indexer needs.
+type Backend interface {
+	FilterLogs(ctx context.Context, q ethereum.FilterQuery) ([]types.Log, error)
+	HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error)
+	SubscribeNewHead(ctx context.Context, ch chan<- *types.Header) (ethereum.Subscription, error)
+	TransactionByHash(ctx context.Context, hash common.Hash) (*types.Transaction, bool, error)
+	TransactionReceipt(ctx context.Context, hash common.Hash) (*types.Receipt, error)
+}
+
+// Contract is a contract whose events are indexed.
+type Contract struct {
+	Address    common.Address
+	StartBlock uint64
+}
+
+type Config struct {
+	ChainID   uint64
+	Contracts []Contract
+
+	// ChunkSize is the initial number of blocks covered by one FilterLogs
+	// call. It adapts between MinChunkSize and MaxChunkSize to keep the
+	// number of logs per call near TargetLogs.
+	ChunkSize    uint64
+	MinChunkSize uint64
+	MaxChunkSize uint64
+	TargetLogs   int
+
+	// Concurrency is the number of block ranges fetched in parallel.
+	Concurrency int
+
+	// ReorgWindow is the number of recent blocks kept to find the common
+	// ancestor of a reorganization. Deeper reorganizations stop the indexer.
+	ReorgWindow int
+
+	// Confirmations is the number of blocks a block must be buried under
+	// before it is indexed. It trades latency for fewer rollbacks.
+	Confirmations uint64
+
+	// RetryDelay is how long to wait before resubscribing after the head
+	// subscription fails.
+	RetryDelay time.Duration
+}
+
+// ChainEvent is sent after the records of a block range have been committed.
+// Ranges of contracts that are catching up are sent as they are committed,
+// so events are only ordered per contract.
+type ChainEvent struct {
+	Batch *store.Batch
+}
+
+// RollbackEvent is sent after the records of orphaned blocks have been
+// removed from the store.
+type RollbackEvent struct {
+	Ancestor model.Block
+	Removed  *store.Batch
+}
+
+var errReorgTooDeep = errors.New("indexer: reorganization deeper than the reorg window")
+
+// errChainChanged is returned when the chain changed while a block range was
+// being fetched. The range is fetched again after the next reorg check.
+var errChainChanged = errors.New("indexer: chain changed during fetch")
+
+// Indexer walks the chain forward from the contracts' start block and then
+// follows new heads, committing the decoded events of every block range to
+// the store. Reorganizations are detected by comparing the recent blocks it
+// indexed with the canonical chain; records of orphaned blocks are rolled
+// back and the canonical branch is indexed again.
+//
+// Contracts that are far behind the others, such as contracts added while
+// the indexer runs, are backfilled by their own goroutine and join the
+// followed contracts once they caught up.
+type Indexer struct {
+	backend Backend
+	store   store.Store
+	cfg     Config
+	decoder *decoder
+	sizer   *chunkSizer
+
+	// live holds the contracts following the head. It and recent are only
+	// used by the goroutine running Run.
+	live *group
+
+	// recent holds the last indexed blocks in ascending order.
+	recent []model.Block
+
+	mu       sync.Mutex
+	changes  []change
+	catchUps map[common.Address]*catchUp
+	wake     chan struct{}
+	joins    chan join
+
+	chainFeed    event.Feed
+	rollbackFeed event.Feed
+}
+
+// change adds or removes a contract while the indexer runs.
+type change struct {
+	add    *Contract
+	remove common.Address
+}
+
+func New(backend Backend, s store.Store, registry *abiregistry.Registry, cfg Config) (*Indexer, error) {
+	if cfg.ChunkSize == 0 {
+		cfg.ChunkSize = 2000
+	}
+	if cfg.MinChunkSize == 0 {
+		cfg.MinChunkSize = 1
+	}
+	if cfg.MaxChunkSize == 0 {
+		cfg.MaxChunkSize = 50000
+	}
+	if cfg.TargetLogs == 0 {
+		cfg.TargetLogs = 2000
+	}
+	if cfg.Concurrency == 0 {
+		cfg.Concurrency = 4
+	}
+	if cfg.ReorgWindow == 0 {
+		cfg.ReorgWindow = 128
+	}
+	if cfg.RetryDelay == 0 {
+		cfg.RetryDelay = 5 * time.Second
+	}
+
+	dec, err := newDecoder(cfg.ChainID, registry)
+	if err != nil {
+		return nil, err
+	}
+
+	ix := &Indexer{
+		backend:  backend,
+		store:    s,
+		cfg:      cfg,
+		decoder:  dec,
+		sizer:    newChunkSizer(cfg),
+		live:     newGroup(),
+		catchUps: make(map[common.Address]*catchUp),
+		wake:     make(chan struct{}, 1),
+		joins:    make(chan join),
+	}
+	for _, c := range cfg.Contracts {
+		c := c
+		ix.changes = append(ix.changes, change{add: &c})
+	}
+	return ix, nil
+}
+
+// AddContract starts indexing a contract from its start block, or from its
+// checkpoint if it was indexed before.
+func (ix *Indexer) AddContract(c Contract) {
+	ix.enqueue(change{add: &c})
+}
+
+// RemoveContract stops indexing a contract. Its records and checkpoint are
+// kept.
+func (ix *Indexer) RemoveContract(addr common.Address) {
+	ix.enqueue(change{remove: addr})
+}
+
+func (ix *Indexer) enqueue(c change) {
+	ix.mu.Lock()
+	ix.changes = append(ix.changes, c)
+	ix.mu.Unlock()
+
+	select {
+	case ix.wake <- struct{}{}:
+	default:
+	}
+}
+
+// SubscribeChainEvents registers ch to receive a ChainEvent for every
+// committed block range.
+func (ix *Indexer) SubscribeChainEvents(ch chan<- ChainEvent) event.Subscription {
+	return ix.chainFeed.Subscribe(ch)
+}
+
+// SubscribeRollbackEvents registers ch to receive a RollbackEvent for every
+// reorganization. Subscribers must undo whatever they derived from the
+// removed records.
+func (ix *Indexer) SubscribeRollbackEvents(ch chan<- RollbackEvent) event.Subscription {
+	return ix.rollbackFeed.Subscribe(ch)
+}
+
+// Run indexes until ctx is cancelled or the backend returns an error.
+func (ix *Indexer) Run(ctx context.Context) error {
+	recent, err := ix.store.RecentBlocks(ctx, ix.cfg.ChainID, ix.cfg.ReorgWindow)
+	if err != nil {
+		return err
+	}
+	ix.recent = recent
+
+	for {
+		err := ix.follow(ctx)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if errors.Is(err, errReorgTooDeep) {
+			return err
+		}
+		log.Printf("indexer: following heads failed: %v, retrying in %v", err, ix.cfg.RetryDelay)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(ix.cfg.RetryDelay):
+		}
+	}
+}
+
+// follow catches up with the current head and then indexes up to every new
+// head until the subscription fails.
+func (ix *Indexer) follow(ctx context.Context) error {
+	heads := make(chan *types.Header, 16)
+	sub, err := ix.backend.SubscribeNewHead(ctx, heads)
+	if err != nil {
+		return err
+	}
+	defer sub.Unsubscribe()
+
+	h, err := ix.backend.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return err
+	}
+	head := ix.confirmed(h)
+	if err := ix.applyChanges(ctx, head); err != nil {
+		return err
+	}
+	if err := ix.syncTo(ctx, head); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-sub.Err():
+			return err
+		case h := <-heads:
+			head = ix.confirmed(h)
+		case <-ix.wake:
+			if err := ix.applyChanges(ctx, head); err != nil {
+				return err
+			}
+		case j := <-ix.joins:
+			ix.join(j)
+		}
+		if err := ix.syncTo(ctx, head); err != nil {
+			return err
+		}
+	}
+}
+
+// confirmed returns the last block with enough confirmations when h is the
+// chain head.
+func (ix *Indexer) confirmed(h *types.Header) uint64 {
+	n := h.Number.Uint64()
+	if n < ix.cfg.Confirmations {
+		return 0
+	}
+	return n - ix.cfg.Confirmations
+}
+
+// syncTo indexes every block of the followed contracts up to and including
+// head, rolling back first if the chain was reorganized.
+func (ix *Indexer) syncTo(ctx context.Context, head uint64) error {
+	if err := ix.checkReorg(ctx); err != nil {
+		return err
+	}
+	if from, ok := ix.live.cursor(); ok && from <= head {
+		return ix.backfill(ctx, ix.live, from, head)
+	}
+	return nil
+}
+
+// fetch decodes the logs of g's contracts in blocks from through to. Logs of
+// a contract below its entry in skip are already indexed and left out.
+func (ix *Indexer) fetch(ctx context.Context, g *group, from, to uint64, skip map[common.Address]uint64) (*store.Batch, int, error) {
+	logs, err := ix.filterLogs(ctx, g.addresses, nil, from, to)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	b := &store.Batch{FromBlock: from, ToBlock: to}
+	headers := make(map[uint64]*types.Header)
+	for _, l := range logs {
+		if next, ok := skip[l.Address]; !ok || l.BlockNumber < next {
+			continue
+		}
+
+		h, err := ix.header(ctx, headers, l.BlockNumber)
+		if err != nil {
+			return nil, 0, err
+		}
+		if h.Hash() != l.BlockHash {
+			return nil, 0, errChainChanged
+		}
+		ix.decoder.decode(b, l)
+	}
+
+	sales, err := ix.sales(ctx, g, from, to, skip, b, headers)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if err := ix.transactions(ctx, b, headers); err != nil {
+		return nil, 0, err
+	}
+	if _, err := ix.header(ctx, headers, to); err != nil {
+		return nil, 0, err
+	}
+	for _, h := range headers {
+		b.Blocks = append(b.Blocks, model.Block{
+			ChainID:    ix.cfg.ChainID,
+			Number:     h.Number.Uint64(),
+			Hash:       h.Hash().Hex(),
+			ParentHash: h.ParentHash.Hex(),
+			Time:       time.Unix(int64(h.Time), 0).UTC(),
+		})
+	}
+	sortBlocks(b.Blocks)
+	return b, len(logs) + sales, nil
+}
+
+// filterLogs returns the logs of addresses matching topics in blocks from
+// through to. Ranges the node rejects as too large are bisected.
+func (ix *Indexer) filterLogs(ctx context.Context, addresses []common.Address, topics [][]common.Hash, from, to uint64) ([]types.Log, error) {
+	logs, err := ix.backend.FilterLogs(ctx, ethereum.FilterQuery{
+		FromBlock: new(big.Int).SetUint64(from),
+		ToBlock:   new(big.Int).SetUint64(to),
+		Addresses: addresses,
+		Topics:    topics,
+	})
+	if err == nil || !isRangeTooLarge(err) || from == to {
+		return logs, err
+	}
+
+	ix.sizer.shrink()
+	mid := from + (to-from)/2
+	left, err := ix.filterLogs(ctx, addresses, topics, from, mid)
+	if err != nil {
+		return nil, err
+	}
+	right, err := ix.filterLogs(ctx, addresses, topics, mid+1, to)
+	if err != nil {
+		return nil, err
+	}
+	return append(left, right...), nil
+}
+
+// commit writes a batch fetched for g together with the checkpoints of the
+// contracts it advances.
+func (ix *Indexer) commit(ctx context.Context, g *group, b *store.Batch) error {
+	live := g == ix.live
+
+	// The range must extend the indexed chain; otherwise a reorganization
+	// happened after the reorg check.
+	if n := len(ix.recent); live && n > 0 && b.Blocks[0].Number == ix.recent[n-1].Number+1 {
+		if b.Blocks[0].ParentHash != ix.recent[n-1].Hash {
+			return errChainChanged
+		}
+	}
+
+	last := b.Blocks[len(b.Blocks)-1]
+	for _, addr := range g.addresses {
+		if g.next[addr] > b.ToBlock {
+			continue
+		}
+		b.Checkpoints = append(b.Checkpoints, model.Checkpoint{
+			ChainID:     ix.cfg.ChainID,
+			Contract:    addr.Hex(),
+			BlockNumber: last.Number,
+			BlockHash:   last.Hash,
+		})
+	}
+
+	if err := ix.store.Commit(ctx, b); err != nil {
+		return err
+	}
+
+	g.advance(b.ToBlock)
+	if live {
+		ix.remember(b.Blocks)
+	}
+	ix.chainFeed.Send(ChainEvent{Batch: b})
+	return nil
+}
+
+// header returns the header of block number, fetching it at most once per
+// batch.
+func (ix *Indexer) header(ctx context.Context, cache map[uint64]*types.Header, number uint64) (*types.Header, error) {
+	if h, ok := cache[number]; ok {
+		return h, nil
+	}
+	h, err := ix.backend.HeaderByNumber(ctx, new(big.Int).SetUint64(number))
+	if err == nil && h == nil {
+		err = ethereum.NotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("header %d: %w", number, err)
+	}
+	cache[number] = h
+	return h, nil
+}
@@ -0,0 +1,92 @@
+package indexer
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math/big"
+	"sort"
+
+	"github.com/ethereum/go-ethereum"
+
+	"blockchain.com/indexer/model"
+)
+
+// checkReorg compares the most recently indexed block with the canonical
+// chain and rolls back to the common ancestor if they diverged.
+func (ix *Indexer) checkReorg(ctx context.Context) error {
+	if len(ix.recent) == 0 {
+		return nil
+	}
+
+	tip := ix.recent[len(ix.recent)-1]
+	canonical, err := ix.canonical(ctx, tip)
+	if err != nil || canonical {
+		return err
+	}
+
+	for i := len(ix.recent) - 2; i >= 0; i-- {
+		canonical, err := ix.canonical(ctx, ix.recent[i])
+		if err != nil {
+			return err
+		}
+		if canonical {
+			return ix.rollback(ctx, ix.recent[i])
+		}
+	}
+	return errReorgTooDeep
+}
+
+// canonical reports whether blk is still part of the canonical chain.
+func (ix *Indexer) canonical(ctx context.Context, blk model.Block) (bool, error) {
+	h, err := ix.backend.HeaderByNumber(ctx, new(big.Int).SetUint64(blk.Number))
+	if errors.Is(err, ethereum.NotFound) || (err == nil && h == nil) {
+		// The canonical chain is shorter than the indexed one.
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return h.Hash().Hex() == blk.Hash, nil
+}
+
+// rollback removes every record above ancestor and rewinds the contracts so
+// the canonical branch is indexed again.
+func (ix *Indexer) rollback(ctx context.Context, ancestor model.Block) error {
+	removed, err := ix.store.Rollback(ctx, ix.cfg.ChainID, ancestor)
+	if err != nil {
+		return err
+	}
+
+	g := ix.live
+	for _, addr := range g.addresses {
+		next := ancestor.Number + 1
+		if next < g.start[addr] {
+			next = g.start[addr]
+		}
+		if g.next[addr] > next {
+			g.next[addr] = next
+		}
+	}
+
+	i := sort.Search(len(ix.recent), func(i int) bool { return ix.recent[i].Number > ancestor.Number })
+	ix.recent = ix.recent[:i]
+
+	ix.rollbackFeed.Send(RollbackEvent{Ancestor: ancestor, Removed: removed})
+
+	log.Printf("indexer: reorganization, rolled back to block %d (%d market items, %d sales, %d transfers, %d approvals removed)",
+		ancestor.Number, len(removed.MarketItems), len(removed.Sales), len(removed.Transfers), len(removed.Approvals))
+	return nil
+}
+
+// remember appends indexed blocks to the reorg window.
+func (ix *Indexer) remember(blocks []model.Block) {
+	ix.recent = append(ix.recent, blocks...)
+	if n := len(ix.recent) - ix.cfg.ReorgWindow; n > 0 {
+		ix.recent = append(ix.recent[:0], ix.recent[n:]...)
+	}
+}
+
+func sortBlocks(blocks []model.Block) {
+	sort.Slice(blocks, func(i, j int) bool { return blocks[i].Number < blocks[j].Number })
+}
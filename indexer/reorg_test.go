@@ -0,0 +1,305 @@
+package indexer
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"blockchain.com/indexer/abiregistry"
+	"blockchain.com/indexer/model"
+	"blockchain.com/indexer/store"
+	"blockchain.com/indexer/store/memory"
+)
+
+// fixture is an indexer following a market and an NFT contract on a chain.
+type fixture struct {
+	chain   *chain
+	store   *memory.Store
+	ix      *Indexer
+	market  common.Address
+	nft     common.Address
+	marketA *abi.ABI
+	nftA    *abi.ABI
+	seller  *ecdsa.PrivateKey
+	buyer   *ecdsa.PrivateKey
+}
+
+func newFixture(t *testing.T, cfg Config) *fixture {
+	t.Helper()
+	registry, err := abiregistry.Default()
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fixture{
+		chain:  newChain(),
+		store:  memory.New(),
+		market: common.HexToAddress("0x00000000000000000000000000000000000000aa"),
+		nft:    common.HexToAddress("0x00000000000000000000000000000000000000bb"),
+		seller: newKey(t),
+		buyer:  newKey(t),
+	}
+	f.marketA, _ = registry.ABI(abiregistry.MarketABI)
+	f.nftA, _ = registry.ABI(abiregistry.NftABI)
+	if err := registry.Bind(f.market, abiregistry.MarketABI); err != nil {
+		t.Fatal(err)
+	}
+	if err := registry.Bind(f.nft, abiregistry.NftABI); err != nil {
+		t.Fatal(err)
+	}
+	cfg.ChainID = testChainID
+	cfg.Contracts = []Contract{{Address: f.market, StartBlock: 1}, {Address: f.nft, StartBlock: 1}}
+	f.ix, err = New(f.chain, f.store, registry, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func (f *fixture) address(key *ecdsa.PrivateKey) common.Address {
+	return crypto.PubkeyToAddress(key.PublicKey)
+}
+
+// mint queues the mint of token to key.
+func (f *fixture) mint(t *testing.T, key *ecdsa.PrivateKey, token int64) {
+	f.chain.send(t, key, f.nft, nil, nil,
+		eventLog(t, f.nftA, "Transfer", f.nft, common.Address{}, f.address(key), big.NewInt(token)))
+}
+
+// list queues the listing of token by key as item at price.
+func (f *fixture) list(t *testing.T, key *ecdsa.PrivateKey, item, token, price int64) {
+	seller := f.address(key)
+	f.chain.send(t, key, f.market, nil, nil,
+		eventLog(t, f.nftA, "Transfer", f.nft, seller, f.market, big.NewInt(token)),
+		eventLog(t, f.marketA, "MarketItemCreated", f.market, big.NewInt(item), f.nft, big.NewInt(token), seller, common.Address{}, big.NewInt(price), false))
+}
+
+// buy queues the purchase of item, holding token, by key for price.
+func (f *fixture) buy(t *testing.T, key *ecdsa.PrivateKey, item, token, price int64) {
+	data, err := f.marketA.Pack("createMarketSale", f.nft, big.NewInt(item))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.chain.send(t, key, f.market, big.NewInt(price), data,
+		eventLog(t, f.nftA, "Transfer", f.nft, f.market, f.address(key), big.NewInt(token)))
+}
+
+// sync indexes up to the head of the chain.
+func (f *fixture) sync(t *testing.T) {
+	t.Helper()
+	ctx := context.Background()
+	head := uint64(len(f.chain.headers) - 1)
+	if err := f.ix.applyChanges(ctx, head); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.ix.syncTo(ctx, head); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func (f *fixture) owner(t *testing.T, token int64) string {
+	t.Helper()
+	o, err := f.store.TokenOwner(context.Background(), testChainID, f.nft.Hex(), big.NewInt(token))
+	if errors.Is(err, store.ErrNotFound) {
+		return ""
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return o.Owner
+}
+
+func (f *fixture) checkpoint(t *testing.T, contract common.Address) model.Checkpoint {
+	t.Helper()
+	cp, err := f.store.Checkpoint(context.Background(), testChainID, contract.Hex())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return *cp
+}
+
+func TestReorg(t *testing.T) {
+	ctx := context.Background()
+	f := newFixture(t, Config{})
+	seller, buyer := f.address(f.seller).Hex(), f.address(f.buyer).Hex()
+
+	f.mint(t, f.seller, 1)
+	f.mint(t, f.seller, 2)
+	f.chain.mine()
+	f.list(t, f.seller, 1, 1, 100)
+	ancestor := f.chain.mine()
+	f.buy(t, f.buyer, 1, 1, 100)
+	f.chain.mine()
+	f.list(t, f.seller, 2, 2, 300)
+	f.chain.mine()
+	f.sync(t)
+
+	if _, err := f.store.Sale(ctx, testChainID, f.market.Hex(), big.NewInt(1)); err != nil {
+		t.Fatalf("sale of item 1 before the fork: %v", err)
+	}
+	if got := f.owner(t, 1); got != buyer {
+		t.Fatalf("owner of token 1 before the fork = %s, want the buyer %s", got, buyer)
+	}
+
+	// Blocks 3 and 4 are replaced by a branch where the seller sends token 2
+	// to the buyer instead.
+	f.chain.fork(2)
+	f.chain.mine()
+	f.chain.send(t, f.seller, f.nft, nil, nil,
+		eventLog(t, f.nftA, "Transfer", f.nft, f.address(f.seller), f.address(f.buyer), big.NewInt(2)))
+	f.chain.mine()
+	head := f.chain.mine()
+
+	removed := make(chan RollbackEvent, 1)
+	sub := f.ix.SubscribeRollbackEvents(removed)
+	defer sub.Unsubscribe()
+	if err := f.ix.checkReorg(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	ev := <-removed
+	if ev.Ancestor.Number != 2 || ev.Ancestor.Hash != ancestor.Hash().Hex() {
+		t.Fatalf("rolled back to block %d %s, want 2 %s", ev.Ancestor.Number, ev.Ancestor.Hash, ancestor.Hash().Hex())
+	}
+	if len(ev.Removed.Sales) != 1 || len(ev.Removed.MarketItems) != 1 || len(ev.Removed.Transfers) != 2 {
+		t.Fatalf("removed %d sales, %d market items and %d transfers, want 1, 1 and 2",
+			len(ev.Removed.Sales), len(ev.Removed.MarketItems), len(ev.Removed.Transfers))
+	}
+	if _, err := f.store.Sale(ctx, testChainID, f.market.Hex(), big.NewInt(1)); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("sale of item 1 after the rollback: %v, want not found", err)
+	}
+	if _, err := f.store.MarketItem(ctx, testChainID, f.market.Hex(), big.NewInt(2)); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("item 2 after the rollback: %v, want not found", err)
+	}
+	it, err := f.store.MarketItem(ctx, testChainID, f.market.Hex(), big.NewInt(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if it.Sold || it.Owner != store.UnsoldOwner {
+		t.Fatalf("item 1 after the rollback: sold %v to %s, want unsold", it.Sold, it.Owner)
+	}
+	if got := f.owner(t, 1); got != f.market.Hex() {
+		t.Fatalf("owner of token 1 after the rollback = %s, want the market", got)
+	}
+	if got := f.owner(t, 2); got != seller {
+		t.Fatalf("owner of token 2 after the rollback = %s, want the seller %s", got, seller)
+	}
+	for _, c := range []common.Address{f.market, f.nft} {
+		if cp := f.checkpoint(t, c); cp.BlockNumber != 2 || cp.BlockHash != ancestor.Hash().Hex() {
+			t.Fatalf("checkpoint of %s after the rollback at block %d %s, want 2 %s", c.Hex(), cp.BlockNumber, cp.BlockHash, ancestor.Hash().Hex())
+		}
+	}
+	if _, err := f.store.Block(ctx, testChainID, 3); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("orphaned block 3 after the rollback: %v, want not found", err)
+	}
+
+	// The canonical branch is indexed again.
+	f.sync(t)
+
+	if got := f.owner(t, 2); got != buyer {
+		t.Fatalf("owner of token 2 on the new branch = %s, want the buyer %s", got, buyer)
+	}
+	if got := f.owner(t, 1); got != f.market.Hex() {
+		t.Fatalf("owner of token 1 on the new branch = %s, want the market", got)
+	}
+	items, err := f.store.MarketItems(ctx, store.MarketItemQuery{ChainID: testChainID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 || items[0].Sold {
+		t.Fatalf("market items on the new branch = %+v, want item 1 unsold", items)
+	}
+	if vol, err := f.store.TotalVolume(ctx, testChainID); err != nil || vol.Int64() != 100 {
+		t.Fatalf("total volume on the new branch = %v, %v, want 100", vol, err)
+	}
+	for _, c := range []common.Address{f.market, f.nft} {
+		if cp := f.checkpoint(t, c); cp.BlockNumber != 5 || cp.BlockHash != head.Hash().Hex() {
+			t.Fatalf("checkpoint of %s on the new branch at block %d %s, want 5 %s", c.Hex(), cp.BlockNumber, cp.BlockHash, head.Hash().Hex())
+		}
+	}
+	blk, err := f.store.Block(ctx, testChainID, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if blk.Hash != f.chain.headers[4].Hash().Hex() {
+		t.Fatalf("block 4 = %s, want the new branch %s", blk.Hash, f.chain.headers[4].Hash().Hex())
+	}
+}
+
+func TestReorgTooDeep(t *testing.T) {
+	f := newFixture(t, Config{ReorgWindow: 2})
+	for i := 0; i < 4; i++ {
+		f.mint(t, f.seller, int64(i))
+		f.chain.mine()
+	}
+	f.sync(t)
+
+	f.chain.fork(0)
+	for i := 0; i < 5; i++ {
+		f.chain.mine()
+	}
+	if err := f.ix.checkReorg(context.Background()); !errors.Is(err, errReorgTooDeep) {
+		t.Fatalf("checkReorg = %v, want %v", err, errReorgTooDeep)
+	}
+}
+
+func TestReorgMint(t *testing.T) {
+	ctx := context.Background()
+	f := newFixture(t, Config{})
+	// queue queues the metadata of the minted tokens, as the metadata
+	// worker does.
+	queue := func(tokens ...int64) {
+		t.Helper()
+		var ms []model.TokenMetadata
+		for _, id := range tokens {
+			ms = append(ms, model.TokenMetadata{ChainID: testChainID, Contract: f.nft.Hex(), TokenID: model.NewBigInt(big.NewInt(id)), Status: model.MetadataResolved})
+		}
+		if err := f.store.QueueMetadata(ctx, ms); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	f.mint(t, f.seller, 1)
+	f.chain.mine()
+	f.sync(t)
+	queue(1)
+
+	// Token 3 is minted and token 1 moves only on the orphaned branch.
+	f.mint(t, f.seller, 3)
+	f.chain.send(t, f.seller, f.nft, nil, nil,
+		eventLog(t, f.nftA, "Transfer", f.nft, f.address(f.seller), f.address(f.buyer), big.NewInt(1)))
+	f.chain.mine()
+	f.sync(t)
+	queue(3)
+
+	f.chain.fork(1)
+	f.mint(t, f.buyer, 2)
+	f.chain.mine()
+	f.chain.mine()
+	if err := f.ix.checkReorg(ctx); err != nil {
+		t.Fatal(err)
+	}
+	f.sync(t)
+	queue(2)
+
+	for _, tt := range []struct {
+		token  int64
+		exists bool
+	}{{1, true}, {2, true}, {3, false}} {
+		_, err := f.store.TokenMetadata(ctx, testChainID, f.nft.Hex(), big.NewInt(tt.token))
+		switch {
+		case tt.exists && err != nil:
+			t.Errorf("metadata of token %d: %v", tt.token, err)
+		case !tt.exists && !errors.Is(err, store.ErrNotFound):
+			t.Errorf("metadata of token %d minted on the orphaned branch: %v, want not found", tt.token, err)
+		}
+	}
+	if got := f.owner(t, 3); got != "" {
+		t.Errorf("owner of token 3 = %s, want none", got)
+	}
+}
@@ -0,0 +1,99 @@
+package indexer
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"blockchain.com/indexer/abiregistry"
+	"blockchain.com/indexer/model"
+	"blockchain.com/indexer/store"
+)
+
+// sales infers the sales of the markets of g in blocks from through to and
+// returns the number of logs it looked at.
+//
+// NFTMarket emits no event in createMarketSale, but the sale transfers the
+// token from the market to the buyer. Those Transfer logs are fetched from
+// any token contract, not only indexed ones, since items can be listed for
+// any ERC-721.
+func (ix *Indexer) sales(ctx context.Context, g *group, from, to uint64, skip map[common.Address]uint64, b *store.Batch, headers map[uint64]*types.Header) (int, error) {
+	var markets []common.Hash
+	for _, addr := range g.addresses {
+		if _, name, ok := ix.decoder.registry.ContractABI(addr); ok && name == abiregistry.MarketABI {
+			markets = append(markets, common.BytesToHash(addr.Bytes()))
+		}
+	}
+	if len(markets) == 0 {
+		return 0, nil
+	}
+
+	logs, err := ix.filterLogs(ctx, nil, [][]common.Hash{{ix.decoder.transferID}, markets}, from, to)
+	if err != nil {
+		return 0, err
+	}
+	for _, l := range logs {
+		// ERC-20 Transfer shares the signature but has no indexed token id.
+		if l.Removed || len(l.Topics) != 4 {
+			continue
+		}
+		market := common.BytesToAddress(l.Topics[1].Bytes())
+		if next, ok := skip[market]; !ok || l.BlockNumber < next {
+			continue
+		}
+
+		h, err := ix.header(ctx, headers, l.BlockNumber)
+		if err != nil {
+			return 0, err
+		}
+		if h.Hash() != l.BlockHash {
+			return 0, errChainChanged
+		}
+		tx, _, err := ix.backend.TransactionByHash(ctx, l.TxHash)
+		if err != nil {
+			return 0, fmt.Errorf("transaction %v: %w", l.TxHash.Hex(), err)
+		}
+		ix.decoder.sale(b, l, market, tx)
+	}
+	return len(logs), nil
+}
+
+// sale appends the sale of the token transferred out of market by l in tx.
+// Transfers out of the market that are not a direct createMarketSale call for
+// the token are kept as unknown logs.
+func (d *decoder) sale(b *store.Batch, l types.Log, market common.Address, tx *types.Transaction) {
+	data := tx.Data()
+	if tx.To() == nil || *tx.To() != market || len(data) < 4 || !bytes.Equal(data[:4], d.createMarketSale.ID) {
+		d.unknown(b, l, "transfer out of the market outside of a createMarketSale call")
+		return
+	}
+	args, err := d.createMarketSale.Inputs.Unpack(data[4:])
+	if err != nil {
+		d.unknown(b, l, fmt.Sprintf("decode createMarketSale: %v", err))
+		return
+	}
+	nftContract, _ := args[0].(common.Address)
+	itemID, _ := args[1].(*big.Int)
+	if nftContract != l.Address || itemID == nil {
+		d.unknown(b, l, "createMarketSale for another token")
+		return
+	}
+
+	b.Sales = append(b.Sales, model.MarketSale{
+		ChainID:     d.chainID,
+		Market:      market.Hex(),
+		ItemID:      model.NewBigInt(itemID),
+		NftContract: l.Address.Hex(),
+		TokenID:     model.NewBigInt(l.Topics[3].Big()),
+		Buyer:       common.BytesToAddress(l.Topics[2].Bytes()).Hex(),
+		Price:       model.NewBigInt(tx.Value()),
+		BlockNumber: l.BlockNumber,
+		BlockHash:   l.BlockHash.Hex(),
+		TxHash:      l.TxHash.Hex(),
+		LogIndex:    l.Index,
+	})
+}
@@ -0,0 +1,111 @@
+package indexer
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"blockchain.com/indexer/store"
+)
+
+func TestDecodeSale(t *testing.T) {
+	f := newFixture(t, Config{})
+	buyer := f.address(f.buyer)
+	other := common.HexToAddress("0x00000000000000000000000000000000000000cc")
+	transfer := eventLog(t, f.nftA, "Transfer", f.nft, f.market, buyer, big.NewInt(7))
+	sale := func(nftContract common.Address, item int64) []byte {
+		data, err := f.marketA.Pack("createMarketSale", nftContract, big.NewInt(item))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+	listing, err := f.marketA.Pack("createMarketItem", f.nft, big.NewInt(7), big.NewInt(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		to     common.Address
+		data   []byte
+		reason string
+	}{
+		{"createMarketSale", f.market, sale(f.nft, 3), ""},
+		{"call to another contract", other, sale(f.nft, 3), "transfer out of the market outside of a createMarketSale call"},
+		{"another market method", f.market, listing, "transfer out of the market outside of a createMarketSale call"},
+		{"no calldata", f.market, nil, "transfer out of the market outside of a createMarketSale call"},
+		{"sale of another token contract", f.market, sale(other, 3), "createMarketSale for another token"},
+		{"truncated arguments", f.market, sale(f.nft, 3)[:20], "decode createMarketSale: "},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx := types.NewTx(&types.LegacyTx{To: &tt.to, Value: big.NewInt(250), Data: tt.data})
+			b := &store.Batch{}
+			f.ix.decoder.sale(b, transfer, f.market, tx)
+
+			if tt.reason != "" {
+				if len(b.Sales) != 0 || len(b.UnknownLogs) != 1 {
+					t.Fatalf("%d sales and %d unknown logs, want the transfer kept as unknown", len(b.Sales), len(b.UnknownLogs))
+				}
+				if got := b.UnknownLogs[0].Reason; !strings.HasPrefix(got, tt.reason) {
+					t.Errorf("reason %q, want %q", got, tt.reason)
+				}
+				return
+			}
+			if len(b.Sales) != 1 {
+				t.Fatalf("%d sales, want 1", len(b.Sales))
+			}
+			s := b.Sales[0]
+			if s.ItemID.Int64() != 3 || s.TokenID.Int64() != 7 || s.Price.Int64() != 250 ||
+				s.Buyer != buyer.Hex() || s.Market != f.market.Hex() || s.NftContract != f.nft.Hex() {
+				t.Errorf("sale %+v, want item 3 of token 7 bought by %s for 250", s, buyer.Hex())
+			}
+		})
+	}
+}
+
+func TestInferSales(t *testing.T) {
+	ctx := context.Background()
+	f := newFixture(t, Config{})
+	seller, buyer := f.address(f.seller), f.address(f.buyer)
+
+	f.mint(t, f.seller, 1)
+	f.mint(t, f.seller, 2)
+	f.chain.mine()
+	f.list(t, f.seller, 1, 1, 100)
+	f.list(t, f.seller, 2, 2, 200)
+	f.chain.mine()
+	// The buyer pays more than the listing price: the sale records what was
+	// paid.
+	f.buy(t, f.buyer, 1, 1, 150)
+	// A Transfer out of the market outside of a sale, as an admin rescue
+	// would emit.
+	f.chain.send(t, f.seller, f.market, nil, nil,
+		eventLog(t, f.nftA, "Transfer", f.nft, f.market, seller, big.NewInt(2)))
+	f.chain.mine()
+	f.sync(t)
+
+	s, err := f.store.Sale(ctx, testChainID, f.market.Hex(), big.NewInt(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Price.Int64() != 150 || s.Seller != seller.Hex() || s.Buyer != buyer.Hex() {
+		t.Errorf("sale of item 1 for %v from %s to %s, want 150 from the seller to the buyer", s.Price.Int64(), s.Seller, s.Buyer)
+	}
+	it, err := f.store.MarketItem(ctx, testChainID, f.market.Hex(), big.NewInt(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !it.Sold || it.Owner != buyer.Hex() {
+		t.Errorf("item 1 sold %v to %s, want sold to the buyer", it.Sold, it.Owner)
+	}
+	if _, err := f.store.Sale(ctx, testChainID, f.market.Hex(), big.NewInt(2)); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("sale of item 2: %v, want not found", err)
+	}
+}
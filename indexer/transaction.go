@@ -0,0 +1,122 @@
+package indexer
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"blockchain.com/indexer/model"
+	"blockchain.com/indexer/store"
+)
+
+// transactions fetches the transactions that emitted the records of b.
+func (ix *Indexer) transactions(ctx context.Context, b *store.Batch, headers map[uint64]*types.Header) error {
+	// first is the index of the first record each transaction emitted.
+	first := make(map[common.Hash]uint)
+	var hashes []common.Hash
+	add := func(txHash string, logIndex uint) {
+		hash := common.HexToHash(txHash)
+		i, ok := first[hash]
+		if !ok {
+			hashes = append(hashes, hash)
+		}
+		if !ok || logIndex < i {
+			first[hash] = logIndex
+		}
+	}
+	for _, it := range b.MarketItems {
+		add(it.TxHash, it.LogIndex)
+	}
+	for _, t := range b.Transfers {
+		add(t.TxHash, t.LogIndex)
+	}
+	for _, sale := range b.Sales {
+		add(sale.TxHash, sale.LogIndex)
+	}
+	for _, a := range b.Approvals {
+		add(a.TxHash, a.LogIndex)
+	}
+	for _, a := range b.ApprovalsForAll {
+		add(a.TxHash, a.LogIndex)
+	}
+	for _, ev := range b.ContractEvents {
+		add(ev.TxHash, ev.LogIndex)
+	}
+
+	for _, hash := range hashes {
+		tx, err := ix.transaction(ctx, hash, headers)
+		if err != nil {
+			return fmt.Errorf("transaction %v: %w", hash.Hex(), err)
+		}
+		logIndex := first[hash]
+		tx.LogIndex = &logIndex
+		b.Transactions = append(b.Transactions, *tx)
+	}
+	return nil
+}
+
+// transaction builds the record of a mined transaction from the
+// transaction, its receipt and the header of its block.
+func (ix *Indexer) transaction(ctx context.Context, hash common.Hash, headers map[uint64]*types.Header) (*model.Transaction, error) {
+	tx, _, err := ix.backend.TransactionByHash(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	receipt, err := ix.backend.TransactionReceipt(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	header, err := ix.header(ctx, headers, receipt.BlockNumber.Uint64())
+	if err != nil {
+		return nil, err
+	}
+	if header.Hash() != receipt.BlockHash {
+		return nil, errChainChanged
+	}
+
+	from, err := types.Sender(types.LatestSignerForChainID(tx.ChainId()), tx)
+	if err != nil {
+		return nil, err
+	}
+
+	rec := &model.Transaction{
+		ChainID:           ix.cfg.ChainID,
+		TxHash:            hash.Hex(),
+		BlockNumber:       receipt.BlockNumber.Uint64(),
+		BlockHash:         receipt.BlockHash.Hex(),
+		TransactionIndex:  receipt.TransactionIndex,
+		FromAddress:       from.Hex(),
+		Nonce:             tx.Nonce(),
+		Value:             model.NewBigInt(tx.Value()),
+		GasUsed:           receipt.GasUsed,
+		EffectiveGasPrice: model.NewBigInt(effectiveGasPrice(tx, header.BaseFee)),
+		Status:            model.TransactionStatusFailed,
+	}
+	if tx.To() != nil {
+		rec.ToAddress = tx.To().Hex()
+	}
+	if receipt.Status == types.ReceiptStatusSuccessful {
+		rec.Status = model.TransactionStatusSuccess
+	}
+	if data := tx.Data(); len(data) >= 4 {
+		rec.MethodSelector = hexutil.Encode(data[:4])
+		rec.MethodName = ix.decoder.methodName(tx.To(), data[:4])
+	}
+	return rec, nil
+}
+
+// effectiveGasPrice returns the price per gas the sender paid.
+func effectiveGasPrice(tx *types.Transaction, baseFee *big.Int) *big.Int {
+	if tx.Type() != types.DynamicFeeTxType || baseFee == nil {
+		return tx.GasPrice()
+	}
+	price := new(big.Int).Add(tx.GasTipCap(), baseFee)
+	if price.Cmp(tx.GasFeeCap()) > 0 {
+		return tx.GasFeeCap()
+	}
+	return price
+}
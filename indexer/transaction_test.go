@@ -0,0 +1,39 @@
+package indexer
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"blockchain.com/indexer/model"
+)
+
+func TestTransactionLogIndex(t *testing.T) {
+	ctx := context.Background()
+	f := newFixture(t, Config{})
+	seller := f.address(f.seller)
+	mint := f.chain.send(t, f.seller, f.nft, nil, nil,
+		eventLog(t, f.nftA, "Transfer", f.nft, common.Address{}, seller, big.NewInt(1)))
+	// The listing emits the logs 1 and 2 of the block.
+	list := f.chain.send(t, f.seller, f.market, nil, nil,
+		eventLog(t, f.nftA, "Transfer", f.nft, seller, f.market, big.NewInt(1)),
+		eventLog(t, f.marketA, "MarketItemCreated", f.market, big.NewInt(1), f.nft, big.NewInt(1), seller, common.Address{}, big.NewInt(100), false))
+	f.chain.mine()
+	f.sync(t)
+
+	for i, hash := range []common.Hash{mint.Hash(), list.Hash()} {
+		tx, err := f.store.Transaction(ctx, testChainID, hash.Hex())
+		if err != nil {
+			t.Fatal(err)
+		}
+		logIndex := -1
+		if tx.LogIndex != nil {
+			logIndex = int(*tx.LogIndex)
+		}
+		if logIndex != i || tx.TransactionIndex != uint(i) || tx.Status != model.TransactionStatusSuccess {
+			t.Errorf("transaction %d: log index %d, index %d, status %s, want %d, %d, success", i, logIndex, tx.TransactionIndex, tx.Status, i, i)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package metadata
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// ErrInvalid is returned for documents that are not ERC-721 metadata.
+var ErrInvalid = errors.New("metadata: invalid ERC-721 metadata")
+
+// Metadata is an ERC-721 metadata document.
+type Metadata struct {
+	Name        string      `json:"name"`
+	Description string      `json:"description"`
+	Image       string      `json:"image"`
+	Attributes  []Attribute `json:"attributes"`
+}
+
+// Attribute is an OpenSea-style trait. Value is a string, number or bool.
+type Attribute struct {
+	TraitType   string      `json:"trait_type,omitempty"`
+	Value       interface{} `json:"value"`
+	DisplayType string      `json:"display_type,omitempty"`
+}
+
+// Parse validates and decodes an ERC-721 metadata document: a JSON object
+// whose name, description and image, when present, are strings and whose
+// attributes, when present, are objects with a scalar value.
+func Parse(data []byte) (*Metadata, error) {
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	if doc == nil {
+		return nil, fmt.Errorf("%w: not an object", ErrInvalid)
+	}
+
+	m := &Metadata{}
+	for key, dst := range map[string]*string{"name": &m.Name, "description": &m.Description, "image": &m.Image} {
+		raw, ok := doc[key]
+		if !ok || isNull(raw) {
+			continue
+		}
+		if err := json.Unmarshal(raw, dst); err != nil {
+			return nil, fmt.Errorf("%w: %s is not a string", ErrInvalid, key)
+		}
+	}
+	if m.Image == "" {
+		// A common alias.
+		if raw, ok := doc["image_url"]; ok {
+			_ = json.Unmarshal(raw, &m.Image)
+		}
+	}
+	if m.Name == "" && m.Image == "" && m.Description == "" {
+		return nil, fmt.Errorf("%w: no name, description or image", ErrInvalid)
+	}
+
+	raw, ok := doc["attributes"]
+	if !ok || isNull(raw) {
+		return m, nil
+	}
+	var attrs []map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &attrs); err != nil {
+		return nil, fmt.Errorf("%w: attributes is not an array of objects", ErrInvalid)
+	}
+	for i, a := range attrs {
+		var attr Attribute
+		if v, ok := a["trait_type"]; ok && !isNull(v) {
+			if err := json.Unmarshal(v, &attr.TraitType); err != nil {
+				return nil, fmt.Errorf("%w: attributes[%d].trait_type is not a string", ErrInvalid, i)
+			}
+		}
+		if v, ok := a["display_type"]; ok && !isNull(v) {
+			if err := json.Unmarshal(v, &attr.DisplayType); err != nil {
+				return nil, fmt.Errorf("%w: attributes[%d].display_type is not a string", ErrInvalid, i)
+			}
+		}
+		v, ok := a["value"]
+		if !ok {
+			return nil, fmt.Errorf("%w: attributes[%d] has no value", ErrInvalid, i)
+		}
+		dec := json.NewDecoder(bytes.NewReader(v))
+		dec.UseNumber()
+		if err := dec.Decode(&attr.Value); err != nil {
+			return nil, fmt.Errorf("%w: attributes[%d].value: %v", ErrInvalid, i, err)
+		}
+		switch attr.Value.(type) {
+		case string, json.Number, bool:
+		default:
+			return nil, fmt.Errorf("%w: attributes[%d].value is not a string, number or bool", ErrInvalid, i)
+		}
+		m.Attributes = append(m.Attributes, attr)
+	}
+	return m, nil
+}
+
+func isNull(raw json.RawMessage) bool {
+	return bytes.Equal(bytes.TrimSpace(raw), []byte("null"))
+}
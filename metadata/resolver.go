@@ -0,0 +1,255 @@
+// Package metadata resolves the ERC-721 metadata of minted tokens from their
+// tokenURI and keeps it in the store, retrying failures with backoff.
+package metadata
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// maxDocumentSize bounds the metadata documents fetched.
+const maxDocumentSize = 1 << 20
+
+var (
+	// ErrUnsupportedURI is returned for URIs of unsupported schemes.
+	ErrUnsupportedURI = errors.New("metadata: unsupported URI")
+
+	// ErrNoGateway is returned for ipfs:// and ar:// URIs when no gateway
+	// is configured for them.
+	ErrNoGateway = errors.New("metadata: no gateway configured")
+
+	// ErrPrivateAddress is returned for URLs that resolve to an address
+	// that is not publicly routable.
+	ErrPrivateAddress = errors.New("metadata: private address")
+)
+
+// Resolver fetches documents by URI: http(s) URLs directly, ipfs:// and ar://
+// URIs through the gateways, in order until one answers, and data: URIs
+// inline.
+type Resolver struct {
+	IPFSGateways    []string
+	ArweaveGateways []string
+	Client          *http.Client
+}
+
+// NewResolver returns a resolver whose client only connects to publicly
+// routable addresses, as tokenURIs are chosen by whoever deploys a contract.
+// The gateways are trusted and may be local, such as an IPFS node on
+// loopback.
+func NewResolver(ipfsGateways, arweaveGateways []string) *Resolver {
+	d := &dialer{
+		public:   net.Dialer{Timeout: 30 * time.Second, Control: checkPublic},
+		any:      net.Dialer{Timeout: 30 * time.Second},
+		gateways: make(map[string]bool),
+	}
+	for _, gw := range append(append([]string{}, ipfsGateways...), arweaveGateways...) {
+		if addr, ok := hostPort(gw); ok {
+			d.gateways[addr] = true
+		}
+	}
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	// A proxy would be dialed in place of the document hosts.
+	t.Proxy = nil
+	t.DialContext = d.DialContext
+	return &Resolver{
+		IPFSGateways:    ipfsGateways,
+		ArweaveGateways: arweaveGateways,
+		Client:          &http.Client{Timeout: 30 * time.Second, Transport: t},
+	}
+}
+
+// dialer dials the gateways as configured and every other host only at a
+// public address.
+type dialer struct {
+	public, any net.Dialer
+
+	// gateways holds the host:port of the gateways.
+	gateways map[string]bool
+}
+
+func (d *dialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	if d.gateways[strings.ToLower(addr)] {
+		return d.any.DialContext(ctx, network, addr)
+	}
+	return d.public.DialContext(ctx, network, addr)
+}
+
+// checkPublic refuses connections to addresses that are not publicly
+// routable. It runs after name resolution, on the address dialed.
+func checkPublic(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !isPublic(ip) {
+		return fmt.Errorf("%w %s", ErrPrivateAddress, host)
+	}
+	return nil
+}
+
+// reserved are the IPv4 ranges that net.IP does not classify but are not
+// publicly routable either: "this network" and the shared address space of
+// carrier-grade NAT.
+var reserved = []net.IPNet{
+	{IP: net.IPv4(0, 0, 0, 0).To4(), Mask: net.CIDRMask(8, 32)},
+	{IP: net.IPv4(100, 64, 0, 0).To4(), Mask: net.CIDRMask(10, 32)},
+}
+
+// isPublic reports whether ip is neither loopback, private, link-local (such
+// as the cloud metadata address 169.254.169.254), multicast, unspecified nor
+// reserved.
+func isPublic(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() ||
+		ip.IsMulticast() || ip.IsUnspecified() {
+		return false
+	}
+	for _, n := range reserved {
+		if n.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// hostPort returns the host:port an http(s) URL is dialed at.
+func hostPort(rawURL string) (string, bool) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Hostname() == "" {
+		return "", false
+	}
+	port := u.Port()
+	if port == "" {
+		switch strings.ToLower(u.Scheme) {
+		case "http":
+			port = "80"
+		case "https":
+			port = "443"
+		default:
+			return "", false
+		}
+	}
+	return strings.ToLower(net.JoinHostPort(u.Hostname(), port)), true
+}
+
+// Fetch returns the document at uri.
+func (r *Resolver) Fetch(ctx context.Context, uri string) ([]byte, error) {
+	uri = strings.TrimSpace(uri)
+	if strings.HasPrefix(uri, "data:") {
+		return decodeDataURI(uri)
+	}
+	urls, err := r.URLs(uri)
+	if err != nil {
+		return nil, err
+	}
+	var errs []string
+	for _, u := range urls {
+		data, err := r.get(ctx, u)
+		if err == nil {
+			return data, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		errs = append(errs, err.Error())
+	}
+	return nil, fmt.Errorf("metadata: %s", strings.Join(errs, "; "))
+}
+
+// URLs returns the http(s) URLs uri can be fetched from, in order of
+// preference.
+func (r *Resolver) URLs(uri string) ([]string, error) {
+	scheme, rest := splitScheme(uri)
+	switch scheme {
+	case "http", "https":
+		return []string{uri}, nil
+	case "ipfs":
+		// ipfs://ipfs/<cid> is a common mistake for ipfs://<cid>.
+		return gatewayURLs(r.IPFSGateways, "ipfs/"+strings.TrimPrefix(rest, "ipfs/"))
+	case "ar":
+		return gatewayURLs(r.ArweaveGateways, rest)
+	}
+	return nil, fmt.Errorf("%w: %q", ErrUnsupportedURI, uri)
+}
+
+func splitScheme(uri string) (string, string) {
+	i := strings.Index(uri, "://")
+	if i < 0 {
+		return "", uri
+	}
+	return strings.ToLower(uri[:i]), uri[i+3:]
+}
+
+func gatewayURLs(gateways []string, path string) ([]string, error) {
+	if len(gateways) == 0 {
+		return nil, ErrNoGateway
+	}
+	urls := make([]string, len(gateways))
+	for i, gw := range gateways {
+		urls[i] = strings.TrimSuffix(gw, "/") + "/" + path
+	}
+	return urls, nil
+}
+
+func (r *Resolver) get(ctx context.Context, u string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	client := r.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", u, res.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(res.Body, maxDocumentSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", u, err)
+	}
+	if len(data) > maxDocumentSize {
+		return nil, fmt.Errorf("%s: document larger than %d bytes", u, maxDocumentSize)
+	}
+	return data, nil
+}
+
+// decodeDataURI decodes an RFC 2397 data: URI.
+func decodeDataURI(uri string) ([]byte, error) {
+	i := strings.IndexByte(uri, ',')
+	if i < 0 {
+		return nil, fmt.Errorf("metadata: malformed data URI")
+	}
+	header, payload := uri[len("data:"):i], uri[i+1:]
+	if strings.HasSuffix(header, ";base64") {
+		// Some contracts emit unpadded or URL-safe base64.
+		payload = strings.TrimRight(payload, "=")
+		data, err := base64.RawStdEncoding.DecodeString(payload)
+		if err != nil {
+			if data, err = base64.RawURLEncoding.DecodeString(payload); err != nil {
+				return nil, fmt.Errorf("metadata: data URI: %w", err)
+			}
+		}
+		return data, nil
+	}
+	data, err := url.PathUnescape(payload)
+	if err != nil {
+		return nil, fmt.Errorf("metadata: data URI: %w", err)
+	}
+	return []byte(data), nil
+}
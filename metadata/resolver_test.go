@@ -0,0 +1,303 @@
+package metadata
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+
+	"blockchain.com/indexer/contracts/nft"
+	"blockchain.com/indexer/model"
+	"blockchain.com/indexer/store/memory"
+)
+
+const testChainID = 1337
+
+const document = `{"name":"Token","image":"ipfs://image"}`
+
+// gateway serves document at every path, after failing the first fails
+// requests with status.
+type gateway struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	status   int
+	fails    int
+	requests []string
+}
+
+func newGateway(t *testing.T, status, fails int) *gateway {
+	g := &gateway{status: status, fails: fails}
+	g.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		g.mu.Lock()
+		g.requests = append(g.requests, r.URL.Path)
+		fail := len(g.requests) <= g.fails
+		g.mu.Unlock()
+		if fail {
+			http.Error(w, "unavailable", g.status)
+			return
+		}
+		fmt.Fprint(w, document)
+	}))
+	t.Cleanup(g.Close)
+	return g
+}
+
+func (g *gateway) paths() []string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return append([]string(nil), g.requests...)
+}
+
+func TestFetchGateways(t *testing.T) {
+	ctx := context.Background()
+	down := newGateway(t, http.StatusBadGateway, 1<<30)
+	up := newGateway(t, 0, 0)
+	notFound := newGateway(t, http.StatusNotFound, 1<<30)
+
+	tests := []struct {
+		name     string
+		ipfs, ar []*gateway
+		uri      string
+		err      string
+		path     string
+	}{
+		{name: "first gateway", ipfs: []*gateway{up, down}, uri: "ipfs://cid/1.json", path: "/ipfs/cid/1.json"},
+		{name: "second gateway after a 5xx", ipfs: []*gateway{down, up}, uri: "ipfs://cid/1.json", path: "/ipfs/cid/1.json"},
+		{name: "second gateway after a 404", ipfs: []*gateway{notFound, up}, uri: "ipfs://cid/1.json", path: "/ipfs/cid/1.json"},
+		{name: "ipfs path in the URI", ipfs: []*gateway{up}, uri: "ipfs://ipfs/cid/1.json", path: "/ipfs/cid/1.json"},
+		{name: "arweave", ar: []*gateway{down, up}, uri: "ar://tx", path: "/tx"},
+		{name: "every gateway down", ipfs: []*gateway{down, notFound}, uri: "ipfs://cid", err: "502 Bad Gateway; "},
+		{name: "no gateway", ar: []*gateway{up}, uri: "ipfs://cid", err: ErrNoGateway.Error()},
+		{name: "unsupported scheme", ipfs: []*gateway{up}, uri: "ftp://host/1.json", err: ErrUnsupportedURI.Error()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ipfs, ar []string
+			for _, g := range tt.ipfs {
+				ipfs = append(ipfs, g.URL+"/")
+			}
+			for _, g := range tt.ar {
+				ar = append(ar, g.URL)
+			}
+			before := len(up.paths())
+			data, err := NewResolver(ipfs, ar).Fetch(ctx, tt.uri)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("error %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != document {
+				t.Errorf("document %q", data)
+			}
+			if paths := up.paths()[before:]; len(paths) != 1 || paths[0] != tt.path {
+				t.Errorf("gateway requests %v, want %s", paths, tt.path)
+			}
+		})
+	}
+}
+
+func TestFetchDataURI(t *testing.T) {
+	tests := []struct {
+		uri, want string
+		err       bool
+	}{
+		{uri: "data:application/json;base64,eyJuYW1lIjoiQSJ9", want: `{"name":"A"}`},
+		{uri: "data:application/json;base64,eyJuYW1lIjoiQUIifQ==", want: `{"name":"AB"}`},
+		{uri: "data:application/json;base64,eyJuYW1lIjoiQUIifQ", want: `{"name":"AB"}`},
+		{uri: "data:application/json;base64,eyJuYW1lIjoiPz8_In0", want: `{"name":"???"}`},
+		{uri: "data:application/json,%7B%22name%22%3A%22A%22%7D", want: `{"name":"A"}`},
+		{uri: `data:application/json;utf8,{"name":"A"}`, want: `{"name":"A"}`},
+		{uri: "  data:,A  ", want: "A"},
+		{uri: "data:application/json;base64", err: true},
+		{uri: "data:application/json;base64,!!!", err: true},
+		{uri: "data:application/json,%zz", err: true},
+	}
+	for _, tt := range tests {
+		data, err := NewResolver(nil, nil).Fetch(context.Background(), tt.uri)
+		if tt.err {
+			if err == nil {
+				t.Errorf("%s: %q, want an error", tt.uri, data)
+			}
+			continue
+		}
+		if err != nil || string(data) != tt.want {
+			t.Errorf("%s: %q, %v, want %q", tt.uri, data, err, tt.want)
+		}
+	}
+}
+
+func TestFetchSizeLimit(t *testing.T) {
+	for _, size := range []int{maxDocumentSize, maxDocumentSize + 1, 4 * maxDocumentSize} {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(strings.Repeat(" ", size)))
+		}))
+		data, err := NewResolver([]string{srv.URL}, nil).Fetch(context.Background(), "ipfs://cid")
+		srv.Close()
+		switch {
+		case size <= maxDocumentSize && (err != nil || len(data) != size):
+			t.Errorf("%d bytes: %d bytes, %v", size, len(data), err)
+		case size > maxDocumentSize && (err == nil || !strings.Contains(err.Error(), "document larger than")):
+			t.Errorf("%d bytes: %d bytes, %v, want the document refused", size, len(data), err)
+		}
+	}
+}
+
+func TestFetchPrivateAddress(t *testing.T) {
+	ctx := context.Background()
+	gw := newGateway(t, 0, 0)
+	// The gateway redirects to a loopback host that is not a gateway.
+	other := newGateway(t, 0, 0)
+	redirect := httptest.NewServer(http.RedirectHandler(other.URL+"/doc", http.StatusFound))
+	defer redirect.Close()
+	r := NewResolver([]string{gw.URL, redirect.URL}, nil)
+
+	for _, uri := range []string{
+		other.URL + "/doc",
+		strings.Replace(other.URL, "127.0.0.1", "localhost", 1) + "/doc",
+		"http://169.254.169.254/latest/meta-data/",
+		"http://10.0.0.1/doc",
+		"http://[::1]:80/doc",
+		"http://0.0.0.0:80/doc",
+		"http://0.1.2.3/doc",
+		"http://100.64.0.1/doc",
+		"http://100.127.255.254/doc",
+		"http://[::ffff:100.64.0.1]/doc",
+	} {
+		if _, err := r.Fetch(ctx, uri); err == nil || !strings.Contains(err.Error(), ErrPrivateAddress.Error()) {
+			t.Errorf("%s: %v, want %v", uri, err, ErrPrivateAddress)
+		}
+	}
+	if _, err := r.Fetch(ctx, gw.URL+"/doc"); err != nil {
+		t.Errorf("gateway URL: %v", err)
+	}
+	// The first gateway answers, so the redirecting one is not tried.
+	if _, err := r.Fetch(ctx, "ipfs://cid"); err != nil {
+		t.Errorf("gateway: %v", err)
+	}
+	r = NewResolver([]string{redirect.URL}, nil)
+	if _, err := r.Fetch(ctx, "ipfs://cid"); err == nil || !strings.Contains(err.Error(), ErrPrivateAddress.Error()) {
+		t.Errorf("redirect to loopback: %v, want %v", err, ErrPrivateAddress)
+	}
+	if n := len(other.paths()); n != 0 {
+		t.Errorf("%d requests reached the private host", n)
+	}
+}
+
+// caller answers tokenURI calls with uri.
+type caller struct {
+	uri string
+}
+
+func (c *caller) CodeAt(ctx context.Context, contract common.Address, blockNumber *big.Int) ([]byte, error) {
+	return []byte{1}, nil
+}
+
+func (c *caller) CallContract(ctx context.Context, call ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	a, err := abi.JSON(strings.NewReader(nft.MainABI))
+	if err != nil {
+		return nil, err
+	}
+	return a.Methods["tokenURI"].Outputs.Pack(c.uri)
+}
+
+func TestResolveRetries(t *testing.T) {
+	ctx := context.Background()
+	// The gateway fails the first four attempts.
+	gw := newGateway(t, http.StatusServiceUnavailable, 4)
+	s := memory.New()
+	w := NewWorker(s, &caller{uri: "ipfs://cid"}, NewResolver([]string{gw.URL}, nil), Config{
+		ChainID:     testChainID,
+		MaxAttempts: 5,
+		MinBackoff:  time.Minute,
+		MaxBackoff:  5 * time.Minute,
+	})
+	m := &model.TokenMetadata{
+		ChainID:       testChainID,
+		Contract:      common.HexToAddress("0xbb").Hex(),
+		TokenID:       model.NewBigInt(big.NewInt(1)),
+		Status:        model.MetadataPending,
+		Attributes:    "[]",
+		NextAttemptAt: time.Now(),
+	}
+
+	for i, backoff := range []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute, 5 * time.Minute} {
+		start := time.Now()
+		if err := w.Resolve(ctx, m); err != nil {
+			t.Fatal(err)
+		}
+		if m.Status != model.MetadataPending || m.Attempts != i+1 || !strings.Contains(m.Error, "503") {
+			t.Fatalf("attempt %d: %s after %d attempts: %s", i+1, m.Status, m.Attempts, m.Error)
+		}
+		if wait := m.NextAttemptAt.Sub(start); wait < backoff || wait > backoff+time.Second {
+			t.Errorf("attempt %d: next attempt in %v, want %v", i+1, wait, backoff)
+		}
+	}
+	if err := w.Resolve(ctx, m); err != nil {
+		t.Fatal(err)
+	}
+	got, err := s.TokenMetadata(ctx, testChainID, m.Contract, m.TokenID.Big())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != model.MetadataResolved || got.Name != "Token" || got.URI != "ipfs://cid" || got.Error != "" {
+		t.Errorf("metadata %+v, want resolved", got)
+	}
+
+	// Without a working gateway the last attempt fails the metadata.
+	down := newGateway(t, http.StatusInternalServerError, 1<<30)
+	w.resolver = NewResolver([]string{down.URL}, nil)
+	m = &model.TokenMetadata{ChainID: testChainID, Contract: m.Contract, TokenID: model.NewBigInt(big.NewInt(2)), Status: model.MetadataPending}
+	for i := 0; i < 5; i++ {
+		if err := w.Resolve(ctx, m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if m.Status != model.MetadataFailed || m.Attempts != 5 {
+		t.Errorf("%s after %d attempts, want failed after 5", m.Status, m.Attempts)
+	}
+}
+
+func TestIsPublic(t *testing.T) {
+	tests := []struct {
+		ip     string
+		public bool
+	}{
+		{"8.8.8.8", true},
+		{"100.63.255.255", true},
+		{"100.128.0.0", true},
+		{"1.0.0.1", true},
+		{"2606:4700::1111", true},
+		{"0.0.0.0", false},
+		{"0.255.255.255", false},
+		{"100.64.0.0", false},
+		{"100.127.255.255", false},
+		{"::ffff:100.100.1.1", false},
+		{"127.0.0.1", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"fd00::1", false},
+		{"fe80::1", false},
+		{"::", false},
+	}
+	for _, tt := range tests {
+		if got := isPublic(net.ParseIP(tt.ip)); got != tt.public {
+			t.Errorf("isPublic(%s) = %v, want %v", tt.ip, got, tt.public)
+		}
+	}
+}
@@ -0,0 +1,317 @@
+package metadata
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/event"
+
+	"blockchain.com/indexer/contracts/nft"
+	"blockchain.com/indexer/indexer"
+	"blockchain.com/indexer/model"
+	"blockchain.com/indexer/rarity"
+	"blockchain.com/indexer/store"
+)
+
+// Indexer is the part of the indexer the worker follows.
+type Indexer interface {
+	SubscribeChainEvents(ch chan<- indexer.ChainEvent) event.Subscription
+	SubscribeRollbackEvents(ch chan<- indexer.RollbackEvent) event.Subscription
+}
+
+type Config struct {
+	ChainID uint64
+
+	// PollInterval is how often due metadata is resolved.
+	PollInterval time.Duration
+
+	// BatchSize is the number of due tokens read at a time.
+	BatchSize int
+
+	// MaxAttempts is the number of attempts before metadata is marked
+	// failed. The wait after a failure doubles from MinBackoff up to
+	// MaxBackoff.
+	MaxAttempts int
+	MinBackoff  time.Duration
+	MaxBackoff  time.Duration
+}
+
+// Worker queues the metadata of the tokens minted in committed batches and
+// resolves it: it reads the tokenURI of the token, fetches the document and
+// stores it once validated. Collections are rescored as their tokens
+// resolve, and after reorganizations that removed their transfers.
+type Worker struct {
+	store    store.Store
+	caller   bind.ContractCaller
+	resolver *Resolver
+	cfg      Config
+
+	mu sync.Mutex
+	// rescore holds the collections to rescore on the next resolution.
+	rescore map[string]bool
+	wake    chan struct{}
+}
+
+func NewWorker(s store.Store, caller bind.ContractCaller, r *Resolver, cfg Config) *Worker {
+	if cfg.PollInterval == 0 {
+		cfg.PollInterval = 15 * time.Second
+	}
+	if cfg.BatchSize == 0 {
+		cfg.BatchSize = 20
+	}
+	if cfg.MaxAttempts == 0 {
+		cfg.MaxAttempts = 8
+	}
+	if cfg.MinBackoff == 0 {
+		cfg.MinBackoff = time.Minute
+	}
+	if cfg.MaxBackoff == 0 {
+		cfg.MaxBackoff = 6 * time.Hour
+	}
+	return &Worker{
+		store:    s,
+		caller:   caller,
+		resolver: r,
+		cfg:      cfg,
+		rescore:  make(map[string]bool),
+		wake:     make(chan struct{}, 1),
+	}
+}
+
+// Run queues and resolves metadata until ctx is cancelled. Metadata is
+// resolved in its own goroutine: the indexer waits for its events to be
+// received before it commits the next batch, so receiving them only queues.
+// Rescoring after a rollback is left to that goroutine too, so that it never
+// runs alongside the rescoring of resolved tokens.
+func (w *Worker) Run(ctx context.Context, ix Indexer) error {
+	chain := make(chan indexer.ChainEvent, 16)
+	sub := ix.SubscribeChainEvents(chain)
+	defer sub.Unsubscribe()
+	rollback := make(chan indexer.RollbackEvent, 16)
+	rollbackSub := ix.SubscribeRollbackEvents(rollback)
+	defer rollbackSub.Unsubscribe()
+
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		w.resolve(ctx)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-sub.Err():
+			return err
+		case err := <-rollbackSub.Err():
+			return err
+		case ev := <-chain:
+			if err := w.queue(ctx, ev.Batch); err != nil {
+				log.Printf("metadata: cannot queue minted tokens: %v", err)
+			}
+		case ev := <-rollback:
+			w.rolledBack(ev.Removed)
+		}
+	}
+}
+
+// resolve resolves due metadata every poll interval, and as soon as a
+// rollback leaves collections to rescore, until ctx is cancelled.
+func (w *Worker) resolve(ctx context.Context) {
+	ticker := time.NewTicker(w.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-w.wake:
+		}
+		if _, err := w.ResolveDue(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("metadata: %v", err)
+		}
+	}
+}
+
+// rolledBack schedules the rescoring of the collections with removed
+// transfers. The store removed the metadata of the tokens minted in them;
+// rescoring a collection whose tokens only moved writes nothing.
+func (w *Worker) rolledBack(removed *store.Batch) {
+	w.mu.Lock()
+	for _, t := range removed.Transfers {
+		w.rescore[t.Contract] = true
+	}
+	w.mu.Unlock()
+
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+}
+
+// queue adds pending metadata for the tokens minted in b.
+func (w *Worker) queue(ctx context.Context, b *store.Batch) error {
+	var ms []model.TokenMetadata
+	for _, t := range b.Transfers {
+		if t.FromAddress != store.UnsoldOwner {
+			continue
+		}
+		ms = append(ms, model.TokenMetadata{
+			ChainID:       t.ChainID,
+			Contract:      t.Contract,
+			TokenID:       t.TokenID,
+			Status:        model.MetadataPending,
+			Attributes:    "[]",
+			NextAttemptAt: time.Now(),
+		})
+	}
+	return w.store.QueueMetadata(ctx, ms)
+}
+
+// ResolveDue resolves the metadata due now, BatchSize tokens at a time, and
+// returns the number of tokens it attempted. The rarity of the collections
+// with newly resolved tokens or rolled back is then recomputed, once per
+// collection. Collections that fail to rescore are rescored on the next
+// call.
+func (w *Worker) ResolveDue(ctx context.Context) (int, error) {
+	var contracts []string
+	resolved := make(map[string]bool)
+	n := 0
+	for {
+		due, err := w.store.DueMetadata(ctx, w.cfg.ChainID, time.Now(), w.cfg.BatchSize)
+		if err != nil {
+			return n, fmt.Errorf("cannot read due metadata: %w", err)
+		}
+		for i := range due {
+			if err := w.Resolve(ctx, &due[i]); err != nil {
+				return n, err
+			}
+			n++
+			if c := due[i].Contract; due[i].Status == model.MetadataResolved && !resolved[c] {
+				resolved[c] = true
+				contracts = append(contracts, c)
+			}
+		}
+		// Attempted metadata is no longer due: it resolved, failed or waits
+		// for its backoff.
+		if len(due) < w.cfg.BatchSize {
+			break
+		}
+	}
+
+	w.mu.Lock()
+	for c := range w.rescore {
+		if !resolved[c] {
+			resolved[c] = true
+			contracts = append(contracts, c)
+		}
+	}
+	w.rescore = make(map[string]bool)
+	w.mu.Unlock()
+
+	for i, c := range contracts {
+		if err := rarity.Update(ctx, w.store, w.cfg.ChainID, c); err != nil {
+			w.mu.Lock()
+			for _, c := range contracts[i:] {
+				w.rescore[c] = true
+			}
+			w.mu.Unlock()
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// Resolve makes an attempt at the metadata of m and saves the outcome. It
+// only returns store errors.
+func (w *Worker) Resolve(ctx context.Context, m *model.TokenMetadata) error {
+	err := w.fetch(ctx, m)
+	now := time.Now()
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		m.Attempts++
+		m.Error = err.Error()
+		if m.Attempts >= w.cfg.MaxAttempts {
+			m.Status = model.MetadataFailed
+			log.Printf("metadata: giving up on %v #%v after %d attempts: %v", m.Contract, m.TokenID, m.Attempts, err)
+		} else {
+			m.NextAttemptAt = now.Add(w.backoff(m.Attempts))
+		}
+	} else {
+		m.Status = model.MetadataResolved
+		m.Error = ""
+		m.ResolvedAt = &now
+	}
+	if err := w.store.SaveMetadata(ctx, m); err != nil {
+		return fmt.Errorf("cannot save metadata of %v #%v: %w", m.Contract, m.TokenID, err)
+	}
+	return nil
+}
+
+func (w *Worker) fetch(ctx context.Context, m *model.TokenMetadata) error {
+	caller, err := nft.NewMainCaller(common.HexToAddress(m.Contract), w.caller)
+	if err != nil {
+		return err
+	}
+	uri, err := caller.TokenURI(&bind.CallOpts{Context: ctx}, m.TokenID.Big())
+	if err != nil {
+		return fmt.Errorf("tokenURI: %w", err)
+	}
+	m.URI = uri
+	data, err := w.resolver.Fetch(ctx, uri)
+	if err != nil {
+		return err
+	}
+	md, err := Parse(data)
+	if err != nil {
+		return err
+	}
+	attrs := make([]Attribute, len(md.Attributes))
+	for i, a := range md.Attributes {
+		a.TraitType, a.DisplayType = clean(a.TraitType), clean(a.DisplayType)
+		if v, ok := a.Value.(string); ok {
+			a.Value = clean(v)
+		}
+		attrs[i] = a
+	}
+	rawAttrs, err := json.Marshal(attrs)
+	if err != nil {
+		return err
+	}
+	m.Name, m.Description, m.Image = clean(md.Name), clean(md.Description), clean(md.Image)
+	m.Attributes, m.Raw = string(rawAttrs), clean(string(data))
+	return nil
+}
+
+// clean drops what Postgres text columns reject: NUL characters and invalid
+// UTF-8.
+func clean(s string) string {
+	return strings.ReplaceAll(strings.ToValidUTF8(s, "\uFFFD"), "\x00", "")
+}
+
+// backoff returns the wait after the attempts-th failure.
+func (w *Worker) backoff(attempts int) time.Duration {
+	d := w.cfg.MinBackoff
+	for i := 1; i < attempts && d < w.cfg.MaxBackoff; i++ {
+		d *= 2
+	}
+	if d > w.cfg.MaxBackoff {
+		d = w.cfg.MaxBackoff
+	}
+	return d
+}
@@ -0,0 +1,123 @@
+package metadata
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/event"
+
+	"blockchain.com/indexer/indexer"
+	"blockchain.com/indexer/model"
+	"blockchain.com/indexer/rarity"
+	"blockchain.com/indexer/store"
+	"blockchain.com/indexer/store/memory"
+)
+
+// feeds sends the events of an indexer. subscribed is closed once Run has
+// subscribed to both feeds.
+type feeds struct {
+	chain, rollback event.Feed
+	subscribed      chan struct{}
+}
+
+func (f *feeds) SubscribeChainEvents(ch chan<- indexer.ChainEvent) event.Subscription {
+	return f.chain.Subscribe(ch)
+}
+
+func (f *feeds) SubscribeRollbackEvents(ch chan<- indexer.RollbackEvent) event.Subscription {
+	defer close(f.subscribed)
+	return f.rollback.Subscribe(ch)
+}
+
+func TestRunRollback(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s := memory.New()
+	contract := common.HexToAddress("0xbb").Hex()
+
+	// Tokens 1 to 3 are minted in blocks 1 to 3 and resolved.
+	b := &store.Batch{FromBlock: 1, ToBlock: 3}
+	for n := uint64(1); n <= 3; n++ {
+		id := model.NewBigInt(new(big.Int).SetUint64(n))
+		b.Blocks = append(b.Blocks, model.Block{ChainID: testChainID, Number: n, Hash: fmt.Sprintf("0x%x", n)})
+		b.Transfers = append(b.Transfers, model.NFTTransfer{
+			ChainID:     testChainID,
+			Contract:    contract,
+			TokenID:     id,
+			FromAddress: store.UnsoldOwner,
+			ToAddress:   "owner",
+			BlockNumber: n,
+			TxHash:      fmt.Sprintf("0x%x", n),
+		})
+	}
+	if err := s.Commit(ctx, b); err != nil {
+		t.Fatal(err)
+	}
+	for n, color := range []string{"Blue", "Blue", "Red"} {
+		err := s.SaveMetadata(ctx, &model.TokenMetadata{
+			ChainID:    testChainID,
+			Contract:   contract,
+			TokenID:    model.NewBigInt(big.NewInt(int64(n + 1))),
+			Status:     model.MetadataResolved,
+			Attributes: fmt.Sprintf(`[{"trait_type":"Background","value":%q}]`, color),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := rarity.Update(ctx, s, testChainID, contract); err != nil {
+		t.Fatal(err)
+	}
+
+	// Nothing is due: only the rollback makes the worker rescore.
+	w := NewWorker(s, &caller{}, NewResolver(nil, nil), Config{ChainID: testChainID, PollInterval: time.Hour})
+	ix := &feeds{subscribed: make(chan struct{})}
+	done := make(chan error, 1)
+	go func() { done <- w.Run(ctx, ix) }()
+	<-ix.subscribed
+
+	ancestor := model.Block{ChainID: testChainID, Number: 2, Hash: "0x2"}
+	removed, err := s.Rollback(ctx, testChainID, ancestor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ix.rollback.Send(indexer.RollbackEvent{Ancestor: ancestor, Removed: removed})
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		_, err := s.TokenRarity(ctx, testChainID, contract, big.NewInt(3))
+		if errors.Is(err, store.ErrNotFound) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("rarity of token 3 minted on an orphaned block: %v, want not found", err)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	for _, id := range []int64{1, 2} {
+		r, err := s.TokenRarity(ctx, testChainID, contract, big.NewInt(id))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.Supply != 2 || r.Score != 1 || r.Rank != 1 {
+			t.Errorf("token %d: supply %d, score %v, rank %d, want 2, 1, 1", id, r.Supply, r.Score, r.Rank)
+		}
+	}
+	counts, err := s.TraitCounts(ctx, testChainID, contract)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(counts) != 1 || counts[0].Value != "Blue" || counts[0].Count != 2 {
+		t.Errorf("trait counts %+v, want Blue twice", counts)
+	}
+
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("run: %v", err)
+	}
+}
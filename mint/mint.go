@@ -0,0 +1,339 @@
+// Package mint mints and lists tokens from the server's own account.
+package mint
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"blockchain.com/indexer/contracts/marketplace"
+	"blockchain.com/indexer/contracts/nft"
+	"blockchain.com/indexer/model"
+	"blockchain.com/indexer/nonce"
+	"blockchain.com/indexer/store"
+)
+
+var (
+	// ErrInvalidJob is returned for jobs that cannot be submitted.
+	ErrInvalidJob = errors.New("mint: invalid job")
+
+	// ErrNotFailed is returned when resuming a job that has not failed.
+	ErrNotFailed = errors.New("mint: job has not failed")
+
+	errReverted = errors.New("transaction reverted")
+)
+
+type Config struct {
+	ChainID uint64
+
+	// Market lists the tokens; NftContract mints them unless the job names
+	// another contract.
+	Market      string
+	NftContract string
+
+	// ReceiptTimeout is how long a step waits for its transaction, or one
+	// of its replacements, to be mined before the job fails. Resuming it
+	// waits again.
+	ReceiptTimeout time.Duration
+}
+
+// Service runs mint jobs one at a time, in submission order. Its
+// transactions are sent through a nonce manager, which replaces them when
+// they get stuck.
+type Service struct {
+	store   store.Store
+	backend bind.ContractBackend
+	nonces  *nonce.Manager
+	cfg     Config
+	wake    chan struct{}
+}
+
+// NewService returns a service sending its transactions through nm, from the
+// account of its signer.
+func NewService(s store.Store, backend bind.ContractBackend, nm *nonce.Manager, cfg Config) *Service {
+	if cfg.ReceiptTimeout == 0 {
+		cfg.ReceiptTimeout = 10 * time.Minute
+	}
+	return &Service{store: s, backend: backend, nonces: nm, cfg: cfg, wake: make(chan struct{}, 1)}
+}
+
+// Sender returns the account the service mints and lists from.
+func (s *Service) Sender() string {
+	return s.nonces.Address().Hex()
+}
+
+// Submit queues a job minting a token with tokenURI on nftContract, or on
+// the default contract when it is empty, and listing it at price.
+func (s *Service) Submit(ctx context.Context, tokenURI string, price *big.Int, nftContract string) (*model.MintJob, error) {
+	if nftContract == "" {
+		nftContract = s.cfg.NftContract
+	}
+	switch {
+	case tokenURI == "":
+		return nil, fmt.Errorf("%w: token uri is required", ErrInvalidJob)
+	case price == nil || price.Sign() <= 0:
+		return nil, fmt.Errorf("%w: price must be positive", ErrInvalidJob)
+	case nftContract == "":
+		return nil, fmt.Errorf("%w: nft contract is required", ErrInvalidJob)
+	case !common.IsHexAddress(nftContract):
+		return nil, fmt.Errorf("%w: invalid nft contract %q", ErrInvalidJob, nftContract)
+	}
+	j := &model.MintJob{
+		ChainID:     s.cfg.ChainID,
+		Market:      common.HexToAddress(s.cfg.Market).Hex(),
+		NftContract: common.HexToAddress(nftContract).Hex(),
+		Sender:      s.Sender(),
+		TokenURI:    tokenURI,
+		Price:       model.NewBigInt(price),
+		Step:        model.MintStepMint,
+		Status:      model.MintPending,
+	}
+	if err := s.store.SaveMintJob(ctx, j); err != nil {
+		return nil, err
+	}
+	s.notify()
+	return j, nil
+}
+
+// Resume queues a failed job again, from the step it failed at.
+func (s *Service) Resume(ctx context.Context, id int) (*model.MintJob, error) {
+	j, err := s.store.MintJob(ctx, s.cfg.ChainID, id)
+	if err != nil {
+		return nil, err
+	}
+	if j.Status != model.MintFailed {
+		return nil, ErrNotFailed
+	}
+	j.Status, j.Error = model.MintPending, ""
+	if err := s.store.SaveMintJob(ctx, j); err != nil {
+		return nil, err
+	}
+	s.notify()
+	return j, nil
+}
+
+func (s *Service) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run processes pending jobs until ctx is cancelled. Jobs left pending by a
+// previous run are resumed.
+func (s *Service) Run(ctx context.Context) error {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		jobs, err := s.store.MintJobs(ctx, s.cfg.ChainID, model.MintPending)
+		if err != nil {
+			log.Printf("mint: cannot read pending jobs: %v", err)
+		}
+		for i := range jobs {
+			if err := s.Process(ctx, &jobs[i]); err != nil {
+				log.Printf("mint: job %d: %v", jobs[i].ID, err)
+				break
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-s.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// Process runs the remaining steps of a pending job. A failed step fails the
+// job; Process only returns the errors that leave it pending: cancellation
+// and store errors.
+func (s *Service) Process(ctx context.Context, j *model.MintJob) error {
+	for j.Status == model.MintPending {
+		var err error
+		switch j.Step {
+		case model.MintStepMint:
+			err = s.mint(ctx, j)
+		case model.MintStepApprove:
+			err = s.approve(ctx, j)
+		case model.MintStepList:
+			err = s.list(ctx, j)
+		default:
+			j.Status = model.MintDone
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			j.Status, j.Error = model.MintFailed, err.Error()
+			log.Printf("mint: job %d failed at %s: %v", j.ID, j.Step, err)
+		}
+		if err := s.store.SaveMintJob(ctx, j); err != nil {
+			return fmt.Errorf("cannot save job: %w", err)
+		}
+	}
+	return nil
+}
+
+// mint creates the token and reads its id from the Transfer it emitted.
+func (s *Service) mint(ctx context.Context, j *model.MintJob) error {
+	contract := common.HexToAddress(j.NftContract)
+	rcpt, err := s.send(ctx, j, &j.MintTx, func(opts *bind.TransactOpts) (*types.Transaction, error) {
+		t, err := nft.NewMainTransactor(contract, s.backend)
+		if err != nil {
+			return nil, err
+		}
+		return t.CreateToken(opts, j.TokenURI)
+	})
+	if err != nil {
+		return err
+	}
+	f, err := nft.NewMainFilterer(contract, s.backend)
+	if err != nil {
+		return err
+	}
+	for _, l := range rcpt.Logs {
+		if l.Address != contract {
+			continue
+		}
+		if ev, err := f.ParseTransfer(*l); err == nil && ev.From == (common.Address{}) {
+			id := model.NewBigInt(ev.TokenId)
+			j.TokenID = &id
+		}
+	}
+	if j.TokenID == nil {
+		return fmt.Errorf("no token minted by %s", j.MintTx)
+	}
+	j.Step = model.MintStepApprove
+	return nil
+}
+
+// approve lets the market transfer the token, unless it already may.
+func (s *Service) approve(ctx context.Context, j *model.MintJob) error {
+	contract, market := common.HexToAddress(j.NftContract), common.HexToAddress(j.Market)
+	if j.ApproveTx == "" {
+		c, err := nft.NewMainCaller(contract, s.backend)
+		if err != nil {
+			return err
+		}
+		opts := &bind.CallOpts{Context: ctx}
+		approved, err := c.GetApproved(opts, j.TokenID.Big())
+		if err != nil {
+			return fmt.Errorf("getApproved: %w", err)
+		}
+		all, err := c.IsApprovedForAll(opts, common.HexToAddress(j.Sender), market)
+		if err != nil {
+			return fmt.Errorf("isApprovedForAll: %w", err)
+		}
+		if approved == market || all {
+			j.Step = model.MintStepList
+			return nil
+		}
+	}
+	_, err := s.send(ctx, j, &j.ApproveTx, func(opts *bind.TransactOpts) (*types.Transaction, error) {
+		t, err := nft.NewMainTransactor(contract, s.backend)
+		if err != nil {
+			return nil, err
+		}
+		return t.Approve(opts, market, j.TokenID.Big())
+	})
+	if err != nil {
+		return err
+	}
+	j.Step = model.MintStepList
+	return nil
+}
+
+// list creates the market item, paying the listing price of the market, and
+// reads the item id from the MarketItemCreated it emitted.
+func (s *Service) list(ctx context.Context, j *model.MintJob) error {
+	market := common.HexToAddress(j.Market)
+	rcpt, err := s.send(ctx, j, &j.ListTx, func(opts *bind.TransactOpts) (*types.Transaction, error) {
+		c, err := marketplace.NewMainCaller(market, s.backend)
+		if err != nil {
+			return nil, err
+		}
+		fee, err := c.GetListingPrice(&bind.CallOpts{Context: ctx})
+		if err != nil {
+			return nil, fmt.Errorf("getListingPrice: %w", err)
+		}
+		t, err := marketplace.NewMainTransactor(market, s.backend)
+		if err != nil {
+			return nil, err
+		}
+		opts.Value = fee
+		tx, err := t.CreateMarketItem(opts, common.HexToAddress(j.NftContract), j.TokenID.Big(), j.Price.Big())
+		if err == nil {
+			listingFee := model.NewBigInt(fee)
+			j.ListingFee = &listingFee
+		}
+		return tx, err
+	})
+	if err != nil {
+		return err
+	}
+	f, err := marketplace.NewMainFilterer(market, s.backend)
+	if err != nil {
+		return err
+	}
+	for _, l := range rcpt.Logs {
+		if l.Address != market {
+			continue
+		}
+		if ev, err := f.ParseMarketItemCreated(*l); err == nil {
+			id := model.NewBigInt(ev.ItemId)
+			j.ItemID = &id
+		}
+	}
+	if j.ItemID == nil {
+		return fmt.Errorf("no market item created by %s", j.ListTx)
+	}
+	j.Step = model.MintStepDone
+	return nil
+}
+
+// send sends the transaction of a step, unless *hash holds one already, and
+// waits for it to be mined. The hash is saved before waiting and updated if
+// a replacement was mined instead. A transaction that reverted, was
+// cancelled or was dropped is forgotten, so that resuming the job sends it
+// again.
+func (s *Service) send(ctx context.Context, j *model.MintJob, hash *string, transact func(*bind.TransactOpts) (*types.Transaction, error)) (*types.Receipt, error) {
+	if *hash == "" {
+		tx, err := s.nonces.Transact(ctx, transact)
+		if tx == nil {
+			return nil, err
+		}
+		// A transaction sent is kept even if it was not recorded, and even
+		// once ctx is done, so that resuming the job does not send it again:
+		// waiting for it records it then.
+		*hash = tx.Hash().Hex()
+		if err := s.store.SaveMintJob(context.Background(), j); err != nil {
+			return nil, fmt.Errorf("cannot save job: %w", err)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	wctx, cancel := context.WithTimeout(ctx, s.cfg.ReceiptTimeout)
+	defer cancel()
+	rcpt, err := s.nonces.Wait(wctx, common.HexToHash(*hash))
+	switch {
+	case errors.Is(err, nonce.ErrCancelled) || errors.Is(err, nonce.ErrDropped):
+	case err != nil:
+		return nil, fmt.Errorf("%s not mined: %w", *hash, err)
+	case rcpt.Status != types.ReceiptStatusSuccessful:
+		err = errReverted
+	default:
+		*hash = rcpt.TxHash.Hex()
+		return rcpt, nil
+	}
+	err = fmt.Errorf("%s: %w", *hash, err)
+	*hash = ""
+	return nil, err
+}
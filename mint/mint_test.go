@@ -0,0 +1,521 @@
+package mint
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"blockchain.com/indexer/contracts/marketplace"
+	"blockchain.com/indexer/contracts/nft"
+	"blockchain.com/indexer/model"
+	"blockchain.com/indexer/nonce"
+	"blockchain.com/indexer/signer"
+	"blockchain.com/indexer/store"
+	"blockchain.com/indexer/store/memory"
+)
+
+const testChainID = 1337
+
+var (
+	testMarket = common.HexToAddress("0x00000000000000000000000000000000000000aa")
+	testNFT    = common.HexToAddress("0x00000000000000000000000000000000000000bb")
+	listingFee = big.NewInt(25)
+)
+
+// node runs the NFT contract and the market for one sender. Transactions
+// are mined as they are sent, unless their method is held; their method is
+// the name of the contract function called, or "cancel" for an empty call.
+type node struct {
+	t       *testing.T
+	nftA    abi.ABI
+	marketA abi.ABI
+	sender  common.Address
+
+	mu       sync.Mutex
+	mempool  map[common.Hash]*types.Transaction
+	included map[common.Hash]*types.Transaction
+	receipts map[common.Hash]*types.Receipt
+	nonce    uint64
+	block    int64
+	tokens   int64
+	items    int64
+	approved map[int64]common.Address
+	// approvedForAll makes the market an operator of the sender.
+	approvedForAll bool
+
+	// hold keeps the transactions of a method in the mempool; reject and
+	// revert count the next sends of a method rejected by the node and the
+	// next transactions of a method reverting.
+	hold   map[string]bool
+	reject map[string]int
+	revert map[string]int
+	// sent counts the transactions accepted by method.
+	sent map[string]int
+}
+
+func newNode(t *testing.T, sender common.Address) *node {
+	nftA, err := abi.JSON(strings.NewReader(nft.MainABI))
+	if err != nil {
+		t.Fatal(err)
+	}
+	marketA, err := abi.JSON(strings.NewReader(marketplace.MainABI))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &node{
+		t:        t,
+		nftA:     nftA,
+		marketA:  marketA,
+		sender:   sender,
+		mempool:  make(map[common.Hash]*types.Transaction),
+		included: make(map[common.Hash]*types.Transaction),
+		receipts: make(map[common.Hash]*types.Receipt),
+		approved: make(map[int64]common.Address),
+		hold:     make(map[string]bool),
+		reject:   make(map[string]int),
+		revert:   make(map[string]int),
+		sent:     make(map[string]int),
+	}
+}
+
+// method returns the function data calls and its arguments.
+func (n *node) method(data []byte) (*abi.Method, []interface{}) {
+	if len(data) < 4 {
+		return nil, nil
+	}
+	for _, a := range []abi.ABI{n.nftA, n.marketA} {
+		if m, err := a.MethodById(data[:4]); err == nil {
+			args, err := m.Inputs.Unpack(data[4:])
+			if err != nil {
+				n.t.Errorf("%s: %v", m.Name, err)
+			}
+			return m, args
+		}
+	}
+	n.t.Errorf("unknown method %x", data[:4])
+	return nil, nil
+}
+
+func methodName(m *abi.Method) string {
+	if m == nil {
+		return "cancel"
+	}
+	return m.Name
+}
+
+// eventLog returns the log of event name of a emitted by addr with args, in
+// the order of the event inputs.
+func (n *node) eventLog(a abi.ABI, name string, addr common.Address, args ...interface{}) *types.Log {
+	ev := a.Events[name]
+	l := &types.Log{Address: addr, Topics: []common.Hash{ev.ID}}
+	var data []interface{}
+	for i, in := range ev.Inputs {
+		switch v := args[i].(type) {
+		case common.Address:
+			if in.Indexed {
+				l.Topics = append(l.Topics, common.BytesToHash(v.Bytes()))
+				continue
+			}
+		case *big.Int:
+			if in.Indexed {
+				l.Topics = append(l.Topics, common.BigToHash(v))
+				continue
+			}
+		}
+		data = append(data, args[i])
+	}
+	packed, err := ev.Inputs.NonIndexed().Pack(data...)
+	if err != nil {
+		n.t.Error(err)
+	}
+	l.Data = packed
+	return l
+}
+
+// mine mines tx in a new block. n.mu must be held.
+func (n *node) mine(tx *types.Transaction) {
+	m, args := n.method(tx.Data())
+	name := methodName(m)
+	n.block++
+	n.nonce = tx.Nonce() + 1
+	for h, other := range n.mempool {
+		if other.Nonce() == tx.Nonce() {
+			delete(n.mempool, h)
+		}
+	}
+	rcpt := &types.Receipt{
+		Status:      types.ReceiptStatusSuccessful,
+		TxHash:      tx.Hash(),
+		BlockNumber: big.NewInt(n.block),
+		BlockHash:   common.BigToHash(big.NewInt(n.block)),
+		GasUsed:     21000,
+	}
+	n.included[tx.Hash()] = tx
+	n.receipts[tx.Hash()] = rcpt
+	if n.revert[name] > 0 {
+		n.revert[name]--
+		rcpt.Status = types.ReceiptStatusFailed
+		return
+	}
+	switch name {
+	case "createToken":
+		n.tokens++
+		rcpt.Logs = append(rcpt.Logs, n.eventLog(n.nftA, "Transfer", testNFT, common.Address{}, n.sender, big.NewInt(n.tokens)))
+	case "approve":
+		to, token := args[0].(common.Address), args[1].(*big.Int)
+		n.approved[token.Int64()] = to
+		rcpt.Logs = append(rcpt.Logs, n.eventLog(n.nftA, "Approval", testNFT, n.sender, to, token))
+	case "createMarketItem":
+		contract, token, price := args[0].(common.Address), args[1].(*big.Int), args[2].(*big.Int)
+		if tx.Value().Cmp(listingFee) != 0 || n.approved[token.Int64()] != testMarket && !n.approvedForAll {
+			rcpt.Status = types.ReceiptStatusFailed
+			return
+		}
+		n.items++
+		rcpt.Logs = append(rcpt.Logs, n.eventLog(n.marketA, "MarketItemCreated", testMarket,
+			big.NewInt(n.items), contract, token, n.sender, common.Address{}, price, false))
+	}
+}
+
+// release mines the transactions held in the mempool.
+func (n *node) release() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.hold = make(map[string]bool)
+	for _, tx := range n.mempool {
+		n.mine(tx)
+	}
+}
+
+func (n *node) sends() string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return fmt.Sprint(n.sent)
+}
+
+func (n *node) CodeAt(ctx context.Context, contract common.Address, blockNumber *big.Int) ([]byte, error) {
+	return []byte{1}, nil
+}
+
+func (n *node) CallContract(ctx context.Context, call ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	m, args := n.method(call.Data)
+	switch methodName(m) {
+	case "getApproved":
+		return m.Outputs.Pack(n.approved[args[0].(*big.Int).Int64()])
+	case "isApprovedForAll":
+		return m.Outputs.Pack(n.approvedForAll && args[1].(common.Address) == testMarket)
+	case "getListingPrice":
+		return m.Outputs.Pack(listingFee)
+	}
+	return nil, fmt.Errorf("unexpected call of %s", methodName(m))
+}
+
+func (n *node) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if number == nil {
+		number = big.NewInt(n.block)
+	}
+	return &types.Header{Number: number, BaseFee: big.NewInt(10)}, nil
+}
+
+func (n *node) PendingCodeAt(ctx context.Context, account common.Address) ([]byte, error) {
+	return []byte{1}, nil
+}
+
+func (n *node) NonceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (uint64, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.nonce, nil
+}
+
+func (n *node) PendingNonceAt(ctx context.Context, account common.Address) (uint64, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	pending := n.nonce
+	for _, tx := range n.mempool {
+		if tx.Nonce() >= pending {
+			pending = tx.Nonce() + 1
+		}
+	}
+	return pending, nil
+}
+
+func (n *node) SuggestGasPrice(ctx context.Context) (*big.Int, error) {
+	return big.NewInt(11), nil
+}
+
+func (n *node) SuggestGasTipCap(ctx context.Context) (*big.Int, error) {
+	return big.NewInt(1), nil
+}
+
+func (n *node) EstimateGas(ctx context.Context, call ethereum.CallMsg) (uint64, error) {
+	return 100000, nil
+}
+
+func (n *node) SendTransaction(ctx context.Context, tx *types.Transaction) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if tx.Nonce() < n.nonce {
+		return errors.New("nonce too low")
+	}
+	m, _ := n.method(tx.Data())
+	name := methodName(m)
+	if n.reject[name] > 0 {
+		n.reject[name]--
+		return errors.New("insufficient funds for gas * price + value")
+	}
+	n.sent[name]++
+	if n.hold[name] {
+		n.mempool[tx.Hash()] = tx
+		return nil
+	}
+	n.mine(tx)
+	return nil
+}
+
+func (n *node) TransactionByHash(ctx context.Context, hash common.Hash) (*types.Transaction, bool, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if tx, ok := n.mempool[hash]; ok {
+		return tx, true, nil
+	}
+	if tx, ok := n.included[hash]; ok {
+		return tx, false, nil
+	}
+	return nil, false, ethereum.NotFound
+}
+
+func (n *node) TransactionReceipt(ctx context.Context, hash common.Hash) (*types.Receipt, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if r, ok := n.receipts[hash]; ok {
+		return r, nil
+	}
+	return nil, ethereum.NotFound
+}
+
+func (n *node) FilterLogs(ctx context.Context, q ethereum.FilterQuery) ([]types.Log, error) {
+	return nil, nil
+}
+
+func (n *node) SubscribeFilterLogs(ctx context.Context, q ethereum.FilterQuery, ch chan<- types.Log) (ethereum.Subscription, error) {
+	return nil, errors.New("not supported")
+}
+
+type fixture struct {
+	store  store.Store
+	node   *node
+	signer signer.Signer
+	nonces *nonce.Manager
+	svc    *Service
+}
+
+func newFixture(t *testing.T) *fixture {
+	sig, err := signer.FromHex("0x4c0883a69102937d6231471b5dbb6204fe5129617082792ae468d01a3f362318", big.NewInt(testChainID), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fixture{store: memory.New(), node: newNode(t, sig.Address()), signer: sig}
+	f.nonces = nonce.NewManager(f.store, f.node, sig, nonce.Config{
+		ChainID:         testChainID,
+		PollInterval:    5 * time.Millisecond,
+		StuckAfter:      time.Nanosecond,
+		MaxReplacements: 1,
+	})
+	f.svc = NewService(f.store, f.node, f.nonces, Config{
+		ChainID:        testChainID,
+		Market:         testMarket.Hex(),
+		NftContract:    testNFT.Hex(),
+		ReceiptTimeout: 5 * time.Second,
+	})
+	return f
+}
+
+// process runs the job until it is done or failed and returns it as saved.
+func (f *fixture) process(t *testing.T, j *model.MintJob) *model.MintJob {
+	t.Helper()
+	ctx := context.Background()
+	if err := f.svc.Process(ctx, j); err != nil {
+		t.Fatal(err)
+	}
+	saved, err := f.store.MintJob(ctx, testChainID, j.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return saved
+}
+
+// resume resumes a failed job and runs it.
+func (f *fixture) resume(t *testing.T, j *model.MintJob) *model.MintJob {
+	t.Helper()
+	j, err := f.svc.Resume(context.Background(), j.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f.process(t, j)
+}
+
+// checkDone checks that j minted token 1 and listed it as item 1.
+func checkDone(t *testing.T, name string, j *model.MintJob) {
+	t.Helper()
+	if j.Status != model.MintDone || j.Step != model.MintStepDone || j.Error != "" {
+		t.Fatalf("%s: job %s at %s: %s, want done", name, j.Status, j.Step, j.Error)
+	}
+	if j.TokenID == nil || j.TokenID.Int64() != 1 || j.ItemID == nil || j.ItemID.Int64() != 1 ||
+		j.ListingFee == nil || j.ListingFee.Cmp(listingFee) != 0 || j.MintTx == "" || j.ListTx == "" {
+		t.Errorf("%s: job %+v, want token 1 listed as item 1", name, j)
+	}
+}
+
+func TestProcess(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(n *node)
+		// failedAt is the step the job first fails at, if it does; it is
+		// then resumed.
+		failedAt model.MintStep
+		sent     string
+	}{
+		{"all steps", func(*node) {}, "", "map[approve:1 createMarketItem:1 createToken:1]"},
+		{"already approved", func(n *node) { n.approvedForAll = true }, "", "map[createMarketItem:1 createToken:1]"},
+		{"approve rejected", func(n *node) { n.reject["approve"] = 1 }, model.MintStepApprove, "map[approve:1 createMarketItem:1 createToken:1]"},
+		{"approve reverted", func(n *node) { n.revert["approve"] = 1 }, model.MintStepApprove, "map[approve:2 createMarketItem:1 createToken:1]"},
+		{"list reverted", func(n *node) { n.revert["createMarketItem"] = 1 }, model.MintStepList, "map[approve:1 createMarketItem:2 createToken:1]"},
+		{"mint reverted", func(n *node) { n.revert["createToken"] = 1 }, model.MintStepMint, "map[approve:1 createMarketItem:1 createToken:2]"},
+	}
+	for _, tt := range tests {
+		f := newFixture(t)
+		tt.setup(f.node)
+		j, err := f.svc.Submit(context.Background(), "ipfs://token", big.NewInt(1000), "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		j = f.process(t, j)
+		if tt.failedAt != "" {
+			if j.Status != model.MintFailed || j.Step != tt.failedAt || j.Error == "" {
+				t.Fatalf("%s: job %s at %s, want failed at %s", tt.name, j.Status, j.Step, tt.failedAt)
+			}
+			// The transaction of the failed step is forgotten; those of
+			// the steps before are kept.
+			txs := map[model.MintStep]string{model.MintStepMint: j.MintTx, model.MintStepApprove: j.ApproveTx, model.MintStepList: j.ListTx}
+			for _, step := range []model.MintStep{model.MintStepMint, model.MintStepApprove, model.MintStepList} {
+				if step == tt.failedAt {
+					if txs[step] != "" {
+						t.Errorf("%s: failed job kept the %s tx %s", tt.name, step, txs[step])
+					}
+					break
+				}
+				if txs[step] == "" {
+					t.Errorf("%s: failed job lost the %s tx", tt.name, step)
+				}
+			}
+			j = f.resume(t, j)
+		}
+		checkDone(t, tt.name, j)
+		if (j.ApproveTx == "") != f.node.approvedForAll {
+			t.Errorf("%s: approve tx %q", tt.name, j.ApproveTx)
+		}
+		if got := f.node.sends(); got != tt.sent {
+			t.Errorf("%s: sent %s, want %s", tt.name, got, tt.sent)
+		}
+	}
+}
+
+func TestProcessCancelledList(t *testing.T) {
+	f := newFixture(t)
+	f.node.hold["createMarketItem"] = true
+	j, err := f.svc.Submit(context.Background(), "ipfs://token", big.NewInt(1000), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The listing stays pending: the nonce manager replaces it once, then
+	// cancels it.
+	done := make(chan *model.MintJob, 1)
+	go func(j *model.MintJob) { done <- f.process(t, j) }(j)
+	for j = nil; j == nil; {
+		select {
+		case j = <-done:
+		case <-time.After(5 * time.Millisecond):
+			if err := f.nonces.Check(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if j.Status != model.MintFailed || j.Step != model.MintStepList || !strings.Contains(j.Error, nonce.ErrCancelled.Error()) {
+		t.Fatalf("job %s at %s: %s, want failed at list by the cancellation", j.Status, j.Step, j.Error)
+	}
+	if j.ListTx != "" || j.ItemID != nil {
+		t.Errorf("cancelled job kept list tx %q, item %v", j.ListTx, j.ItemID)
+	}
+	if got := f.node.sends(); got != "map[approve:1 cancel:1 createMarketItem:2 createToken:1]" {
+		t.Errorf("sent %s, want the listing, its replacement and its cancellation", got)
+	}
+
+	f.node.release()
+	checkDone(t, "resumed", f.resume(t, j))
+	if got := f.node.sends(); got != "map[approve:1 cancel:1 createMarketItem:3 createToken:1]" {
+		t.Errorf("sent %s after resuming, want the listing sent again", got)
+	}
+}
+
+func TestResumeNotRecorded(t *testing.T) {
+	ctx := context.Background()
+	f := newFixture(t)
+	// The mint was sent but recording it failed: the job failed with its
+	// hash only.
+	opts := signer.TransactOpts(ctx, f.signer)
+	opts.Nonce = big.NewInt(0)
+	tr, err := nft.NewMainTransactor(testNFT, f.node)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx, err := tr.CreateToken(opts, "ipfs://token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	j := &model.MintJob{
+		ChainID:     testChainID,
+		Market:      testMarket.Hex(),
+		NftContract: testNFT.Hex(),
+		Sender:      f.svc.Sender(),
+		TokenURI:    "ipfs://token",
+		Price:       model.NewBigInt(big.NewInt(1000)),
+		Step:        model.MintStepMint,
+		Status:      model.MintFailed,
+		Error:       "sent but not recorded",
+		MintTx:      tx.Hash().Hex(),
+	}
+	if err := f.store.SaveMintJob(ctx, j); err != nil {
+		t.Fatal(err)
+	}
+
+	checkDone(t, "resumed", f.resume(t, j))
+	if got := f.node.sends(); got != "map[approve:1 createMarketItem:1 createToken:1]" {
+		t.Errorf("sent %s, want the mint sent once", got)
+	}
+	if rec, err := f.store.Transaction(ctx, testChainID, tx.Hash().Hex()); err != nil || !rec.Sent {
+		t.Errorf("mint transaction %+v, %v, want it recorded", rec, err)
+	}
+}
+
+// Compile-time checks that the node serves both the bindings and the nonce
+// manager.
+var (
+	_ bind.ContractBackend = (*node)(nil)
+	_ nonce.Backend        = (*node)(nil)
+)
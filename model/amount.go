@@ -0,0 +1,48 @@
+package model
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/big"
+)
+
+// Amount is a wei amount in the expanded form returned by the API for
+// headline figures: the exact value as a decimal string, plus the value
+// formatted in ether and in hex for display.
+//
+// Decoding accepts the expanded form as well as anything BigInt accepts.
+// Only Wei is read from the expanded form.
+type Amount struct {
+	Wei   BigInt `json:"wei"`
+	Ether string `json:"ether"`
+	Hex   string `json:"hex"`
+}
+
+func NewAmount(x *big.Int) Amount {
+	wei := NewBigInt(x)
+	return Amount{Wei: wei, Ether: wei.Ether(), Hex: wei.Hex()}
+}
+
+// Big returns the amount in wei.
+func (a *Amount) Big() *big.Int {
+	return a.Wei.Big()
+}
+
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		var v struct {
+			Wei BigInt `json:"wei"`
+		}
+		if err := json.Unmarshal(data, &v); err != nil {
+			return err
+		}
+		*a = NewAmount(v.Wei.Big())
+		return nil
+	}
+	var wei BigInt
+	if err := wei.UnmarshalJSON(data); err != nil {
+		return err
+	}
+	*a = NewAmount(wei.Big())
+	return nil
+}
@@ -0,0 +1,127 @@
+package model
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// BigInt is a uint256 value (token ids, wei amounts) stored as NUMERIC.
+//
+// It is encoded in JSON as a decimal string, since JSON numbers lose
+// precision past 2^53 in JavaScript clients. Decoding also accepts JSON
+// numbers and 0x-prefixed hex strings.
+type BigInt struct {
+	big.Int
+}
+
+func NewBigInt(x *big.Int) BigInt {
+	var b BigInt
+	if x != nil {
+		b.Set(x)
+	}
+	return b
+}
+
+// Big returns the underlying value.
+func (b *BigInt) Big() *big.Int {
+	return &b.Int
+}
+
+func (b BigInt) Value() (driver.Value, error) {
+	return b.Int.String(), nil
+}
+
+func (b *BigInt) Scan(src interface{}) error {
+	var s string
+	switch v := src.(type) {
+	case string:
+		s = v
+	case []byte:
+		s = string(v)
+	case int64:
+		b.SetInt64(v)
+		return nil
+	case nil:
+		b.SetInt64(0)
+		return nil
+	default:
+		return fmt.Errorf("cannot scan %T into BigInt", src)
+	}
+	if _, ok := b.SetString(s, 10); !ok {
+		return fmt.Errorf("invalid numeric value %q", s)
+	}
+	return nil
+}
+
+// ParseBigInt parses a decimal or 0x-prefixed hex integer.
+func ParseBigInt(s string) (BigInt, error) {
+	var b BigInt
+	if strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X") {
+		x, err := hexutil.DecodeBig(s)
+		if err != nil {
+			return b, fmt.Errorf("invalid hex integer %q: %v", s, err)
+		}
+		b.Set(x)
+		return b, nil
+	}
+	if _, ok := b.SetString(s, 10); !ok {
+		return b, fmt.Errorf("invalid integer %q", s)
+	}
+	return b, nil
+}
+
+// Hex returns the value as a 0x-prefixed hex string.
+func (b BigInt) Hex() string {
+	return hexutil.EncodeBig(&b.Int)
+}
+
+// Ether formats a wei amount in ether, without trailing zeros.
+func (b BigInt) Ether() string {
+	return FormatUnits(&b.Int, 18)
+}
+
+// FormatUnits formats x as a decimal number with the given number of
+// decimals, without trailing zeros.
+func FormatUnits(x *big.Int, decimals int) string {
+	s := new(big.Int).Abs(x).String()
+	if len(s) <= decimals {
+		s = strings.Repeat("0", decimals-len(s)+1) + s
+	}
+	whole, frac := s[:len(s)-decimals], strings.TrimRight(s[len(s)-decimals:], "0")
+	if x.Sign() < 0 {
+		whole = "-" + whole
+	}
+	if frac == "" {
+		return whole
+	}
+	return whole + "." + frac
+}
+
+func (b BigInt) MarshalJSON() ([]byte, error) {
+	return json.Marshal(b.Int.String())
+}
+
+func (b *BigInt) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+	if len(data) > 0 && data[0] == '"' {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		v, err := ParseBigInt(s)
+		if err != nil {
+			return err
+		}
+		*b = v
+		return nil
+	}
+	return b.Int.UnmarshalJSON(data)
+}
@@ -0,0 +1,20 @@
+package model
+
+import (
+	"time"
+)
+
+// Block is the header of an indexed block. Blocks are kept for every block
+// that produced records and for the end of every indexed range, so the
+// indexer can detect reorganizations and the API can report block times.
+type Block struct {
+	ChainID    uint64    `gorm:"primary_key" json:"chain_id"`
+	Number     uint64    `gorm:"primary_key" json:"number"`
+	Hash       string    `gorm:"not null" json:"hash"`
+	ParentHash string    `gorm:"not null" json:"parent_hash"`
+	Time       time.Time `gorm:"not null" json:"time"`
+}
+
+func (Block) TableName() string {
+	return "block"
+}
@@ -0,0 +1,19 @@
+package model
+
+import (
+	"time"
+)
+
+// Checkpoint is the last block whose events have been fully indexed for a
+// contract.
+type Checkpoint struct {
+	ChainID     uint64    `gorm:"primary_key" json:"chain_id"`
+	Contract    string    `gorm:"primary_key" json:"contract"`
+	BlockNumber uint64    `gorm:"not null" json:"block_number"`
+	BlockHash   string    `gorm:"not null" json:"block_hash"`
+	UpdatedAt   time.Time `gorm:"default:now()" json:"updated_at"`
+}
+
+func (Checkpoint) TableName() string {
+	return "checkpoint"
+}
@@ -0,0 +1,20 @@
+package model
+
+import (
+	"time"
+)
+
+// Contract is a contract on the indexer's watch list.
+type Contract struct {
+	ChainID    uint64    `gorm:"primary_key" json:"chain_id"`
+	Address    string    `gorm:"primary_key" json:"address"`
+	ABI        string    `gorm:"column:abi;not null" json:"abi"`
+	StartBlock uint64    `gorm:"not null" json:"start_block"`
+	Label      string    `gorm:"not null" json:"label"`
+	CreatedAt  time.Time `gorm:"default:now()" json:"created_at"`
+	UpdatedAt  time.Time `gorm:"default:now()" json:"updated_at"`
+}
+
+func (Contract) TableName() string {
+	return "contract"
+}
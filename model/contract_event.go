@@ -0,0 +1,26 @@
+package model
+
+import (
+	"time"
+)
+
+// ContractEvent is a log decoded with a registered ABI for which the indexer
+// has no dedicated record type.
+type ContractEvent struct {
+	ID          int       `gorm:"primary_key;AUTO_INCREMENT" json:"id"`
+	ChainID     uint64    `gorm:"not null" json:"chain_id"`
+	Contract    string    `gorm:"not null" json:"contract"`
+	ABI         string    `gorm:"column:abi;not null" json:"abi"`
+	Event       string    `gorm:"not null" json:"event"`
+	Signature   string    `gorm:"not null" json:"signature"`
+	Args        string    `gorm:"type:jsonb;not null" json:"args"`
+	BlockNumber uint64    `gorm:"not null" json:"block_number"`
+	BlockHash   string    `gorm:"not null" json:"block_hash"`
+	TxHash      string    `gorm:"not null" json:"tx_hash"`
+	LogIndex    uint      `gorm:"not null" json:"log_index"`
+	CreatedAt   time.Time `gorm:"default:now()" json:"created_at"`
+}
+
+func (ContractEvent) TableName() string {
+	return "contract_event"
+}
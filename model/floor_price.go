@@ -0,0 +1,43 @@
+package model
+
+import (
+	"time"
+)
+
+// FloorPrice is the floor price and the number of active listings of an NFT
+// contract on a market after a block with listings or sales of it. Floor is
+// nil when nothing is listed.
+type FloorPrice struct {
+	ChainID     uint64    `gorm:"primary_key" json:"chain_id"`
+	Market      string    `gorm:"primary_key" json:"market"`
+	NftContract string    `gorm:"primary_key" json:"nft_contract"`
+	BlockNumber uint64    `gorm:"primary_key" json:"block_number"`
+	BlockTime   time.Time `gorm:"not null" json:"block_time"`
+	Floor       *BigInt   `gorm:"type:numeric" json:"floor"`
+	Listed      int       `gorm:"not null" json:"listed"`
+}
+
+func (FloorPrice) TableName() string {
+	return "floor_price"
+}
+
+// CollectionStats is the current listing state of an NFT contract on a
+// market. Supply counts the tokens that exist, as far as the contract is
+// indexed; ListedPct is nil while it is unknown.
+type CollectionStats struct {
+	Market      string   `json:"market"`
+	NftContract string   `json:"nft_contract"`
+	Floor       *BigInt  `json:"floor"`
+	Listed      int      `json:"listed"`
+	Supply      int      `json:"supply"`
+	ListedPct   *float64 `json:"listed_pct"`
+}
+
+// SetSupply sets the supply of the collection and the share of it listed.
+func (c *CollectionStats) SetSupply(supply int) {
+	c.Supply, c.ListedPct = supply, nil
+	if supply > 0 {
+		pct := float64(c.Listed) * 100 / float64(supply)
+		c.ListedPct = &pct
+	}
+}
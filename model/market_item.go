@@ -0,0 +1,28 @@
+package model
+
+import (
+	"time"
+)
+
+// MarketItem is a decoded MarketItemCreated event of the NFTMarket contract.
+type MarketItem struct {
+	ID          int       `gorm:"primary_key;AUTO_INCREMENT" json:"id"`
+	ChainID     uint64    `gorm:"not null" json:"chain_id"`
+	Market      string    `gorm:"not null" json:"market"`
+	ItemID      BigInt    `gorm:"type:numeric;not null" json:"item_id"`
+	NftContract string    `gorm:"not null" json:"nft_contract"`
+	TokenID     BigInt    `gorm:"type:numeric;not null" json:"token_id"`
+	Seller      string    `gorm:"not null" json:"seller"`
+	Owner       string    `gorm:"not null" json:"owner"`
+	Price       BigInt    `gorm:"type:numeric;not null" json:"price"`
+	Sold        bool      `gorm:"not null" json:"sold"`
+	BlockNumber uint64    `gorm:"not null" json:"block_number"`
+	BlockHash   string    `gorm:"not null" json:"block_hash"`
+	TxHash      string    `gorm:"not null" json:"tx_hash"`
+	LogIndex    uint      `gorm:"not null" json:"log_index"`
+	CreatedAt   time.Time `gorm:"default:now()" json:"created_at"`
+}
+
+func (MarketItem) TableName() string {
+	return "market_item"
+}
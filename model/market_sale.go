@@ -0,0 +1,29 @@
+package model
+
+import (
+	"time"
+)
+
+// MarketSale is a sale of a market item. NFTMarket emits no event for
+// sales; they are inferred from the Transfer of the token out of the market
+// in a createMarketSale transaction.
+type MarketSale struct {
+	ID          int       `gorm:"primary_key;AUTO_INCREMENT" json:"id"`
+	ChainID     uint64    `gorm:"not null" json:"chain_id"`
+	Market      string    `gorm:"not null" json:"market"`
+	ItemID      BigInt    `gorm:"type:numeric;not null" json:"item_id"`
+	NftContract string    `gorm:"not null" json:"nft_contract"`
+	TokenID     BigInt    `gorm:"type:numeric;not null" json:"token_id"`
+	Seller      string    `gorm:"not null" json:"seller"`
+	Buyer       string    `gorm:"not null" json:"buyer"`
+	Price       BigInt    `gorm:"type:numeric;not null" json:"price"`
+	BlockNumber uint64    `gorm:"not null" json:"block_number"`
+	BlockHash   string    `gorm:"not null" json:"block_hash"`
+	TxHash      string    `gorm:"not null" json:"tx_hash"`
+	LogIndex    uint      `gorm:"not null" json:"log_index"`
+	CreatedAt   time.Time `gorm:"default:now()" json:"created_at"`
+}
+
+func (MarketSale) TableName() string {
+	return "market_sale"
+}
@@ -0,0 +1,54 @@
+package model
+
+import (
+	"time"
+)
+
+// Market activity kinds.
+const (
+	MarketActivityListing = "listing"
+	MarketActivitySale    = "sale"
+)
+
+// MarketStatsHour is the hourly rollup of the listings or sales of an NFT
+// contract on a market, kept up to date on commit and rollback so statistics
+// over long ranges do not aggregate every record. Hour is the start of the
+// hour in UTC. The pg store keeps the price histogram and the participants
+// of every hour beside it, for medians and unique counts.
+type MarketStatsHour struct {
+	ChainID     uint64    `gorm:"primary_key" json:"chain_id"`
+	Kind        string    `gorm:"primary_key" json:"kind"`
+	Market      string    `gorm:"primary_key" json:"market"`
+	NftContract string    `gorm:"primary_key" json:"nft_contract"`
+	Hour        time.Time `gorm:"primary_key" json:"hour"`
+	Count       int       `gorm:"not null" json:"count"`
+	Volume      BigInt    `gorm:"type:numeric;not null" json:"volume"`
+	MinPrice    BigInt    `gorm:"type:numeric;not null" json:"min_price"`
+	MaxPrice    BigInt    `gorm:"type:numeric;not null" json:"max_price"`
+}
+
+func (MarketStatsHour) TableName() string {
+	return "market_stats_hour"
+}
+
+// PriceStats summarizes the listings or sales of a period. Prices are in wei;
+// Median is the lower of the two middle prices when Count is even.
+type PriceStats struct {
+	Count  int    `json:"count"`
+	Volume BigInt `json:"volume"`
+	Min    BigInt `json:"min"`
+	Max    BigInt `json:"max"`
+	Avg    BigInt `json:"avg"`
+	Median BigInt `json:"median"`
+
+	// Buyers is only counted for sales.
+	Buyers  int `json:"unique_buyers,omitempty"`
+	Sellers int `json:"unique_sellers"`
+}
+
+// MarketStats is the market activity of the period starting at Start.
+type MarketStats struct {
+	Start    time.Time  `json:"start"`
+	Listings PriceStats `json:"listings"`
+	Sales    PriceStats `json:"sales"`
+}
@@ -0,0 +1,63 @@
+package model
+
+import (
+	"time"
+)
+
+// MintStep is the next step of a mint job.
+type MintStep string
+
+const (
+	MintStepMint    MintStep = "mint"
+	MintStepApprove MintStep = "approve"
+	MintStepList    MintStep = "list"
+	MintStepDone    MintStep = "done"
+)
+
+type MintStatus string
+
+const (
+	// MintPending jobs are waiting for, or running, their next step.
+	MintPending MintStatus = "pending"
+
+	// MintFailed jobs stopped at Step. Error holds the failure; resuming
+	// the job retries the step.
+	MintFailed MintStatus = "failed"
+
+	// MintDone jobs have minted and listed their token.
+	MintDone MintStatus = "done"
+)
+
+// MintJob is a server-side workflow that mints a token, approves the market
+// for it and lists it. The hash of each step's transaction is saved as soon
+// as it is sent, so that an interrupted job resumes by waiting for it
+// instead of sending it again.
+type MintJob struct {
+	ID          int        `gorm:"primary_key;AUTO_INCREMENT" json:"id"`
+	ChainID     uint64     `gorm:"not null" json:"chain_id"`
+	Market      string     `gorm:"not null" json:"market"`
+	NftContract string     `gorm:"not null" json:"nft_contract"`
+	Sender      string     `gorm:"not null" json:"sender"`
+	TokenURI    string     `gorm:"not null" json:"token_uri"`
+	Price       BigInt     `gorm:"type:numeric;not null" json:"price"`
+	Step        MintStep   `gorm:"not null" json:"step"`
+	Status      MintStatus `gorm:"not null" json:"status"`
+	Error       string     `gorm:"not null" json:"error,omitempty"`
+
+	// TokenID is set once minted, ItemID once listed. ListingFee is the
+	// listing price of the market paid by the listing transaction.
+	TokenID    *BigInt `gorm:"type:numeric" json:"token_id"`
+	ItemID     *BigInt `gorm:"type:numeric" json:"item_id"`
+	ListingFee *BigInt `gorm:"type:numeric" json:"listing_fee"`
+
+	MintTx    string `gorm:"not null" json:"mint_tx,omitempty"`
+	ApproveTx string `gorm:"not null" json:"approve_tx,omitempty"`
+	ListTx    string `gorm:"not null" json:"list_tx,omitempty"`
+
+	CreatedAt time.Time `gorm:"default:now()" json:"created_at"`
+	UpdatedAt time.Time `gorm:"default:now()" json:"updated_at"`
+}
+
+func (MintJob) TableName() string {
+	return "mint_job"
+}
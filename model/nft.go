@@ -0,0 +1,62 @@
+package model
+
+import (
+	"time"
+)
+
+// NFTTransfer is a decoded ERC-721 Transfer event.
+type NFTTransfer struct {
+	ID          int       `gorm:"primary_key;AUTO_INCREMENT" json:"id"`
+	ChainID     uint64    `gorm:"not null" json:"chain_id"`
+	Contract    string    `gorm:"not null" json:"contract"`
+	FromAddress string    `gorm:"not null" json:"from_address"`
+	ToAddress   string    `gorm:"not null" json:"to_address"`
+	TokenID     BigInt    `gorm:"type:numeric;not null" json:"token_id"`
+	BlockNumber uint64    `gorm:"not null" json:"block_number"`
+	BlockHash   string    `gorm:"not null" json:"block_hash"`
+	TxHash      string    `gorm:"not null" json:"tx_hash"`
+	LogIndex    uint      `gorm:"not null" json:"log_index"`
+	CreatedAt   time.Time `gorm:"default:now()" json:"created_at"`
+}
+
+func (NFTTransfer) TableName() string {
+	return "nft_transfer"
+}
+
+// NFTApproval is a decoded ERC-721 Approval event.
+type NFTApproval struct {
+	ID          int       `gorm:"primary_key;AUTO_INCREMENT" json:"id"`
+	ChainID     uint64    `gorm:"not null" json:"chain_id"`
+	Contract    string    `gorm:"not null" json:"contract"`
+	Owner       string    `gorm:"not null" json:"owner"`
+	Approved    string    `gorm:"not null" json:"approved"`
+	TokenID     BigInt    `gorm:"type:numeric;not null" json:"token_id"`
+	BlockNumber uint64    `gorm:"not null" json:"block_number"`
+	BlockHash   string    `gorm:"not null" json:"block_hash"`
+	TxHash      string    `gorm:"not null" json:"tx_hash"`
+	LogIndex    uint      `gorm:"not null" json:"log_index"`
+	CreatedAt   time.Time `gorm:"default:now()" json:"created_at"`
+}
+
+func (NFTApproval) TableName() string {
+	return "nft_approval"
+}
+
+// NFTApprovalForAll is a decoded ERC-721 ApprovalForAll event.
+type NFTApprovalForAll struct {
+	ID          int       `gorm:"primary_key;AUTO_INCREMENT" json:"id"`
+	ChainID     uint64    `gorm:"not null" json:"chain_id"`
+	Contract    string    `gorm:"not null" json:"contract"`
+	Owner       string    `gorm:"not null" json:"owner"`
+	Operator    string    `gorm:"not null" json:"operator"`
+	Approved    bool      `gorm:"not null" json:"approved"`
+	BlockNumber uint64    `gorm:"not null" json:"block_number"`
+	BlockHash   string    `gorm:"not null" json:"block_hash"`
+	TxHash      string    `gorm:"not null" json:"tx_hash"`
+	LogIndex    uint      `gorm:"not null" json:"log_index"`
+	CreatedAt   time.Time `gorm:"default:now()" json:"created_at"`
+}
+
+func (NFTApprovalForAll) TableName() string {
+	return "nft_approval_for_all"
+}
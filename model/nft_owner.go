@@ -0,0 +1,31 @@
+package model
+
+import (
+	"time"
+)
+
+// NFTOwner is the current owner of a token, projected from its Transfer
+// events. Burned tokens keep a row owned by the zero address.
+type NFTOwner struct {
+	ChainID  uint64 `gorm:"primary_key" json:"chain_id"`
+	Contract string `gorm:"primary_key" json:"contract"`
+	TokenID  BigInt `gorm:"primary_key;type:numeric" json:"token_id"`
+	Owner    string `gorm:"not null" json:"owner"`
+
+	// BlockNumber, LogIndex and TxHash locate the Transfer that made Owner
+	// the owner.
+	BlockNumber uint64    `gorm:"not null" json:"block_number"`
+	LogIndex    uint      `gorm:"not null" json:"log_index"`
+	TxHash      string    `gorm:"not null" json:"tx_hash"`
+	UpdatedAt   time.Time `gorm:"not null" json:"updated_at"`
+}
+
+func (NFTOwner) TableName() string {
+	return "nft_owner"
+}
+
+// NFTBalance is the number of tokens of a contract an account owns.
+type NFTBalance struct {
+	Contract string `json:"contract"`
+	Balance  int    `json:"balance"`
+}
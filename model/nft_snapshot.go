@@ -0,0 +1,37 @@
+package model
+
+import (
+	"time"
+)
+
+// NFTSnapshot is a materialized holder set of a contract at a block. The
+// holders at a later block are the snapshot with the transfers after it
+// replayed.
+type NFTSnapshot struct {
+	ChainID     uint64    `gorm:"primary_key" json:"chain_id"`
+	Contract    string    `gorm:"primary_key" json:"contract"`
+	BlockNumber uint64    `gorm:"primary_key" json:"block_number"`
+	Tokens      int       `gorm:"not null" json:"tokens"`
+	CreatedAt   time.Time `gorm:"default:now()" json:"created_at"`
+}
+
+func (NFTSnapshot) TableName() string {
+	return "nft_snapshot"
+}
+
+// NFTSnapshotOwner is a token of a snapshot with the owner it had at the
+// snapshot block. Burned tokens are left out.
+type NFTSnapshotOwner struct {
+	ChainID       uint64 `gorm:"primary_key" json:"chain_id"`
+	Contract      string `gorm:"primary_key" json:"contract"`
+	SnapshotBlock uint64 `gorm:"primary_key" json:"snapshot_block"`
+	TokenID       BigInt `gorm:"primary_key;type:numeric" json:"token_id"`
+	Owner         string `gorm:"not null" json:"owner"`
+	BlockNumber   uint64 `gorm:"not null" json:"block_number"`
+	LogIndex      uint   `gorm:"not null" json:"log_index"`
+	TxHash        string `gorm:"not null" json:"tx_hash"`
+}
+
+func (NFTSnapshotOwner) TableName() string {
+	return "nft_snapshot_owner"
+}
@@ -0,0 +1,54 @@
+package model
+
+import (
+	"time"
+)
+
+// TokenTrait is an attribute of a token, from its resolved metadata. Values
+// of any JSON type are kept in their JSON text form, except strings, which
+// are kept unquoted.
+type TokenTrait struct {
+	ChainID   uint64 `gorm:"primary_key" json:"-"`
+	Contract  string `gorm:"primary_key" json:"-"`
+	TokenID   BigInt `gorm:"primary_key;type:numeric" json:"-"`
+	TraitType string `gorm:"primary_key" json:"trait_type"`
+	Value     string `gorm:"primary_key" json:"value"`
+}
+
+func (TokenTrait) TableName() string {
+	return "token_trait"
+}
+
+// TraitCount is the number of tokens of a collection with a trait value.
+// Tokens without a trait are counted under its empty value.
+type TraitCount struct {
+	ChainID   uint64  `gorm:"primary_key" json:"-"`
+	Contract  string  `gorm:"primary_key" json:"-"`
+	TraitType string  `gorm:"primary_key" json:"trait_type"`
+	Value     string  `gorm:"primary_key" json:"value"`
+	Count     int     `gorm:"not null" json:"count"`
+	Frequency float64 `gorm:"not null" json:"frequency"`
+}
+
+func (TraitCount) TableName() string {
+	return "trait_count"
+}
+
+// TokenRarity is the rarity of a token within its collection. Score is the
+// sum of the inverse frequencies of its traits and Statistical the product
+// of their frequencies. Rank orders tokens by Score, 1 being the rarest;
+// tokens with the same score share a rank.
+type TokenRarity struct {
+	ChainID     uint64    `gorm:"primary_key" json:"-"`
+	Contract    string    `gorm:"primary_key" json:"contract"`
+	TokenID     BigInt    `gorm:"primary_key;type:numeric" json:"token_id"`
+	Score       float64   `gorm:"not null" json:"score"`
+	Statistical float64   `gorm:"not null" json:"statistical"`
+	Rank        int       `gorm:"not null" json:"rank"`
+	Supply      int       `gorm:"not null" json:"supply"`
+	UpdatedAt   time.Time `gorm:"default:now()" json:"updated_at"`
+}
+
+func (TokenRarity) TableName() string {
+	return "token_rarity"
+}
@@ -0,0 +1,49 @@
+package model
+
+import (
+	"time"
+)
+
+type MetadataStatus string
+
+const (
+	// MetadataPending metadata is waiting for its first or next attempt.
+	MetadataPending MetadataStatus = "pending"
+
+	// MetadataResolved metadata was fetched and is valid.
+	MetadataResolved MetadataStatus = "resolved"
+
+	// MetadataFailed metadata could not be resolved within the allowed
+	// attempts. Error holds the last failure.
+	MetadataFailed MetadataStatus = "failed"
+)
+
+// TokenMetadata is the ERC-721 metadata of a token, resolved from its
+// tokenURI. Attributes is the JSON array of its attributes and Raw the
+// document as fetched.
+type TokenMetadata struct {
+	ChainID     uint64         `gorm:"primary_key" json:"chain_id"`
+	Contract    string         `gorm:"primary_key" json:"contract"`
+	TokenID     BigInt         `gorm:"primary_key;type:numeric" json:"token_id"`
+	URI         string         `gorm:"not null" json:"uri"`
+	Status      MetadataStatus `gorm:"not null" json:"status"`
+	Name        string         `gorm:"not null" json:"name"`
+	Description string         `gorm:"not null" json:"description"`
+	Image       string         `gorm:"not null" json:"image"`
+	Attributes  string         `gorm:"type:jsonb;not null" json:"attributes"`
+	Raw         string         `gorm:"not null" json:"raw"`
+	Error       string         `gorm:"not null" json:"error,omitempty"`
+
+	// Attempts counts the failed attempts; NextAttemptAt is when pending
+	// metadata is due.
+	Attempts      int       `gorm:"not null" json:"attempts"`
+	NextAttemptAt time.Time `gorm:"not null" json:"next_attempt_at"`
+
+	ResolvedAt *time.Time `json:"resolved_at"`
+	CreatedAt  time.Time  `gorm:"default:now()" json:"created_at"`
+	UpdatedAt  time.Time  `gorm:"default:now()" json:"updated_at"`
+}
+
+func (TokenMetadata) TableName() string {
+	return "token_metadata"
+}
@@ -4,13 +4,55 @@ import (
 	"time"
 )
 
+type TransactionStatus string
+
+const (
+	TransactionStatusPending TransactionStatus = "pending"
+	TransactionStatusSuccess TransactionStatus = "success"
+	TransactionStatusFailed  TransactionStatus = "failed"
+
+	// TransactionStatusReplaced transactions lost their nonce to another
+	// transaction of the same sender, TransactionStatusDropped ones to a
+	// transaction that was not recorded.
+	TransactionStatusReplaced TransactionStatus = "replaced"
+	TransactionStatusDropped  TransactionStatus = "dropped"
+)
+
+// Transaction is an on-chain transaction that emitted indexed events. Events
+// reference it through (chain_id, tx_hash). LogIndex is the first of these
+// events; transactions the server sent have none.
+//
+// Transactions the server sends are recorded as soon as they are sent, marked
+// Sent, with the fields needed to replace them: their gas limit, fee caps
+// and input. ReplacedBy is the hash of the transaction sent to replace one.
 type Transaction struct {
-	ID          int        `gorm:"primary_key;AUTO_INCREMENT" json:"inspection_id"`
-	FromAddress string     `gorm:"not null" json:"from_address"`
-	ToAddress   string     `gorm:"not null" json:"to_address"`
-	CreatedAt   time.Time  `gorm:"default:now()" json:"created_at"`
-	UpdatedAt   time.Time  `gorm:"default:now()" json:"updated_at"`
-	DeletedAt   *time.Time `json:"deleted_at"`
+	ID                int               `gorm:"primary_key;AUTO_INCREMENT" json:"id"`
+	ChainID           uint64            `gorm:"not null;unique_index:transaction_chain_tx_hash_key" json:"chain_id"`
+	TxHash            string            `gorm:"not null;unique_index:transaction_chain_tx_hash_key" json:"tx_hash"`
+	BlockNumber       uint64            `json:"block_number"`
+	BlockHash         string            `json:"block_hash"`
+	TransactionIndex  uint              `json:"transaction_index"`
+	LogIndex          *uint             `json:"log_index"`
+	FromAddress       string            `gorm:"not null" json:"from_address"`
+	ToAddress         string            `gorm:"not null" json:"to_address"`
+	Nonce             uint64            `gorm:"not null" json:"nonce"`
+	Value             BigInt            `gorm:"type:numeric;not null" json:"value"`
+	GasUsed           uint64            `json:"gas_used"`
+	EffectiveGasPrice BigInt            `gorm:"type:numeric" json:"effective_gas_price"`
+	Status            TransactionStatus `gorm:"not null" json:"status"`
+	MethodSelector    string            `json:"method_selector"`
+	MethodName        string            `json:"method_name"`
+
+	GasLimit             uint64  `json:"gas_limit,omitempty"`
+	MaxFeePerGas         *BigInt `gorm:"type:numeric" json:"max_fee_per_gas,omitempty"`
+	MaxPriorityFeePerGas *BigInt `gorm:"type:numeric" json:"max_priority_fee_per_gas,omitempty"`
+	Input                string  `json:"input,omitempty"`
+	ReplacedBy           string  `json:"replaced_by,omitempty"`
+	Sent                 bool    `gorm:"not null" json:"sent,omitempty"`
+
+	CreatedAt time.Time  `gorm:"default:now()" json:"created_at"`
+	UpdatedAt time.Time  `gorm:"default:now()" json:"updated_at"`
+	DeletedAt *time.Time `json:"deleted_at"`
 }
 
 func (Transaction) TableName() string {
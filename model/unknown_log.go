@@ -0,0 +1,26 @@
+package model
+
+import (
+	"time"
+)
+
+// UnknownLog is a log emitted by an indexed contract that could not be
+// decoded into one of the known events. It is kept so it can be decoded once
+// the event is supported.
+type UnknownLog struct {
+	ID          int       `gorm:"primary_key;AUTO_INCREMENT" json:"id"`
+	ChainID     uint64    `gorm:"not null" json:"chain_id"`
+	Contract    string    `gorm:"not null" json:"contract"`
+	Topics      string    `gorm:"not null" json:"topics"`
+	Data        string    `gorm:"not null" json:"data"`
+	Reason      string    `gorm:"not null" json:"reason"`
+	BlockNumber uint64    `gorm:"not null" json:"block_number"`
+	BlockHash   string    `gorm:"not null" json:"block_hash"`
+	TxHash      string    `gorm:"not null" json:"tx_hash"`
+	LogIndex    uint      `gorm:"not null" json:"log_index"`
+	CreatedAt   time.Time `gorm:"default:now()" json:"created_at"`
+}
+
+func (UnknownLog) TableName() string {
+	return "unknown_log"
+}
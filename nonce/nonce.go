@@ -0,0 +1,569 @@
+// Package nonce allocates the nonces of the transactions the server sends
+// and keeps them moving: transactions stuck in the mempool are replaced with
+// higher fees and, past a number of replacements, cancelled.
+package nonce
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"blockchain.com/indexer/model"
+	"blockchain.com/indexer/signer"
+	"blockchain.com/indexer/store"
+)
+
+var (
+	// ErrCancelled is returned by Wait when the nonce of a transaction was
+	// taken by its cancellation.
+	ErrCancelled = errors.New("nonce: transaction cancelled")
+
+	// ErrDropped is returned by Wait when the nonce of a transaction was
+	// taken by a transaction the manager did not send.
+	ErrDropped = errors.New("nonce: transaction dropped")
+)
+
+// Backend is the node the manager sends transactions to.
+type Backend interface {
+	HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error)
+	NonceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (uint64, error)
+	PendingNonceAt(ctx context.Context, account common.Address) (uint64, error)
+	SuggestGasTipCap(ctx context.Context) (*big.Int, error)
+	SendTransaction(ctx context.Context, tx *types.Transaction) error
+	TransactionByHash(ctx context.Context, hash common.Hash) (*types.Transaction, bool, error)
+	TransactionReceipt(ctx context.Context, hash common.Hash) (*types.Receipt, error)
+}
+
+type Config struct {
+	ChainID uint64
+
+	// PollInterval is how often pending transactions are checked.
+	PollInterval time.Duration
+
+	// StuckAfter is how long a transaction may stay pending before it is
+	// replaced.
+	StuckAfter time.Duration
+
+	// FeeBumpPercent raises both fee caps of a replacement. Nodes reject
+	// replacements raising them by less than 10%.
+	FeeBumpPercent int64
+
+	// MaxReplacements is the number of replacements after which a stuck
+	// transaction is cancelled instead.
+	MaxReplacements int
+
+	// MaxFeePerGas bounds the fee cap of replacements. Transactions that
+	// would need more stay stuck. Nil does not bound it.
+	MaxFeePerGas *big.Int
+}
+
+// Manager sends the transactions of one signer, that is of one sender on one
+// chain. Nonces are allocated locally, so that transactions can be sent
+// concurrently, and reconciled with the pending nonce of the node. Every
+// transaction sent is recorded; Run settles them once mined and replaces
+// the stuck ones.
+//
+// A single manager must run per sender.
+type Manager struct {
+	store   store.Store
+	backend Backend
+	signer  signer.Signer
+	cfg     Config
+
+	// mu serializes sending; next is the next nonce to allocate, unknown
+	// until reconciled.
+	mu   sync.Mutex
+	next *uint64
+
+	// takenMu guards taken, which holds when nonces were first seen mined
+	// without a receipt of any of their transactions.
+	takenMu sync.Mutex
+	taken   map[uint64]time.Time
+}
+
+func NewManager(s store.Store, backend Backend, sig signer.Signer, cfg Config) *Manager {
+	if cfg.PollInterval == 0 {
+		cfg.PollInterval = 5 * time.Second
+	}
+	if cfg.StuckAfter == 0 {
+		cfg.StuckAfter = 3 * time.Minute
+	}
+	if cfg.FeeBumpPercent < 10 {
+		cfg.FeeBumpPercent = 15
+	}
+	if cfg.MaxReplacements == 0 {
+		cfg.MaxReplacements = 3
+	}
+	return &Manager{store: s, backend: backend, signer: sig, cfg: cfg, taken: make(map[uint64]time.Time)}
+}
+
+// Address returns the sender.
+func (m *Manager) Address() common.Address {
+	return m.signer.Address()
+}
+
+// Transact calls transact, which sends a transaction with the bindings, with
+// options allocating it the next nonce, and records the transaction. The
+// nonce is only consumed if the transaction is sent. Recording is retried
+// until ctx is done; a transaction sent but not recorded is returned with
+// the error.
+func (m *Manager) Transact(ctx context.Context, transact func(*bind.TransactOpts) (*types.Transaction, error)) (*types.Transaction, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.reconcile(ctx); err != nil {
+		return nil, err
+	}
+	opts := signer.TransactOpts(ctx, m.signer)
+	opts.Nonce = new(big.Int).SetUint64(*m.next)
+	tx, err := transact(opts)
+	if err != nil {
+		if nonceError(err) {
+			// Someone else sent from the account: start over from the
+			// node's view.
+			m.next = nil
+		}
+		return nil, err
+	}
+	*m.next++
+
+	// The transaction is out: until it is recorded, neither Wait nor Run
+	// can follow it.
+	rec := m.record(tx)
+	for {
+		err := m.store.SaveTransaction(ctx, rec)
+		if err == nil {
+			return tx, nil
+		}
+		log.Printf("nonce: cannot record %s, retrying: %v", rec.TxHash, err)
+		select {
+		case <-ctx.Done():
+			return tx, fmt.Errorf("nonce: %s sent but not recorded: %w", rec.TxHash, err)
+		case <-time.After(m.cfg.PollInterval):
+		}
+	}
+}
+
+// reconcile moves the next nonce up to the pending nonce of the node. It
+// never moves it down: transactions the node dropped are still pending for
+// the manager, which replaces them once stuck.
+func (m *Manager) reconcile(ctx context.Context) error {
+	pending, err := m.backend.PendingNonceAt(ctx, m.Address())
+	if err != nil {
+		return fmt.Errorf("nonce: pending nonce: %w", err)
+	}
+	if m.next == nil || pending > *m.next {
+		m.next = &pending
+	}
+	return nil
+}
+
+// nonceError reports whether err is a node rejecting a nonce as used.
+func nonceError(err error) bool {
+	msg := err.Error()
+	return strings.Contains(msg, "nonce too low") ||
+		strings.Contains(msg, "already known") ||
+		strings.Contains(msg, "replacement transaction underpriced")
+}
+
+// record returns the record of a transaction just sent.
+func (m *Manager) record(tx *types.Transaction) *model.Transaction {
+	feeCap, tipCap := model.NewBigInt(tx.GasFeeCap()), model.NewBigInt(tx.GasTipCap())
+	rec := &model.Transaction{
+		ChainID:              m.cfg.ChainID,
+		TxHash:               tx.Hash().Hex(),
+		FromAddress:          m.Address().Hex(),
+		Nonce:                tx.Nonce(),
+		Value:                model.NewBigInt(tx.Value()),
+		Status:               model.TransactionStatusPending,
+		GasLimit:             tx.Gas(),
+		MaxFeePerGas:         &feeCap,
+		MaxPriorityFeePerGas: &tipCap,
+		Input:                hexutil.Encode(tx.Data()),
+		CreatedAt:            time.Now(),
+	}
+	if to := tx.To(); to != nil {
+		rec.ToAddress = to.Hex()
+	}
+	if len(tx.Data()) >= 4 {
+		rec.MethodSelector = hexutil.Encode(tx.Data()[:4])
+	}
+	return rec
+}
+
+// Wait waits for the nonce of the transaction hash to be mined and returns
+// the receipt of the transaction that took it: hash or one of its
+// replacements. It returns ErrCancelled if that is a cancellation and
+// ErrDropped if the nonce was taken by a transaction it did not send.
+//
+// A transaction sent but not recorded, as Transact can return, is recorded
+// from the node first; it is dropped if the node does not know it.
+func (m *Manager) Wait(ctx context.Context, hash common.Hash) (*types.Receipt, error) {
+	tx, err := m.recorded(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	ticker := time.NewTicker(m.cfg.PollInterval)
+	defer ticker.Stop()
+	for {
+		rcpt, winner, err := m.mined(ctx, tx.Nonce)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case winner != nil && isCancel(winner) && !isCancel(tx):
+			return nil, fmt.Errorf("%w: by %s", ErrCancelled, winner.TxHash)
+		case winner != nil:
+			return rcpt, nil
+		case rcpt != nil:
+			return nil, fmt.Errorf("%w: nonce %d taken by another transaction", ErrDropped, tx.Nonce)
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// recorded returns the record of the transaction hash, recording it from the
+// node if it was not recorded as sent.
+func (m *Manager) recorded(ctx context.Context, hash common.Hash) (*model.Transaction, error) {
+	rec, err := m.store.Transaction(ctx, m.cfg.ChainID, hash.Hex())
+	if err == nil && rec.Sent {
+		return rec, nil
+	}
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		return nil, fmt.Errorf("nonce: %s: %w", hash.Hex(), err)
+	}
+	tx, _, txErr := m.backend.TransactionByHash(ctx, hash)
+	if errors.Is(txErr, ethereum.NotFound) {
+		return nil, fmt.Errorf("%w: %s unknown to the node", ErrDropped, hash.Hex())
+	}
+	if txErr != nil {
+		return nil, fmt.Errorf("nonce: %s: %w", hash.Hex(), txErr)
+	}
+	sent := m.record(tx)
+	if err == nil {
+		// The indexer recorded it once mined: keep what it found.
+		rec.GasLimit, rec.Input = sent.GasLimit, sent.Input
+		rec.MaxFeePerGas, rec.MaxPriorityFeePerGas = sent.MaxFeePerGas, sent.MaxPriorityFeePerGas
+		sent = rec
+	}
+	if err := m.store.SaveTransaction(ctx, sent); err != nil {
+		return nil, fmt.Errorf("nonce: cannot record %s: %w", hash.Hex(), err)
+	}
+	return sent, nil
+}
+
+// mined returns the receipt of the recorded transaction of nonce that was
+// mined, with its record. If the nonce was mined by a transaction that was
+// not recorded, it returns an empty receipt of the block and no record; if
+// it is not mined yet, neither.
+//
+// The mined nonce is read before the receipts, so that a transaction mined
+// in between is not missed. A node can still report a nonce mined before it
+// serves the receipt, so the nonce only counts as taken once it was seen
+// without a receipt for a poll interval.
+func (m *Manager) mined(ctx context.Context, nonce uint64) (*types.Receipt, *model.Transaction, error) {
+	mined, err := m.backend.NonceAt(ctx, m.Address(), nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("nonce: nonce: %w", err)
+	}
+	if mined <= nonce {
+		return nil, nil, nil
+	}
+	txs, err := m.store.SentTransactions(ctx, store.SentTransactionQuery{
+		ChainID: m.cfg.ChainID,
+		From:    m.Address().Hex(),
+		Nonce:   &nonce,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	for i := range txs {
+		rcpt, err := m.backend.TransactionReceipt(ctx, common.HexToHash(txs[i].TxHash))
+		if errors.Is(err, ethereum.NotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("nonce: receipt of %s: %w", txs[i].TxHash, err)
+		}
+		m.takenMu.Lock()
+		delete(m.taken, nonce)
+		m.takenMu.Unlock()
+		return rcpt, &txs[i], nil
+	}
+
+	m.takenMu.Lock()
+	defer m.takenMu.Unlock()
+	since, ok := m.taken[nonce]
+	if !ok {
+		m.taken[nonce] = time.Now()
+		return nil, nil, nil
+	}
+	if time.Since(since) < m.cfg.PollInterval {
+		return nil, nil, nil
+	}
+	delete(m.taken, nonce)
+	return &types.Receipt{}, nil, nil
+}
+
+// isCancel reports whether tx is a cancellation: an empty self-transfer.
+func isCancel(tx *model.Transaction) bool {
+	return tx.ToAddress == tx.FromAddress && tx.Value.Sign() == 0 && (tx.Input == "" || tx.Input == "0x")
+}
+
+// Run settles and replaces the pending transactions of the sender until ctx
+// is cancelled.
+func (m *Manager) Run(ctx context.Context) error {
+	ticker := time.NewTicker(m.cfg.PollInterval)
+	defer ticker.Stop()
+	for {
+		if err := m.Check(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("nonce: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Check settles the pending transactions whose nonce was mined and replaces
+// the stuck ones: those pending for StuckAfter that hold up the next nonce
+// to be mined or that the node no longer knows.
+func (m *Manager) Check(ctx context.Context) error {
+	pending, err := m.store.SentTransactions(ctx, store.SentTransactionQuery{
+		ChainID: m.cfg.ChainID,
+		From:    m.Address().Hex(),
+		Status:  model.TransactionStatusPending,
+	})
+	if err != nil {
+		return err
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+	mined, err := m.backend.NonceAt(ctx, m.Address(), nil)
+	if err != nil {
+		return fmt.Errorf("nonce: %w", err)
+	}
+
+	// The transactions come by nonce, the latest replacement last.
+	for i := 0; i < len(pending); {
+		j := i
+		for j < len(pending) && pending[j].Nonce == pending[i].Nonce {
+			j++
+		}
+		group := pending[i:j]
+		i = j
+
+		if group[0].Nonce < mined {
+			if err := m.settle(ctx, group[0].Nonce); err != nil {
+				return err
+			}
+			continue
+		}
+		latest := &group[len(group)-1]
+		if latest.ReplacedBy != "" || time.Since(latest.CreatedAt) < m.cfg.StuckAfter {
+			continue
+		}
+		if latest.Nonce > mined {
+			_, _, err := m.backend.TransactionByHash(ctx, common.HexToHash(latest.TxHash))
+			if !errors.Is(err, ethereum.NotFound) {
+				// Queued behind a lower nonce, or unknown for now.
+				continue
+			}
+		}
+		if err := m.replace(ctx, latest, len(group) > m.cfg.MaxReplacements); err != nil {
+			log.Printf("nonce: cannot replace %s: %v", latest.TxHash, err)
+		}
+	}
+	return nil
+}
+
+// settle records the outcome of the transactions of a mined nonce: the one
+// mined succeeded or failed, the others were replaced, or all were dropped
+// if the nonce was taken by a transaction that was not recorded.
+func (m *Manager) settle(ctx context.Context, nonce uint64) error {
+	rcpt, winner, err := m.mined(ctx, nonce)
+	if err != nil || rcpt == nil {
+		// Without a receipt yet, the nonce is settled on a later check.
+		return err
+	}
+	txs, err := m.store.SentTransactions(ctx, store.SentTransactionQuery{
+		ChainID: m.cfg.ChainID,
+		From:    m.Address().Hex(),
+		Nonce:   &nonce,
+	})
+	if err != nil {
+		return err
+	}
+	for i := range txs {
+		tx := &txs[i]
+		switch {
+		case winner != nil && tx.TxHash == winner.TxHash:
+			tx.Status = model.TransactionStatusSuccess
+			if rcpt.Status != types.ReceiptStatusSuccessful {
+				tx.Status = model.TransactionStatusFailed
+			}
+			tx.BlockNumber = rcpt.BlockNumber.Uint64()
+			tx.BlockHash = rcpt.BlockHash.Hex()
+			tx.TransactionIndex = rcpt.TransactionIndex
+			tx.GasUsed = rcpt.GasUsed
+			head, err := m.backend.HeaderByNumber(ctx, rcpt.BlockNumber)
+			if err != nil {
+				return fmt.Errorf("nonce: block %v: %w", rcpt.BlockNumber, err)
+			}
+			tx.EffectiveGasPrice = model.NewBigInt(effectiveGasPrice(tx, head.BaseFee))
+		case tx.Status != model.TransactionStatusPending:
+			continue
+		case winner != nil:
+			tx.Status = model.TransactionStatusReplaced
+		default:
+			tx.Status = model.TransactionStatusDropped
+		}
+		if err := m.store.SaveTransaction(ctx, tx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// replace sends a replacement of tx with bumped fees: the same transaction
+// or, if cancel is set, an empty self-transfer.
+func (m *Manager) replace(ctx context.Context, tx *model.Transaction, cancel bool) error {
+	head, err := m.backend.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return err
+	}
+	tip, err := m.backend.SuggestGasTipCap(ctx)
+	if err != nil {
+		return err
+	}
+	oldTip, oldCap := new(big.Int), new(big.Int)
+	if tx.MaxPriorityFeePerGas != nil {
+		oldTip = tx.MaxPriorityFeePerGas.Big()
+	}
+	if tx.MaxFeePerGas != nil {
+		oldCap = tx.MaxFeePerGas.Big()
+	}
+	tip = max(tip, m.bump(oldTip))
+	feeCap := m.bump(oldCap)
+	if head.BaseFee != nil {
+		feeCap = max(feeCap, new(big.Int).Add(new(big.Int).Mul(head.BaseFee, common.Big2), tip))
+	}
+	if feeCap.Cmp(tip) < 0 {
+		feeCap = tip
+	}
+	if m.cfg.MaxFeePerGas != nil && feeCap.Cmp(m.cfg.MaxFeePerGas) > 0 {
+		return fmt.Errorf("replacement fee cap %v above the maximum %v", feeCap, m.cfg.MaxFeePerGas)
+	}
+
+	from := m.Address()
+	chainID := new(big.Int).SetUint64(m.cfg.ChainID)
+	var unsigned *types.Transaction
+	if cancel {
+		unsigned = m.newTx(chainID, head, tx.Nonce, &from, new(big.Int), 21000, nil, feeCap, tip)
+	} else {
+		input, err := hexutil.Decode(tx.Input)
+		if err != nil {
+			return fmt.Errorf("input: %w", err)
+		}
+		var to *common.Address
+		if tx.ToAddress != "" {
+			addr := common.HexToAddress(tx.ToAddress)
+			to = &addr
+		}
+		unsigned = m.newTx(chainID, head, tx.Nonce, to, tx.Value.Big(), tx.GasLimit, input, feeCap, tip)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	signed, err := m.signer.SignTx(ctx, unsigned)
+	if err != nil {
+		return err
+	}
+	if err := m.backend.SendTransaction(ctx, signed); err != nil {
+		return err
+	}
+	rec := m.record(signed)
+	if !cancel {
+		rec.MethodName = tx.MethodName
+	}
+	if err := m.store.SaveTransaction(ctx, rec); err != nil {
+		return err
+	}
+	tx.ReplacedBy = rec.TxHash
+	if err := m.store.SaveTransaction(ctx, tx); err != nil {
+		return err
+	}
+	what := "replaced"
+	if cancel {
+		what = "cancelled"
+	}
+	log.Printf("nonce: %s %s (nonce %d) with %s, fee cap %v, tip %v", what, tx.TxHash, tx.Nonce, rec.TxHash, feeCap, tip)
+	return nil
+}
+
+// newTx returns an EIP-1559 transaction, or a legacy one paying feeCap on
+// chains without a base fee.
+func (m *Manager) newTx(chainID *big.Int, head *types.Header, nonce uint64, to *common.Address, value *big.Int, gas uint64, data []byte, feeCap, tip *big.Int) *types.Transaction {
+	if head.BaseFee == nil {
+		return types.NewTx(&types.LegacyTx{Nonce: nonce, To: to, Value: value, Gas: gas, GasPrice: feeCap, Data: data})
+	}
+	return types.NewTx(&types.DynamicFeeTx{
+		ChainID:   chainID,
+		Nonce:     nonce,
+		To:        to,
+		Value:     value,
+		Gas:       gas,
+		GasFeeCap: feeCap,
+		GasTipCap: tip,
+		Data:      data,
+	})
+}
+
+// effectiveGasPrice returns the price per gas tx paid in a block of baseFee.
+// Legacy transactions are recorded with both caps set to their gas price.
+func effectiveGasPrice(tx *model.Transaction, baseFee *big.Int) *big.Int {
+	if tx.MaxFeePerGas == nil || tx.MaxPriorityFeePerGas == nil {
+		return new(big.Int)
+	}
+	feeCap := tx.MaxFeePerGas.Big()
+	if baseFee == nil {
+		return feeCap
+	}
+	price := new(big.Int).Add(tx.MaxPriorityFeePerGas.Big(), baseFee)
+	if price.Cmp(feeCap) > 0 {
+		return feeCap
+	}
+	return price
+}
+
+// bump raises a fee by FeeBumpPercent, rounding up.
+func (m *Manager) bump(fee *big.Int) *big.Int {
+	x := new(big.Int).Mul(fee, big.NewInt(100+m.cfg.FeeBumpPercent))
+	x.Add(x, big.NewInt(99))
+	return x.Div(x, big.NewInt(100))
+}
+
+func max(a, b *big.Int) *big.Int {
+	if a.Cmp(b) >= 0 {
+		return a
+	}
+	return b
+}
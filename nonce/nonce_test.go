@@ -0,0 +1,523 @@
+package nonce
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"blockchain.com/indexer/model"
+	"blockchain.com/indexer/signer"
+	"blockchain.com/indexer/store"
+	"blockchain.com/indexer/store/memory"
+)
+
+const testChainID = 1337
+
+var testContract = common.HexToAddress("0x00000000000000000000000000000000000000bb")
+
+// backend is a node mining what the test tells it to.
+type backend struct {
+	mu       sync.Mutex
+	baseFee  *big.Int
+	tip      *big.Int
+	mined    uint64 // the nonce of the sender in the latest block
+	pending  uint64
+	mempool  map[common.Hash]*types.Transaction
+	included map[common.Hash]*types.Transaction
+	receipts map[common.Hash]*types.Receipt
+	sent     []*types.Transaction
+	block    int64
+}
+
+func newBackend() *backend {
+	return &backend{
+		baseFee:  big.NewInt(10),
+		tip:      big.NewInt(1),
+		mempool:  make(map[common.Hash]*types.Transaction),
+		included: make(map[common.Hash]*types.Transaction),
+		receipts: make(map[common.Hash]*types.Receipt),
+	}
+}
+
+// mine mines tx in a new block. A nil tx takes the next nonce with a
+// transaction that was not recorded.
+func (b *backend) mine(tx *types.Transaction, status uint64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.block++
+	b.mined++
+	if tx == nil {
+		return
+	}
+	b.included[tx.Hash()] = tx
+	b.receipts[tx.Hash()] = &types.Receipt{
+		Status:      status,
+		TxHash:      tx.Hash(),
+		BlockNumber: big.NewInt(b.block),
+		BlockHash:   common.BigToHash(big.NewInt(b.block)),
+		GasUsed:     21000,
+	}
+	for h, other := range b.mempool {
+		if other.Nonce() == tx.Nonce() {
+			delete(b.mempool, h)
+		}
+	}
+}
+
+func (b *backend) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if number == nil {
+		number = big.NewInt(b.block)
+	}
+	return &types.Header{Number: number, BaseFee: b.baseFee}, nil
+}
+
+func (b *backend) NonceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (uint64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.mined, nil
+}
+
+func (b *backend) PendingNonceAt(ctx context.Context, account common.Address) (uint64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.pending < b.mined {
+		return b.mined, nil
+	}
+	return b.pending, nil
+}
+
+func (b *backend) SuggestGasTipCap(ctx context.Context) (*big.Int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return new(big.Int).Set(b.tip), nil
+}
+
+func (b *backend) SendTransaction(ctx context.Context, tx *types.Transaction) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if tx.Nonce() < b.mined {
+		return errors.New("nonce too low")
+	}
+	b.mempool[tx.Hash()] = tx
+	b.sent = append(b.sent, tx)
+	if tx.Nonce() >= b.pending {
+		b.pending = tx.Nonce() + 1
+	}
+	return nil
+}
+
+func (b *backend) TransactionByHash(ctx context.Context, hash common.Hash) (*types.Transaction, bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if tx, ok := b.mempool[hash]; ok {
+		return tx, true, nil
+	}
+	if tx, ok := b.included[hash]; ok {
+		return tx, false, nil
+	}
+	return nil, false, ethereum.NotFound
+}
+
+func (b *backend) TransactionReceipt(ctx context.Context, hash common.Hash) (*types.Receipt, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if r, ok := b.receipts[hash]; ok {
+		return r, nil
+	}
+	return nil, ethereum.NotFound
+}
+
+// call sends a call of the NFT contract with data, as the bindings would.
+func call(data []byte) func(*bind.TransactOpts) (*types.Transaction, error) {
+	return func(opts *bind.TransactOpts) (*types.Transaction, error) {
+		tx := types.NewTx(&types.DynamicFeeTx{
+			ChainID:   big.NewInt(testChainID),
+			Nonce:     opts.Nonce.Uint64(),
+			To:        &testContract,
+			Value:     big.NewInt(5),
+			Gas:       100000,
+			GasFeeCap: big.NewInt(100),
+			GasTipCap: big.NewInt(2),
+			Data:      data,
+		})
+		signed, err := opts.Signer(opts.From, tx)
+		if err != nil {
+			return nil, err
+		}
+		return signed, nil
+	}
+}
+
+type fixture struct {
+	store   store.Store
+	backend *backend
+	m       *Manager
+}
+
+func newFixture(t *testing.T, cfg Config) *fixture {
+	sig, err := signer.FromHex("0x4c0883a69102937d6231471b5dbb6204fe5129617082792ae468d01a3f362318", big.NewInt(testChainID), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.ChainID = testChainID
+	if cfg.PollInterval == 0 {
+		cfg.PollInterval = 10 * time.Millisecond
+	}
+	f := &fixture{store: memory.New(), backend: newBackend()}
+	f.m = NewManager(f.store, f.backend, sig, cfg)
+	return f
+}
+
+// transact sends a call through the manager and the backend.
+func (f *fixture) transact(t *testing.T, data ...byte) *types.Transaction {
+	t.Helper()
+	tx, err := f.m.Transact(context.Background(), func(opts *bind.TransactOpts) (*types.Transaction, error) {
+		tx, err := call(data)(opts)
+		if err != nil {
+			return nil, err
+		}
+		return tx, f.backend.SendTransaction(opts.Context, tx)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tx
+}
+
+func (f *fixture) check(t *testing.T) {
+	t.Helper()
+	if err := f.m.Check(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func (f *fixture) record(t *testing.T, tx *types.Transaction) *model.Transaction {
+	t.Helper()
+	rec, err := f.store.Transaction(context.Background(), testChainID, tx.Hash().Hex())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rec
+}
+
+// lastSent returns the last transaction sent to the backend.
+func (f *fixture) lastSent() *types.Transaction {
+	f.backend.mu.Lock()
+	defer f.backend.mu.Unlock()
+	return f.backend.sent[len(f.backend.sent)-1]
+}
+
+func TestBump(t *testing.T) {
+	m := &Manager{cfg: Config{FeeBumpPercent: 15}}
+	tests := []struct{ fee, want int64 }{
+		{0, 0},
+		{1, 2},
+		{100, 115},
+		{101, 117},
+		{1000000007, 1150000009},
+	}
+	for _, tt := range tests {
+		if got := m.bump(big.NewInt(tt.fee)); got.Int64() != tt.want {
+			t.Errorf("bump(%d) = %v, want %d", tt.fee, got, tt.want)
+		}
+	}
+}
+
+func TestEffectiveGasPrice(t *testing.T) {
+	fee := func(x int64) *model.BigInt { v := model.NewBigInt(big.NewInt(x)); return &v }
+	tests := []struct {
+		name           string
+		feeCap, tipCap *model.BigInt
+		baseFee        *big.Int
+		want           int64
+	}{
+		{"tip under the cap", fee(100), fee(2), big.NewInt(10), 12},
+		{"capped", fee(100), fee(20), big.NewInt(90), 100},
+		{"no base fee", fee(30), fee(30), nil, 30},
+		{"not recorded", nil, nil, big.NewInt(10), 0},
+	}
+	for _, tt := range tests {
+		tx := &model.Transaction{MaxFeePerGas: tt.feeCap, MaxPriorityFeePerGas: tt.tipCap}
+		if got := effectiveGasPrice(tx, tt.baseFee); got.Int64() != tt.want {
+			t.Errorf("%s: %v, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestTransact(t *testing.T) {
+	ctx := context.Background()
+	f := newFixture(t, Config{})
+	a := f.transact(t, 1)
+	b := f.transact(t, 2)
+	// Another process sends from the account: the manager moves past it.
+	f.backend.mu.Lock()
+	f.backend.pending = 5
+	f.backend.mu.Unlock()
+	c := f.transact(t, 3)
+	if a.Nonce() != 0 || b.Nonce() != 1 || c.Nonce() != 5 {
+		t.Errorf("nonces %d, %d, %d, want 0, 1, 5", a.Nonce(), b.Nonce(), c.Nonce())
+	}
+
+	// A transaction that fails to send does not take its nonce.
+	_, err := f.m.Transact(ctx, func(opts *bind.TransactOpts) (*types.Transaction, error) {
+		return nil, errors.New("gas required exceeds allowance")
+	})
+	if err == nil {
+		t.Fatal("no error")
+	}
+	if d := f.transact(t, 4); d.Nonce() != 6 {
+		t.Errorf("nonce %d after a failed send, want 6", d.Nonce())
+	}
+
+	rec := f.record(t, b)
+	if !rec.Sent || rec.Status != model.TransactionStatusPending || rec.Nonce != 1 || rec.GasLimit != 100000 ||
+		rec.Input != "0x02" || rec.MaxFeePerGas.Int64() != 100 || rec.MaxPriorityFeePerGas.Int64() != 2 {
+		t.Errorf("record %+v", rec)
+	}
+}
+
+func TestReplaceAndCancel(t *testing.T) {
+	ctx := context.Background()
+	f := newFixture(t, Config{StuckAfter: time.Nanosecond, MaxReplacements: 2})
+	orig := f.transact(t, 0xab, 0xcd)
+
+	// Two replacements of the same call with bumped fees, then a
+	// cancellation.
+	tests := []struct {
+		baseFee, tip   int64
+		feeCap, tipCap int64
+		cancel         bool
+	}{
+		// The bumped caps are above what the block needs.
+		{10, 1, 115, 3, false},
+		// The base fee rose: the cap follows twice the base fee.
+		{100, 1, 204, 4, false},
+		// The suggested tip rose above the bumped one.
+		{100, 7, 235, 7, true},
+	}
+	prev := orig
+	for i, tt := range tests {
+		f.backend.mu.Lock()
+		f.backend.baseFee, f.backend.tip = big.NewInt(tt.baseFee), big.NewInt(tt.tip)
+		f.backend.mu.Unlock()
+		f.check(t)
+
+		tx := f.lastSent()
+		if tx.Hash() == prev.Hash() {
+			t.Fatalf("check %d: not replaced", i)
+		}
+		if tx.Nonce() != orig.Nonce() || tx.GasFeeCap().Int64() != tt.feeCap || tx.GasTipCap().Int64() != tt.tipCap {
+			t.Errorf("check %d: nonce %d, fee cap %v, tip %v, want %d, %d, %d",
+				i, tx.Nonce(), tx.GasFeeCap(), tx.GasTipCap(), orig.Nonce(), tt.feeCap, tt.tipCap)
+		}
+		if tt.cancel {
+			if *tx.To() != f.m.Address() || tx.Value().Sign() != 0 || len(tx.Data()) != 0 || tx.Gas() != 21000 {
+				t.Errorf("check %d: %v %v %x gas %d, want an empty self-transfer", i, tx.To(), tx.Value(), tx.Data(), tx.Gas())
+			}
+		} else if *tx.To() != testContract || tx.Value().Int64() != 5 || string(tx.Data()) != "\xab\xcd" || tx.Gas() != 100000 {
+			t.Errorf("check %d: %v %v %x gas %d, want the original call", i, tx.To(), tx.Value(), tx.Data(), tx.Gas())
+		}
+		if got := f.record(t, prev).ReplacedBy; got != tx.Hash().Hex() {
+			t.Errorf("check %d: %s replaced by %q, want %s", i, prev.Hash().Hex(), got, tx.Hash().Hex())
+		}
+		// The previous check's fees are its own for the next.
+		f.backend.mu.Lock()
+		f.backend.baseFee = big.NewInt(10)
+		f.backend.mu.Unlock()
+		prev = tx
+	}
+
+	// The cancellation is mined: the call's waiters learn it was cancelled
+	// and Check settles every transaction of the nonce.
+	cancel := prev
+	f.backend.mine(cancel, types.ReceiptStatusSuccessful)
+	if _, err := f.m.Wait(ctx, orig.Hash()); !errors.Is(err, ErrCancelled) {
+		t.Errorf("wait: %v, want %v", err, ErrCancelled)
+	}
+	f.check(t)
+	for _, tx := range f.backend.sent[:3] {
+		if rec := f.record(t, tx); rec.Status != model.TransactionStatusReplaced {
+			t.Errorf("%s: %s, want replaced", tx.Hash().Hex(), rec.Status)
+		}
+	}
+	rec := f.record(t, cancel)
+	if rec.Status != model.TransactionStatusSuccess || rec.BlockNumber != 1 || rec.GasUsed != 21000 || rec.EffectiveGasPrice.Int64() != 17 {
+		t.Errorf("cancellation %+v, want mined in block 1 at 17 wei per gas", rec)
+	}
+	if err := f.m.Check(ctx); err != nil || len(f.backend.sent) != 4 {
+		t.Errorf("check after settling: %v, %d sent", err, len(f.backend.sent))
+	}
+}
+
+func TestReplaceLimits(t *testing.T) {
+	f := newFixture(t, Config{StuckAfter: time.Nanosecond, MaxFeePerGas: big.NewInt(110)})
+	f.transact(t, 1)
+	// A transaction above the mined nonce that the node still holds waits
+	// behind the lower one.
+	queued := f.transact(t, 2)
+	f.backend.mu.Lock()
+	f.backend.baseFee = big.NewInt(1)
+	f.backend.mu.Unlock()
+
+	f.check(t)
+	if n := len(f.backend.sent); n != 2 {
+		t.Errorf("%d sent, want no replacement above the fee limit", n)
+	}
+	f.m.cfg.MaxFeePerGas = nil
+	f.check(t)
+	if n := len(f.backend.sent); n != 3 || f.lastSent().Nonce() != 0 {
+		t.Errorf("%d sent, want only nonce 0 replaced", n)
+	}
+
+	// Once the node forgets it, the queued transaction is sent again.
+	f.backend.mu.Lock()
+	delete(f.backend.mempool, queued.Hash())
+	f.backend.mu.Unlock()
+	f.check(t)
+	if last := f.lastSent(); last.Nonce() != 1 || last.Hash() == queued.Hash() {
+		t.Errorf("last sent nonce %d, want a replacement of nonce 1", last.Nonce())
+	}
+}
+
+func TestWait(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("replacement mined", func(t *testing.T) {
+		f := newFixture(t, Config{StuckAfter: time.Nanosecond})
+		orig := f.transact(t, 1)
+		f.check(t)
+		repl := f.lastSent()
+		f.backend.mine(repl, types.ReceiptStatusFailed)
+		rcpt, err := f.m.Wait(ctx, orig.Hash())
+		if err != nil || rcpt.TxHash != repl.Hash() || rcpt.Status != types.ReceiptStatusFailed {
+			t.Errorf("wait: %v, %v, want the failed receipt of the replacement", rcpt, err)
+		}
+		f.check(t)
+		if rec := f.record(t, repl); rec.Status != model.TransactionStatusFailed {
+			t.Errorf("replacement %s, want failed", rec.Status)
+		}
+	})
+
+	t.Run("receipt late", func(t *testing.T) {
+		f := newFixture(t, Config{PollInterval: 20 * time.Millisecond})
+		tx := f.transact(t, 1)
+		// The node reports the nonce mined before it serves the receipt.
+		f.backend.mine(nil, 0)
+		f.check(t)
+		if rec := f.record(t, tx); rec.Status != model.TransactionStatusPending {
+			t.Errorf("settled as %s without a receipt", rec.Status)
+		}
+		done := make(chan error, 1)
+		go func() {
+			_, err := f.m.Wait(ctx, tx.Hash())
+			done <- err
+		}()
+		time.Sleep(5 * time.Millisecond)
+		f.backend.mu.Lock()
+		f.backend.receipts[tx.Hash()] = &types.Receipt{Status: types.ReceiptStatusSuccessful, TxHash: tx.Hash(), BlockNumber: big.NewInt(1)}
+		f.backend.mu.Unlock()
+		if err := <-done; err != nil {
+			t.Errorf("wait: %v, want the late receipt", err)
+		}
+	})
+
+	t.Run("dropped", func(t *testing.T) {
+		f := newFixture(t, Config{})
+		tx := f.transact(t, 1)
+		// The nonce goes to a transaction of the sender the indexer
+		// recorded but the manager did not send.
+		other := types.NewTx(&types.LegacyTx{Nonce: tx.Nonce(), To: &testContract, Gas: 21000, GasPrice: big.NewInt(1)})
+		err := f.store.Commit(ctx, &store.Batch{Transactions: []model.Transaction{{
+			ChainID:     testChainID,
+			TxHash:      other.Hash().Hex(),
+			FromAddress: f.m.Address().Hex(),
+			Nonce:       tx.Nonce(),
+			BlockNumber: 1,
+			Status:      model.TransactionStatusSuccess,
+		}}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.backend.mine(other, types.ReceiptStatusSuccessful)
+		if _, err := f.m.Wait(ctx, tx.Hash()); !errors.Is(err, ErrDropped) {
+			t.Errorf("wait: %v, want %v", err, ErrDropped)
+		}
+		f.check(t)
+		time.Sleep(f.m.cfg.PollInterval)
+		f.check(t)
+		if rec := f.record(t, tx); rec.Status != model.TransactionStatusDropped {
+			t.Errorf("%s, want dropped", rec.Status)
+		}
+	})
+
+	// sendOnly sends a call the manager does not record, as when recording
+	// failed.
+	sendOnly := func(t *testing.T, f *fixture) *types.Transaction {
+		t.Helper()
+		opts := signer.TransactOpts(ctx, f.m.signer)
+		opts.Nonce = big.NewInt(0)
+		tx, err := call([]byte{1})(opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := f.backend.SendTransaction(ctx, tx); err != nil {
+			t.Fatal(err)
+		}
+		return tx
+	}
+
+	t.Run("not recorded", func(t *testing.T) {
+		f := newFixture(t, Config{})
+		tx := sendOnly(t, f)
+		f.backend.mine(tx, types.ReceiptStatusSuccessful)
+		rcpt, err := f.m.Wait(ctx, tx.Hash())
+		if err != nil || rcpt.TxHash != tx.Hash() {
+			t.Fatalf("wait: %v, %v, want the receipt of %s", rcpt, err, tx.Hash().Hex())
+		}
+		if rec := f.record(t, tx); !rec.Sent || rec.Nonce != 0 || rec.GasLimit != 100000 || rec.Input != "0x01" {
+			t.Errorf("record %+v, want it recorded from the node", rec)
+		}
+	})
+
+	t.Run("indexed only", func(t *testing.T) {
+		f := newFixture(t, Config{})
+		tx := sendOnly(t, f)
+		f.backend.mine(tx, types.ReceiptStatusSuccessful)
+		err := f.store.Commit(ctx, &store.Batch{Transactions: []model.Transaction{{
+			ChainID:     testChainID,
+			TxHash:      tx.Hash().Hex(),
+			FromAddress: f.m.Address().Hex(),
+			Nonce:       tx.Nonce(),
+			BlockNumber: 1,
+			GasUsed:     21000,
+			Status:      model.TransactionStatusSuccess,
+		}}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.m.Wait(ctx, tx.Hash()); err != nil {
+			t.Fatalf("wait: %v, want the receipt of the indexed transaction", err)
+		}
+		rec := f.record(t, tx)
+		if !rec.Sent || rec.Status != model.TransactionStatusSuccess || rec.BlockNumber != 1 || rec.GasUsed != 21000 || rec.GasLimit != 100000 {
+			t.Errorf("record %+v, want the indexed one marked sent", rec)
+		}
+	})
+
+	t.Run("unknown", func(t *testing.T) {
+		f := newFixture(t, Config{})
+		if _, err := f.m.Wait(ctx, common.HexToHash("0x01")); !errors.Is(err, ErrDropped) {
+			t.Errorf("wait: %v, want %v", err, ErrDropped)
+		}
+	})
+}
@@ -0,0 +1,239 @@
+// Package rarity scores the tokens of a collection by the rarity of their
+// traits.
+package rarity
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"blockchain.com/indexer/model"
+	"blockchain.com/indexer/store"
+)
+
+// Table is the rarity of a collection: the traits of its tokens, their
+// frequencies and the score of every token.
+type Table struct {
+	Traits []model.TokenTrait
+	Counts []model.TraitCount
+	Tokens []model.TokenRarity
+}
+
+// attribute is a trait as stored in TokenMetadata.Attributes.
+type attribute struct {
+	TraitType   string      `json:"trait_type"`
+	Value       interface{} `json:"value"`
+	DisplayType string      `json:"display_type"`
+}
+
+// Compute scores the tokens of a collection from their resolved metadata.
+//
+// Every trait type of the collection counts for every token: a token without
+// it has its empty value. Attributes without a trait type and numeric
+// attributes (those with a display type, such as number, boost_number or
+// date) are kept as traits but do not count towards rarity.
+func Compute(chainID uint64, contract string, ms []model.TokenMetadata) (*Table, error) {
+	t := &Table{}
+	supply := len(ms)
+	if supply == 0 {
+		return t, nil
+	}
+
+	// values holds the scored trait values of each token, by trait type.
+	values := make([]map[string][]string, len(ms))
+	counts := make(map[string]map[string]int)
+	for i := range ms {
+		attrs, err := attributes(&ms[i])
+		if err != nil {
+			return nil, fmt.Errorf("rarity: %s #%v: %w", contract, ms[i].TokenID, err)
+		}
+		values[i] = make(map[string][]string)
+		seen := make(map[[2]string]bool)
+		for _, a := range attrs {
+			tr := model.TokenTrait{
+				ChainID:   chainID,
+				Contract:  contract,
+				TokenID:   ms[i].TokenID,
+				TraitType: a.TraitType,
+				Value:     valueText(a.Value),
+			}
+			key := [2]string{tr.TraitType, tr.Value}
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			t.Traits = append(t.Traits, tr)
+			if a.TraitType == "" || a.DisplayType != "" {
+				continue
+			}
+			values[i][tr.TraitType] = append(values[i][tr.TraitType], tr.Value)
+			if counts[tr.TraitType] == nil {
+				counts[tr.TraitType] = make(map[string]int)
+			}
+			counts[tr.TraitType][tr.Value]++
+		}
+	}
+	for i := range values {
+		for typ, byValue := range counts {
+			if len(values[i][typ]) == 0 {
+				byValue[""]++
+			}
+		}
+	}
+
+	types := make([]string, 0, len(counts))
+	for typ, byValue := range counts {
+		types = append(types, typ)
+		for v, n := range byValue {
+			t.Counts = append(t.Counts, model.TraitCount{
+				ChainID:   chainID,
+				Contract:  contract,
+				TraitType: typ,
+				Value:     v,
+				Count:     n,
+				Frequency: float64(n) / float64(supply),
+			})
+		}
+	}
+	sort.Slice(t.Counts, func(i, j int) bool {
+		a, b := &t.Counts[i], &t.Counts[j]
+		if a.TraitType != b.TraitType {
+			return a.TraitType < b.TraitType
+		}
+		return a.Value < b.Value
+	})
+
+	sort.Strings(types)
+
+	now := time.Now()
+	t.Tokens = make([]model.TokenRarity, len(ms))
+	for i := range ms {
+		r := model.TokenRarity{
+			ChainID:     chainID,
+			Contract:    contract,
+			TokenID:     ms[i].TokenID,
+			Statistical: 1,
+			Supply:      supply,
+			UpdatedAt:   now,
+		}
+		// Scores are summed in trait type order so that recomputing an
+		// unchanged collection gives the same scores to the last bit.
+		for _, typ := range types {
+			byValue := counts[typ]
+			vs := values[i][typ]
+			if len(vs) == 0 {
+				vs = []string{""}
+			}
+			for _, v := range vs {
+				f := float64(byValue[v]) / float64(supply)
+				r.Score += 1 / f
+				r.Statistical *= f
+			}
+		}
+		t.Tokens[i] = r
+	}
+	rank(t.Tokens)
+	return t, nil
+}
+
+// rank sets the rank of the tokens by descending score, in place.
+func rank(rs []model.TokenRarity) {
+	order := make([]int, len(rs))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool { return rs[order[i]].Score > rs[order[j]].Score })
+	for i, idx := range order {
+		if i > 0 && rs[idx].Score == rs[order[i-1]].Score {
+			rs[idx].Rank = rs[order[i-1]].Rank
+		} else {
+			rs[idx].Rank = i + 1
+		}
+	}
+}
+
+// attributes decodes the attributes of m, keeping numbers as written.
+func attributes(m *model.TokenMetadata) ([]attribute, error) {
+	if m.Attributes == "" {
+		return nil, nil
+	}
+	dec := json.NewDecoder(bytes.NewReader([]byte(m.Attributes)))
+	dec.UseNumber()
+	var attrs []attribute
+	if err := dec.Decode(&attrs); err != nil {
+		return nil, err
+	}
+	return attrs, nil
+}
+
+// valueText returns a string value as is and any other value as JSON.
+func valueText(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
+
+// Changes returns the traits and rarities of the tokens whose rarity differs
+// from saved, the rarities last saved: tokens not scored before with their
+// traits, and tokens whose score, rank or supply changed. It also returns the
+// saved tokens that are no longer in the table, such as tokens minted on
+// orphaned blocks.
+func (t *Table) Changes(saved []model.TokenRarity) ([]model.TokenTrait, []model.TokenRarity, []model.BigInt) {
+	old := make(map[string]model.TokenRarity, len(saved))
+	for _, r := range saved {
+		old[r.TokenID.String()] = r
+	}
+	scored := make(map[string]bool, len(t.Tokens))
+	var tokens []model.TokenRarity
+	for _, r := range t.Tokens {
+		scored[r.TokenID.String()] = true
+		o, ok := old[r.TokenID.String()]
+		if ok && o.Score == r.Score && o.Statistical == r.Statistical && o.Rank == r.Rank && o.Supply == r.Supply {
+			continue
+		}
+		tokens = append(tokens, r)
+	}
+	var traits []model.TokenTrait
+	for _, tr := range t.Traits {
+		if _, ok := old[tr.TokenID.String()]; !ok {
+			traits = append(traits, tr)
+		}
+	}
+	var removed []model.BigInt
+	for _, r := range saved {
+		if !scored[r.TokenID.String()] {
+			removed = append(removed, r.TokenID)
+		}
+	}
+	return traits, tokens, removed
+}
+
+// Update recomputes the rarity of a collection from the resolved metadata
+// of its tokens and saves what changed.
+func Update(ctx context.Context, s store.Store, chainID uint64, contract string) error {
+	ms, err := s.CollectionMetadata(ctx, chainID, contract)
+	if err != nil {
+		return fmt.Errorf("rarity: cannot read metadata of %s: %w", contract, err)
+	}
+	t, err := Compute(chainID, contract, ms)
+	if err != nil {
+		return err
+	}
+	saved, err := s.CollectionRarity(ctx, chainID, contract)
+	if err != nil {
+		return fmt.Errorf("rarity: cannot read rarity of %s: %w", contract, err)
+	}
+	traits, tokens, removed := t.Changes(saved)
+	if err := s.SaveRarity(ctx, chainID, contract, traits, t.Counts, tokens, removed); err != nil {
+		return fmt.Errorf("rarity: cannot save rarity of %s: %w", contract, err)
+	}
+	return nil
+}
@@ -0,0 +1,259 @@
+package rarity
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"testing"
+
+	"blockchain.com/indexer/model"
+	"blockchain.com/indexer/store"
+	"blockchain.com/indexer/store/memory"
+)
+
+const (
+	testChainID  = 1337
+	testContract = "0x00000000000000000000000000000000000000bb"
+)
+
+func token(id int64, attributes string) model.TokenMetadata {
+	return model.TokenMetadata{
+		ChainID:    testChainID,
+		Contract:   testContract,
+		TokenID:    model.NewBigInt(big.NewInt(id)),
+		Status:     model.MetadataResolved,
+		Attributes: attributes,
+	}
+}
+
+// collection has a token without Eyes, which then counts as the empty value,
+// and numeric, untyped and repeated attributes, which do not count.
+var collection = []model.TokenMetadata{
+	token(1, `[{"trait_type":"Background","value":"Blue"},{"trait_type":"Eyes","value":"Laser"}]`),
+	token(2, `[{"trait_type":"Background","value":"Blue"},{"trait_type":"Eyes","value":"Normal"},{"trait_type":"Eyes","value":"Normal"}]`),
+	token(3, `[{"trait_type":"Background","value":"Red"},{"trait_type":"Eyes","value":"Normal"},{"value":"Unique"}]`),
+	token(4, `[{"trait_type":"Background","value":"Blue"},{"trait_type":"Level","value":5,"display_type":"number"}]`),
+}
+
+func TestCompute(t *testing.T) {
+	tab, err := Compute(testChainID, testContract, collection)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var counts []string
+	for _, c := range tab.Counts {
+		counts = append(counts, fmt.Sprintf("%s=%s:%d", c.TraitType, c.Value, c.Count))
+	}
+	if got, want := fmt.Sprint(counts), "[Background=Blue:3 Background=Red:1 Eyes=:1 Eyes=Laser:1 Eyes=Normal:2]"; got != want {
+		t.Errorf("counts %s, want %s", got, want)
+	}
+	if n := len(tab.Traits); n != 9 {
+		t.Errorf("%d traits, want 9", n)
+	}
+
+	tests := []struct {
+		token       int64
+		score       float64
+		statistical float64
+		rank        int
+	}{
+		{1, 4.0/3 + 4, 3.0 / 4 * 1 / 4, 2},
+		{2, 4.0/3 + 2, 3.0 / 4 * 2 / 4, 4},
+		{3, 4 + 2, 1.0 / 4 * 2 / 4, 1},
+		{4, 4.0/3 + 4, 3.0 / 4 * 1 / 4, 2},
+	}
+	for i, tt := range tests {
+		r := tab.Tokens[i]
+		if r.TokenID.Int64() != tt.token || math.Abs(r.Score-tt.score) > 1e-9 ||
+			math.Abs(r.Statistical-tt.statistical) > 1e-9 || r.Rank != tt.rank || r.Supply != 4 {
+			t.Errorf("token %d: score %v, statistical %v, rank %d, supply %d, want %v, %v, %d, 4",
+				tt.token, r.Score, r.Statistical, r.Rank, r.Supply, tt.score, tt.statistical, tt.rank)
+		}
+	}
+}
+
+func TestComputeInvalid(t *testing.T) {
+	for _, attrs := range []string{`{"trait_type":"Eyes"}`, `[`, `["Eyes"]`} {
+		if _, err := Compute(testChainID, testContract, []model.TokenMetadata{token(1, attrs)}); err == nil {
+			t.Errorf("%s: no error", attrs)
+		}
+	}
+	tab, err := Compute(testChainID, testContract, nil)
+	if err != nil || len(tab.Tokens) != 0 {
+		t.Errorf("empty collection: %+v, %v", tab, err)
+	}
+}
+
+func TestRank(t *testing.T) {
+	tests := []struct {
+		scores []float64
+		ranks  []int
+	}{
+		{nil, nil},
+		{[]float64{1}, []int{1}},
+		{[]float64{3, 1, 2}, []int{1, 3, 2}},
+		{[]float64{2, 2, 2}, []int{1, 1, 1}},
+		{[]float64{1, 5, 3, 5, 3}, []int{5, 1, 3, 1, 3}},
+	}
+	for _, tt := range tests {
+		rs := make([]model.TokenRarity, len(tt.scores))
+		for i, s := range tt.scores {
+			rs[i].Score = s
+		}
+		rank(rs)
+		var ranks []int
+		for _, r := range rs {
+			ranks = append(ranks, r.Rank)
+		}
+		if fmt.Sprint(ranks) != fmt.Sprint(tt.ranks) {
+			t.Errorf("scores %v: ranks %v, want %v", tt.scores, ranks, tt.ranks)
+		}
+	}
+}
+
+// saves records what Update writes.
+type saves struct {
+	store.Store
+	traits, tokens, removed []int
+}
+
+func (s *saves) SaveRarity(ctx context.Context, chainID uint64, contract string, traits []model.TokenTrait, counts []model.TraitCount, tokens []model.TokenRarity, removed []model.BigInt) error {
+	s.traits, s.tokens, s.removed = nil, nil, nil
+	seen := make(map[int]bool)
+	for _, tr := range traits {
+		if id := int(tr.TokenID.Int64()); !seen[id] {
+			seen[id] = true
+			s.traits = append(s.traits, id)
+		}
+	}
+	for _, r := range tokens {
+		s.tokens = append(s.tokens, int(r.TokenID.Int64()))
+	}
+	for _, id := range removed {
+		s.removed = append(s.removed, int(id.Int64()))
+	}
+	return s.Store.SaveRarity(ctx, chainID, contract, traits, counts, tokens, removed)
+}
+
+func TestUpdate(t *testing.T) {
+	ctx := context.Background()
+	s := &saves{Store: memory.New()}
+	resolve := func(ms ...model.TokenMetadata) {
+		for i := range ms {
+			if err := s.SaveMetadata(ctx, &ms[i]); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	tests := []struct {
+		name           string
+		resolved       []model.TokenMetadata
+		traits, tokens string
+	}{
+		{"first tokens", collection[:2], "[1 2]", "[1 2]"},
+		{"unchanged", nil, "[]", "[]"},
+		// Every score depends on the supply.
+		{"new token", collection[2:3], "[3]", "[1 2 3]"},
+		{"new token of an existing rarity", []model.TokenMetadata{token(5, collection[2].Attributes)}, "[5]", "[1 2 3 5]"},
+	}
+	for _, tt := range tests {
+		resolve(tt.resolved...)
+		if err := Update(ctx, s, testChainID, testContract); err != nil {
+			t.Fatal(err)
+		}
+		if got := fmt.Sprint(s.traits); got != tt.traits {
+			t.Errorf("%s: traits of tokens %s written, want %s", tt.name, got, tt.traits)
+		}
+		if got := fmt.Sprint(s.tokens); got != tt.tokens {
+			t.Errorf("%s: rarity of tokens %s written, want %s", tt.name, got, tt.tokens)
+		}
+	}
+
+	saved, err := s.CollectionRarity(ctx, testChainID, testContract)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tab, err := Compute(testChainID, testContract, append(collection[:3:3], token(5, collection[2].Attributes)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if traits, tokens, removed := tab.Changes(saved); len(traits) != 0 || len(tokens) != 0 || len(removed) != 0 {
+		t.Errorf("saved rarity differs from the computed one in %d traits, %d tokens and %d removed", len(traits), len(tokens), len(removed))
+	}
+}
+
+func TestUpdateRollback(t *testing.T) {
+	ctx := context.Background()
+	s := &saves{Store: memory.New()}
+	// Token n is minted in block n.
+	b := &store.Batch{FromBlock: 1, ToBlock: 3}
+	for i, m := range collection[:3] {
+		n := uint64(i + 1)
+		b.Blocks = append(b.Blocks, model.Block{ChainID: testChainID, Number: n, Hash: fmt.Sprintf("0x%x", n)})
+		b.Transfers = append(b.Transfers, model.NFTTransfer{
+			ChainID:     testChainID,
+			Contract:    testContract,
+			TokenID:     m.TokenID,
+			FromAddress: store.UnsoldOwner,
+			ToAddress:   "owner",
+			BlockNumber: n,
+			TxHash:      fmt.Sprintf("0x%x", n),
+		})
+	}
+	if err := s.Commit(ctx, b); err != nil {
+		t.Fatal(err)
+	}
+	for i := range collection[:3] {
+		if err := s.SaveMetadata(ctx, &collection[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := Update(ctx, s, testChainID, testContract); err != nil {
+		t.Fatal(err)
+	}
+
+	// Token 3 was minted on an orphaned block.
+	if _, err := s.Rollback(ctx, testChainID, model.Block{ChainID: testChainID, Number: 2, Hash: "0x2"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := Update(ctx, s, testChainID, testContract); err != nil {
+		t.Fatal(err)
+	}
+	if got := fmt.Sprint(s.removed); got != "[3]" {
+		t.Errorf("rarity of tokens %s removed, want [3]", got)
+	}
+	if _, err := s.TokenRarity(ctx, testChainID, testContract, big.NewInt(3)); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("rarity of token 3: %v, want not found", err)
+	}
+	want, err := Compute(testChainID, testContract, collection[:2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved, err := s.CollectionRarity(ctx, testChainID, testContract)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if traits, tokens, removed := want.Changes(saved); len(traits) != 0 || len(tokens) != 0 || len(removed) != 0 {
+		t.Errorf("saved rarity differs from that of tokens 1 and 2 in %d traits, %d tokens and %d removed", len(traits), len(tokens), len(removed))
+	}
+	for _, r := range saved {
+		if r.Supply != 2 {
+			t.Errorf("token %v: supply %d, want 2", r.TokenID, r.Supply)
+		}
+	}
+	counts, err := s.TraitCounts(ctx, testChainID, testContract)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, c := range counts {
+		got = append(got, fmt.Sprintf("%s=%s:%d", c.TraitType, c.Value, c.Count))
+	}
+	if fmt.Sprint(got) != "[Background=Blue:2 Eyes=Laser:1 Eyes=Normal:1]" {
+		t.Errorf("counts %v after the rollback", got)
+	}
+}
@@ -0,0 +1,23 @@
+package signer
+
+import (
+	"fmt"
+	"math/big"
+	"os"
+
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+)
+
+// FromKeystore returns a signer for the key of an encrypted JSON keystore
+// file, as written by geth account new or clef.
+func FromKeystore(path, passphrase string, chainID *big.Int) (*KeySigner, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("signer: %w", err)
+	}
+	key, err := keystore.DecryptKey(data, passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("signer: cannot decrypt %s: %w", path, err)
+	}
+	return NewKeySigner(key.PrivateKey, chainID), nil
+}
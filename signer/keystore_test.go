@@ -0,0 +1,49 @@
+package signer
+
+import (
+	"context"
+	"math/big"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestFromKeystore(t *testing.T) {
+	dir := t.TempDir()
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	account, err := keystore.NewKeyStore(dir, keystore.LightScryptN, keystore.LightScryptP).ImportECDSA(key, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := FromKeystore(account.URL.Path, "wrong", big.NewInt(1)); err == nil {
+		t.Error("wrong passphrase: no error")
+	}
+	if _, err := FromKeystore(filepath.Join(dir, "missing"), "secret", big.NewInt(1)); err == nil {
+		t.Error("missing file: no error")
+	}
+
+	// Keystores are allowed on every chain, mainnet included.
+	s, err := FromKeystore(account.URL.Path, "secret", big.NewInt(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Address() != account.Address || s.ChainID().Int64() != 1 {
+		t.Fatalf("signer for %s on chain %v, want %s on 1", s.Address().Hex(), s.ChainID(), account.Address.Hex())
+	}
+	to := common.HexToAddress("0xbb")
+	signed, err := s.SignTx(context.Background(), types.NewTx(&types.DynamicFeeTx{To: &to, Gas: 21000, GasFeeCap: big.NewInt(2), GasTipCap: big.NewInt(1)}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if from, err := types.Sender(types.LatestSignerForChainID(big.NewInt(1)), signed); err != nil || from != account.Address {
+		t.Errorf("signed by %s (%v), want %s", from.Hex(), err, account.Address.Hex())
+	}
+}
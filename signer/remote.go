@@ -0,0 +1,116 @@
+package signer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+)
+
+// DefaultMethod is the JSON-RPC method of the remote signer, as geth serves
+// it. clef serves account_signTransaction.
+const DefaultMethod = "eth_signTransaction"
+
+// ErrBadSignature is returned when the remote signer returns a transaction
+// that is not the one it was asked to sign, or not signed by its account
+// for the chain.
+var ErrBadSignature = errors.New("signer: remote signer returned an unexpected transaction")
+
+// Remote signs with a key held by another process, over JSON-RPC: the
+// transaction is sent unsigned to method and comes back signed and RLP
+// encoded. The returned transaction is checked against the request, so a
+// remote signer can refuse but not alter a transaction.
+type Remote struct {
+	client  *rpc.Client
+	method  string
+	address common.Address
+	chainID *big.Int
+}
+
+// NewRemote returns a signer for address calling method, DefaultMethod if
+// empty, on the signer at url.
+func NewRemote(ctx context.Context, url, method string, address common.Address, chainID *big.Int) (*Remote, error) {
+	client, err := rpc.DialContext(ctx, url)
+	if err != nil {
+		return nil, fmt.Errorf("signer: cannot dial %s: %w", url, err)
+	}
+	if method == "" {
+		method = DefaultMethod
+	}
+	return &Remote{client: client, method: method, address: address, chainID: new(big.Int).Set(chainID)}, nil
+}
+
+func (s *Remote) Address() common.Address { return s.address }
+func (s *Remote) ChainID() *big.Int       { return new(big.Int).Set(s.chainID) }
+
+func (s *Remote) SignTx(ctx context.Context, tx *types.Transaction) (*types.Transaction, error) {
+	if err := CheckChain(s.chainID, tx); err != nil {
+		return nil, err
+	}
+	data := hexutil.Bytes(tx.Data())
+	args := apitypes.SendTxArgs{
+		From:    common.NewMixedcaseAddress(s.address),
+		Gas:     hexutil.Uint64(tx.Gas()),
+		Value:   hexutil.Big(*tx.Value()),
+		Nonce:   hexutil.Uint64(tx.Nonce()),
+		Data:    &data,
+		ChainID: (*hexutil.Big)(s.chainID),
+	}
+	if to := tx.To(); to != nil {
+		addr := common.NewMixedcaseAddress(*to)
+		args.To = &addr
+	}
+	switch tx.Type() {
+	case types.LegacyTxType:
+		args.GasPrice = (*hexutil.Big)(tx.GasPrice())
+	default:
+		args.MaxFeePerGas = (*hexutil.Big)(tx.GasFeeCap())
+		args.MaxPriorityFeePerGas = (*hexutil.Big)(tx.GasTipCap())
+		if al := tx.AccessList(); len(al) > 0 {
+			args.AccessList = &al
+		}
+	}
+
+	var res struct {
+		Raw hexutil.Bytes `json:"raw"`
+	}
+	if err := s.client.CallContext(ctx, &res, s.method, &args); err != nil {
+		return nil, fmt.Errorf("signer: %s: %w", s.method, err)
+	}
+	signed := new(types.Transaction)
+	if err := signed.UnmarshalBinary(res.Raw); err != nil {
+		return nil, fmt.Errorf("signer: cannot decode signed transaction: %w", err)
+	}
+	if err := s.verify(tx, signed); err != nil {
+		return nil, err
+	}
+	return signed, nil
+}
+
+// verify checks that signed is tx signed by the account of s for its chain.
+func (s *Remote) verify(tx, signed *types.Transaction) error {
+	signer := types.LatestSignerForChainID(s.chainID)
+	if signed.Type() != tx.Type() || signer.Hash(signed) != signer.Hash(tx) {
+		return fmt.Errorf("%w: it differs from the request", ErrBadSignature)
+	}
+	if !signed.Protected() {
+		return fmt.Errorf("%w: not replay protected", ErrBadSignature)
+	}
+	if signed.ChainId().Cmp(s.chainID) != 0 {
+		return fmt.Errorf("%w: signed for chain %v", ErrBadSignature, signed.ChainId())
+	}
+	from, err := types.Sender(signer, signed)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrBadSignature, err)
+	}
+	if from != s.address {
+		return fmt.Errorf("%w: signed by %s", ErrBadSignature, from.Hex())
+	}
+	return nil
+}
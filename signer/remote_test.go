@@ -0,0 +1,168 @@
+package signer
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+)
+
+// newRemote returns a remote signer for the account of key on chain 1337,
+// served by a signer answering with what sign makes of the requested
+// transaction. calls counts the requests.
+func newRemote(t *testing.T, key *ecdsa.PrivateKey, calls *int32, sign func(tx *types.Transaction) (*types.Transaction, error)) *Remote {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(calls, 1)
+		var req struct {
+			ID     json.RawMessage       `json:"id"`
+			Method string                `json:"method"`
+			Params []apitypes.SendTxArgs `json:"params"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Method != DefaultMethod || len(req.Params) != 1 {
+			t.Errorf("request %+v: %v", req, err)
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		resp := map[string]interface{}{"jsonrpc": "2.0", "id": req.ID}
+		signed, err := sign(req.Params[0].ToTransaction())
+		if err != nil {
+			resp["error"] = map[string]interface{}{"code": -32000, "message": err.Error()}
+		} else {
+			raw, err := signed.MarshalBinary()
+			if err != nil {
+				t.Error(err)
+			}
+			resp["result"] = map[string]interface{}{"raw": hexutil.Bytes(raw)}
+		}
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			t.Error(err)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	s, err := NewRemote(context.Background(), srv.URL, "", crypto.PubkeyToAddress(key.PublicKey), big.NewInt(1337))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// changed returns the dynamic fee transaction tx with change applied.
+func changed(tx *types.Transaction, change func(*types.DynamicFeeTx)) *types.Transaction {
+	d := &types.DynamicFeeTx{
+		ChainID:   tx.ChainId(),
+		Nonce:     tx.Nonce(),
+		GasTipCap: tx.GasTipCap(),
+		GasFeeCap: tx.GasFeeCap(),
+		Gas:       tx.Gas(),
+		To:        tx.To(),
+		Value:     tx.Value(),
+		Data:      tx.Data(),
+	}
+	change(d)
+	return types.NewTx(d)
+}
+
+func TestRemote(t *testing.T) {
+	key, err := crypto.HexToECDSA(testKey[2:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	chain := big.NewInt(1337)
+	signWith := func(key *ecdsa.PrivateKey, s types.Signer) func(*types.Transaction) (*types.Transaction, error) {
+		return func(tx *types.Transaction) (*types.Transaction, error) { return types.SignTx(tx, s, key) }
+	}
+	signChanged := func(change func(*types.DynamicFeeTx)) func(*types.Transaction) (*types.Transaction, error) {
+		return func(tx *types.Transaction) (*types.Transaction, error) {
+			return types.SignTx(changed(tx, change), types.LatestSignerForChainID(chain), key)
+		}
+	}
+
+	to, attacker := common.HexToAddress("0xbb"), common.HexToAddress("0xee")
+	dynamic := &types.DynamicFeeTx{Nonce: 7, To: &to, Value: big.NewInt(5), Gas: 50000, GasFeeCap: big.NewInt(30), GasTipCap: big.NewInt(2), Data: []byte{1, 2}}
+	legacy := &types.LegacyTx{Nonce: 7, To: &to, Value: big.NewInt(5), Gas: 50000, GasPrice: big.NewInt(30), Data: []byte{1, 2}}
+
+	tests := []struct {
+		name string
+		tx   types.TxData
+		sign func(*types.Transaction) (*types.Transaction, error)
+		err  error
+	}{
+		{"dynamic fee", dynamic, signWith(key, types.LatestSignerForChainID(chain)), nil},
+		{"legacy", legacy, signWith(key, types.NewEIP155Signer(chain)), nil},
+		{"legacy without replay protection", legacy, signWith(key, types.HomesteadSigner{}), ErrBadSignature},
+		{"other sender", dynamic, signWith(other, types.LatestSignerForChainID(chain)), ErrBadSignature},
+		{"other chain", dynamic, func(tx *types.Transaction) (*types.Transaction, error) {
+			mainnet := big.NewInt(1)
+			return types.SignTx(changed(tx, func(d *types.DynamicFeeTx) { d.ChainID = mainnet }), types.LatestSignerForChainID(mainnet), key)
+		}, ErrBadSignature},
+		{"legacy for another chain", legacy, signWith(key, types.NewEIP155Signer(big.NewInt(1))), ErrBadSignature},
+		{"value changed", dynamic, signChanged(func(d *types.DynamicFeeTx) { d.Value = big.NewInt(5e18) }), ErrBadSignature},
+		{"recipient changed", dynamic, signChanged(func(d *types.DynamicFeeTx) { d.To = &attacker }), ErrBadSignature},
+		{"data changed", dynamic, signChanged(func(d *types.DynamicFeeTx) { d.Data = []byte{9} }), ErrBadSignature},
+		{"nonce changed", dynamic, signChanged(func(d *types.DynamicFeeTx) { d.Nonce = 8 }), ErrBadSignature},
+		{"fees changed", dynamic, signChanged(func(d *types.DynamicFeeTx) { d.GasFeeCap = big.NewInt(3000) }), ErrBadSignature},
+		{"type changed", dynamic, func(tx *types.Transaction) (*types.Transaction, error) {
+			return types.SignTx(types.NewTx(legacy), types.NewEIP155Signer(chain), key)
+		}, ErrBadSignature},
+	}
+	for _, tt := range tests {
+		var calls int32
+		s := newRemote(t, key, &calls, tt.sign)
+		req := types.NewTx(tt.tx)
+		signed, err := s.SignTx(context.Background(), req)
+		switch {
+		case tt.err != nil && !errors.Is(err, tt.err):
+			t.Errorf("%s: %v, want %v", tt.name, err, tt.err)
+		case tt.err == nil && err != nil:
+			t.Errorf("%s: %v", tt.name, err)
+		case tt.err == nil:
+			from, err := types.Sender(types.LatestSignerForChainID(chain), signed)
+			if err != nil || from != s.Address() || signed.ChainId().Cmp(chain) != 0 || signed.Nonce() != req.Nonce() {
+				t.Errorf("%s: signed by %s for chain %v (%v)", tt.name, from.Hex(), signed.ChainId(), err)
+			}
+		}
+		if calls != 1 {
+			t.Errorf("%s: %d calls of the remote signer", tt.name, calls)
+		}
+	}
+}
+
+func TestRemoteRefused(t *testing.T) {
+	key, err := crypto.HexToECDSA(testKey[2:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var calls int32
+	s := newRemote(t, key, &calls, func(*types.Transaction) (*types.Transaction, error) {
+		return nil, errors.New("request denied")
+	})
+	to := common.HexToAddress("0xbb")
+
+	_, err = s.SignTx(context.Background(), types.NewTx(&types.DynamicFeeTx{To: &to, Gas: 21000, GasFeeCap: big.NewInt(2), GasTipCap: big.NewInt(1)}))
+	if err == nil || errors.Is(err, ErrBadSignature) {
+		t.Errorf("refused: %v, want the signer's error", err)
+	}
+	// A transaction for another chain is not even sent to the signer.
+	_, err = s.SignTx(context.Background(), types.NewTx(&types.DynamicFeeTx{ChainID: big.NewInt(1), To: &to, Gas: 21000, GasFeeCap: big.NewInt(2), GasTipCap: big.NewInt(1)}))
+	if !errors.Is(err, ErrWrongChain) {
+		t.Errorf("other chain: %v, want %v", err, ErrWrongChain)
+	}
+	if calls != 1 {
+		t.Errorf("%d calls of the remote signer, want 1", calls)
+	}
+}
@@ -0,0 +1,118 @@
+// Package signer signs the transactions the server sends.
+//
+// Signers are bound to a chain: they sign with EIP-155 replay protection for
+// their chain only and reject transactions for any other chain.
+package signer
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+var (
+	// ErrWrongChain is returned when signing a transaction for another chain
+	// than the signer's.
+	ErrWrongChain = errors.New("signer: transaction is for another chain")
+
+	// ErrDevKey is returned when a raw hex key is used on a chain it is not
+	// allowed on.
+	ErrDevKey = errors.New("signer: raw hex keys are for development networks only")
+)
+
+// DevChainIDs are the chain ids of local development nodes, Ganache and
+// Hardhat, on which raw hex keys are always allowed.
+var DevChainIDs = []uint64{1337, 31337}
+
+// IsDevChain reports whether chainID is one of DevChainIDs.
+func IsDevChain(chainID uint64) bool {
+	for _, id := range DevChainIDs {
+		if id == chainID {
+			return true
+		}
+	}
+	return false
+}
+
+// Signer signs transactions from one account on one chain.
+type Signer interface {
+	// Address is the account the signer signs for.
+	Address() common.Address
+
+	// ChainID is the chain the signer signs for.
+	ChainID() *big.Int
+
+	// SignTx returns tx signed for the chain of the signer.
+	SignTx(ctx context.Context, tx *types.Transaction) (*types.Transaction, error)
+}
+
+// TransactOpts returns options sending transactions from the account of s
+// with the bindings. ctx bounds the calls and the signing.
+func TransactOpts(ctx context.Context, s Signer) *bind.TransactOpts {
+	return &bind.TransactOpts{
+		From:    s.Address(),
+		Context: ctx,
+		Signer: func(from common.Address, tx *types.Transaction) (*types.Transaction, error) {
+			if from != s.Address() {
+				return nil, bind.ErrNotAuthorized
+			}
+			return s.SignTx(ctx, tx)
+		},
+	}
+}
+
+// CheckChain returns ErrWrongChain if tx is a typed transaction for another
+// chain than chainID. Legacy transactions carry no chain id before they are
+// signed, nor do the typed transactions of the bindings; signing them for
+// chainID is what binds them to it.
+func CheckChain(chainID *big.Int, tx *types.Transaction) error {
+	if tx.Type() != types.LegacyTxType && tx.ChainId().Sign() != 0 && tx.ChainId().Cmp(chainID) != 0 {
+		return fmt.Errorf("%w: %v, signer is for %v", ErrWrongChain, tx.ChainId(), chainID)
+	}
+	return nil
+}
+
+// KeySigner signs with a private key held in the process.
+type KeySigner struct {
+	key     *ecdsa.PrivateKey
+	address common.Address
+	chainID *big.Int
+}
+
+func NewKeySigner(key *ecdsa.PrivateKey, chainID *big.Int) *KeySigner {
+	return &KeySigner{key: key, address: crypto.PubkeyToAddress(key.PublicKey), chainID: new(big.Int).Set(chainID)}
+}
+
+// FromHex returns a signer for a hex-encoded private key, with or without a
+// 0x prefix. Raw keys are meant for development networks: it refuses every
+// chain but DevChainIDs unless allowRawKey is set, as for a testnet, and
+// mainnet regardless.
+func FromHex(hexKey string, chainID *big.Int, allowRawKey bool) (*KeySigner, error) {
+	dev := chainID.IsUint64() && IsDevChain(chainID.Uint64())
+	if chainID.Cmp(common.Big1) == 0 || !dev && !allowRawKey {
+		return nil, ErrDevKey
+	}
+	key, err := crypto.HexToECDSA(strings.TrimPrefix(hexKey, "0x"))
+	if err != nil {
+		return nil, fmt.Errorf("signer: %w", err)
+	}
+	return NewKeySigner(key, chainID), nil
+}
+
+func (s *KeySigner) Address() common.Address { return s.address }
+func (s *KeySigner) ChainID() *big.Int       { return new(big.Int).Set(s.chainID) }
+
+func (s *KeySigner) SignTx(ctx context.Context, tx *types.Transaction) (*types.Transaction, error) {
+	if err := CheckChain(s.chainID, tx); err != nil {
+		return nil, err
+	}
+	return types.SignTx(tx, types.LatestSignerForChainID(s.chainID), s.key)
+}
@@ -0,0 +1,81 @@
+package signer
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+const testKey = "0x4c0883a69102937d6231471b5dbb6204fe5129617082792ae468d01a3f362318"
+
+func TestFromHex(t *testing.T) {
+	tests := []struct {
+		chainID     int64
+		allowRawKey bool
+		ok          bool
+	}{
+		{1337, false, true},
+		{31337, false, true},
+		{3, false, false},
+		{3, true, true},
+		{137, false, false},
+		{1, false, false},
+		{1, true, false},
+	}
+	for _, tt := range tests {
+		s, err := FromHex(testKey, big.NewInt(tt.chainID), tt.allowRawKey)
+		switch {
+		case tt.ok && err != nil:
+			t.Errorf("chain %d, allowRawKey %v: %v", tt.chainID, tt.allowRawKey, err)
+		case tt.ok && s.ChainID().Int64() != tt.chainID:
+			t.Errorf("chain %d: signer for chain %v", tt.chainID, s.ChainID())
+		case !tt.ok && !errors.Is(err, ErrDevKey):
+			t.Errorf("chain %d, allowRawKey %v: %v, want %v", tt.chainID, tt.allowRawKey, err, ErrDevKey)
+		}
+	}
+	if _, err := FromHex("0x1234", big.NewInt(1337), false); err == nil || errors.Is(err, ErrDevKey) {
+		t.Errorf("short key: %v, want a key error", err)
+	}
+}
+
+func TestKeySignerChain(t *testing.T) {
+	s, err := FromHex(testKey, big.NewInt(1337), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	to := common.HexToAddress("0xbb")
+	tests := []struct {
+		name    string
+		tx      types.TxData
+		chainID int64
+	}{
+		{"legacy", &types.LegacyTx{To: &to, Gas: 21000, GasPrice: big.NewInt(1)}, 1337},
+		// The bindings leave the chain id of the transactions they build to
+		// the signer.
+		{"dynamic fee, no chain", &types.DynamicFeeTx{To: &to, Gas: 21000, GasFeeCap: big.NewInt(2), GasTipCap: big.NewInt(1)}, 1337},
+		{"dynamic fee", &types.DynamicFeeTx{ChainID: big.NewInt(1337), To: &to, Gas: 21000, GasFeeCap: big.NewInt(2), GasTipCap: big.NewInt(1)}, 1337},
+		{"dynamic fee, other chain", &types.DynamicFeeTx{ChainID: big.NewInt(1), To: &to, Gas: 21000, GasFeeCap: big.NewInt(2), GasTipCap: big.NewInt(1)}, 0},
+		{"access list, other chain", &types.AccessListTx{ChainID: big.NewInt(3), To: &to, Gas: 21000, GasPrice: big.NewInt(1)}, 0},
+	}
+	for _, tt := range tests {
+		signed, err := s.SignTx(context.Background(), types.NewTx(tt.tx))
+		if tt.chainID == 0 {
+			if !errors.Is(err, ErrWrongChain) {
+				t.Errorf("%s: %v, want %v", tt.name, err, ErrWrongChain)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		from, err := types.Sender(types.LatestSignerForChainID(big.NewInt(1337)), signed)
+		if err != nil || from != s.Address() || !signed.Protected() || signed.ChainId().Int64() != tt.chainID {
+			t.Errorf("%s: signed by %s for chain %v (%v), want %s for %d", tt.name, from.Hex(), signed.ChainId(), err, s.Address().Hex(), tt.chainID)
+		}
+	}
+}
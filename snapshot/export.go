@@ -0,0 +1,76 @@
+package snapshot
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"blockchain.com/indexer/model"
+)
+
+// Export formats.
+const (
+	FormatJSON = "json"
+	FormatCSV  = "csv"
+)
+
+// Write exports s in format.
+func Write(w io.Writer, s *Snapshot, format string) error {
+	switch format {
+	case FormatJSON, "":
+		return WriteJSON(w, s)
+	case FormatCSV:
+		return WriteCSV(w, s)
+	}
+	return fmt.Errorf("snapshot: unknown format %q", format)
+}
+
+type jsonSnapshot struct {
+	ChainID     uint64           `json:"chain_id"`
+	Contract    string           `json:"contract"`
+	BlockNumber uint64           `json:"block_number"`
+	BlockTime   *time.Time       `json:"block_time,omitempty"`
+	Holders     []Holder         `json:"holders"`
+	Tokens      []model.NFTOwner `json:"tokens"`
+}
+
+// WriteJSON writes s as a JSON object with the holders and their tokens.
+func WriteJSON(w io.Writer, s *Snapshot) error {
+	out := jsonSnapshot{
+		ChainID:     s.ChainID,
+		Contract:    s.Contract,
+		BlockNumber: s.BlockNumber,
+		Holders:     s.Holders(),
+		Tokens:      s.Tokens,
+	}
+	if !s.BlockTime.IsZero() {
+		out.BlockTime = &s.BlockTime
+	}
+	if out.Holders == nil {
+		out.Holders = []Holder{}
+	}
+	if out.Tokens == nil {
+		out.Tokens = []model.NFTOwner{}
+	}
+	return json.NewEncoder(w).Encode(out)
+}
+
+// WriteCSV writes one row per token: the token id, its owner and the block
+// and transaction of the transfer that gave it to the owner.
+func WriteCSV(w io.Writer, s *Snapshot) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"contract", "token_id", "owner", "acquired_block", "acquired_tx"}); err != nil {
+		return err
+	}
+	for _, t := range s.Tokens {
+		err := cw.Write([]string{t.Contract, t.TokenID.String(), t.Owner, strconv.FormatUint(t.BlockNumber, 10), t.TxHash})
+		if err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
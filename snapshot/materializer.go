@@ -0,0 +1,124 @@
+package snapshot
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"github.com/ethereum/go-ethereum/event"
+
+	"blockchain.com/indexer/indexer"
+	"blockchain.com/indexer/store"
+)
+
+// Indexer is the part of the indexer the materializer follows.
+type Indexer interface {
+	SubscribeChainEvents(ch chan<- indexer.ChainEvent) event.Subscription
+	SubscribeRollbackEvents(ch chan<- indexer.RollbackEvent) event.Subscription
+}
+
+type Config struct {
+	ChainID uint64
+
+	// Interval is the number of blocks between snapshots of a contract.
+	Interval uint64
+
+	// Finality is how far below the indexed blocks snapshots are taken, so
+	// reorganizations rarely discard them.
+	Finality uint64
+}
+
+// Materializer saves a snapshot of every contract with transfers each
+// Interval blocks, bounding the transfers OwnersAt replays.
+type Materializer struct {
+	store store.Store
+	cfg   Config
+
+	// last is the block of the latest snapshot by contract.
+	last map[string]uint64
+}
+
+func NewMaterializer(s store.Store, cfg Config) *Materializer {
+	if cfg.Interval == 0 {
+		cfg.Interval = 10000
+	}
+	if cfg.Finality == 0 {
+		cfg.Finality = 128
+	}
+	return &Materializer{store: s, cfg: cfg, last: make(map[string]uint64)}
+}
+
+// Run saves snapshots as ix commits blocks until ctx is cancelled.
+func (m *Materializer) Run(ctx context.Context, ix Indexer) error {
+	chain := make(chan indexer.ChainEvent, 16)
+	chainSub := ix.SubscribeChainEvents(chain)
+	defer chainSub.Unsubscribe()
+	rollback := make(chan indexer.RollbackEvent, 16)
+	rollbackSub := ix.SubscribeRollbackEvents(rollback)
+	defer rollbackSub.Unsubscribe()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-chainSub.Err():
+			return err
+		case err := <-rollbackSub.Err():
+			return err
+		case ev := <-rollback:
+			for contract, block := range m.last {
+				if block > ev.Ancestor.Number {
+					delete(m.last, contract)
+				}
+			}
+		case ev := <-chain:
+			m.materialize(ctx, ev)
+		}
+	}
+}
+
+func (m *Materializer) materialize(ctx context.Context, ev indexer.ChainEvent) {
+	b := ev.Batch
+	if b.ToBlock < m.cfg.Finality+m.cfg.Interval {
+		return
+	}
+	target := (b.ToBlock - m.cfg.Finality) / m.cfg.Interval * m.cfg.Interval
+
+	seen := make(map[string]bool)
+	for _, t := range b.Transfers {
+		if seen[t.Contract] {
+			continue
+		}
+		seen[t.Contract] = true
+
+		last, err := m.latest(ctx, t.Contract)
+		if err != nil {
+			log.Printf("snapshot: cannot read latest snapshot of %v: %v", t.Contract, err)
+			continue
+		}
+		if target <= last {
+			continue
+		}
+		if err := m.store.SaveSnapshot(ctx, m.cfg.ChainID, t.Contract, target); err != nil {
+			log.Printf("snapshot: cannot save snapshot of %v at block %d: %v", t.Contract, target, err)
+			continue
+		}
+		m.last[t.Contract] = target
+	}
+}
+
+func (m *Materializer) latest(ctx context.Context, contract string) (uint64, error) {
+	if block, ok := m.last[contract]; ok {
+		return block, nil
+	}
+	snap, err := m.store.LatestSnapshot(ctx, m.cfg.ChainID, contract)
+	if errors.Is(err, store.ErrNotFound) {
+		m.last[contract] = 0
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	m.last[contract] = snap.BlockNumber
+	return snap.BlockNumber, nil
+}
@@ -0,0 +1,120 @@
+// Package snapshot reconstructs the holders of an NFT contract at a past
+// block and keeps the periodic snapshots that make those queries cheap.
+package snapshot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"blockchain.com/indexer/model"
+	"blockchain.com/indexer/store"
+)
+
+var (
+	// ErrNotIndexed is returned for blocks the contract is not indexed up
+	// to yet.
+	ErrNotIndexed = errors.New("snapshot: block not indexed")
+
+	// ErrNoBlock is returned for times before the first indexed block.
+	ErrNoBlock = errors.New("snapshot: no indexed block at that time")
+)
+
+// At selects the block of a snapshot: Block if set, else the last indexed
+// block at Time, else the latest indexed block.
+type At struct {
+	Block *uint64
+	Time  *time.Time
+}
+
+// Snapshot is the holder set of a contract at a block.
+type Snapshot struct {
+	ChainID     uint64
+	Contract    string
+	BlockNumber uint64
+
+	// BlockTime is the time of the block, zero if the block has no records
+	// and was not indexed.
+	BlockTime time.Time
+
+	// Tokens are the owned tokens ordered by token id.
+	Tokens []model.NFTOwner
+}
+
+// Holder is an account with the number of tokens it owns.
+type Holder struct {
+	Owner   string `json:"owner"`
+	Balance int    `json:"balance"`
+}
+
+// Take reconstructs the holders of contract at at.
+func Take(ctx context.Context, s store.Store, chainID uint64, contract string, at At) (*Snapshot, error) {
+	cp, err := s.Checkpoint(ctx, chainID, contract)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, fmt.Errorf("%w: %v is not indexed", ErrNotIndexed, contract)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	snap := &Snapshot{ChainID: chainID, Contract: contract, BlockNumber: cp.BlockNumber}
+	switch {
+	case at.Block != nil:
+		if *at.Block > cp.BlockNumber {
+			return nil, fmt.Errorf("%w: %v is indexed up to block %d", ErrNotIndexed, contract, cp.BlockNumber)
+		}
+		snap.BlockNumber = *at.Block
+	case at.Time != nil:
+		blk, err := s.BlockAt(ctx, chainID, *at.Time)
+		if errors.Is(err, store.ErrNotFound) {
+			return nil, ErrNoBlock
+		}
+		if err != nil {
+			return nil, err
+		}
+		if blk.Number < snap.BlockNumber {
+			snap.BlockNumber = blk.Number
+		}
+	}
+
+	blk, err := s.Block(ctx, chainID, snap.BlockNumber)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		return nil, err
+	}
+	if blk != nil {
+		snap.BlockTime = blk.Time
+	}
+	if snap.Tokens, err = s.OwnersAt(ctx, chainID, contract, snap.BlockNumber); err != nil {
+		return nil, err
+	}
+	return snap, nil
+}
+
+// Holders returns the distinct owners of the snapshot with their balances,
+// largest balance first.
+func (s *Snapshot) Holders() []Holder {
+	counts := make(map[string]int)
+	var holders []Holder
+	for _, t := range s.Tokens {
+		if counts[t.Owner] == 0 {
+			holders = append(holders, Holder{Owner: t.Owner})
+		}
+		counts[t.Owner]++
+	}
+	for i := range holders {
+		holders[i].Balance = counts[holders[i].Owner]
+	}
+	sortHolders(holders)
+	return holders
+}
+
+func sortHolders(holders []Holder) {
+	sort.Slice(holders, func(i, j int) bool {
+		if holders[i].Balance != holders[j].Balance {
+			return holders[i].Balance > holders[j].Balance
+		}
+		return holders[i].Owner < holders[j].Owner
+	})
+}
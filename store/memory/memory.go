@@ -0,0 +1,1364 @@
+package memory
+
+import (
+	"context"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+
+	"blockchain.com/indexer/model"
+	"blockchain.com/indexer/store"
+)
+
+// Store is an in-memory store.Store.
+type Store struct {
+	mu sync.RWMutex
+
+	blocks          map[blockKey]model.Block
+	transactions    map[txKey]model.Transaction
+	marketItems     []model.MarketItem
+	transfers       []model.NFTTransfer
+	approvals       []model.NFTApproval
+	approvalsForAll []model.NFTApprovalForAll
+	sales           []model.MarketSale
+	contractEvents  []model.ContractEvent
+	unknownLogs     []model.UnknownLog
+	owners          map[ownerKey]model.NFTOwner
+	snapshots       map[checkpointKey][]snapshot
+	floors          map[floorKey]model.FloorPrice
+	stats           map[statsKey]*hourStats
+	metadata        map[ownerKey]model.TokenMetadata
+	rarity          map[checkpointKey]*rarity
+	mintJobs        []model.MintJob
+	checkpoints     map[checkpointKey]model.Checkpoint
+	contracts       map[checkpointKey]model.Contract
+
+	// logs holds the logs every record was decoded from, by table, so that
+	// a batch committed twice is a no-op as with the unique indexes of the
+	// pg store.
+	logs map[logKey]bool
+
+	// lastID is the last record id handed out, as a database sequence would.
+	lastID int
+}
+
+type blockKey struct {
+	chainID uint64
+	number  uint64
+}
+
+type txKey struct {
+	chainID uint64
+	hash    string
+}
+
+type logKey struct {
+	table    string
+	chainID  uint64
+	txHash   string
+	logIndex uint
+}
+
+type ownerKey struct {
+	chainID  uint64
+	contract string
+	tokenID  string
+}
+
+func ownerKeyOf(chainID uint64, contract string, tokenID *big.Int) ownerKey {
+	return ownerKey{chainID, contract, tokenID.String()}
+}
+
+// snapshot is a materialized holder set; the snapshots of a contract are kept
+// in ascending block order.
+type snapshot struct {
+	model.NFTSnapshot
+	owners []model.NFTOwner
+}
+
+type floorKey struct {
+	chainID     uint64
+	market      string
+	nftContract string
+	block       uint64
+}
+
+// rarity is the rarity table of a contract. traits and tokens are keyed by
+// token id.
+type rarity struct {
+	traits map[string][]model.TokenTrait
+	counts []model.TraitCount
+	tokens map[string]model.TokenRarity
+}
+
+// statsKey identifies the hourly rollup of the listings or sales of an NFT
+// contract on a market.
+type statsKey struct {
+	chainID     uint64
+	kind        string
+	market      string
+	nftContract string
+	hour        time.Time
+}
+
+// hourStats is an hourly rollup. It counts every price and participant, so
+// that the records of orphaned blocks can be taken out of it as exactly as
+// they were added, and buckets merge the rollups of their hours without
+// going back to the records.
+type hourStats struct {
+	prices  map[string]*priceCount
+	sellers map[string]int
+	buyers  map[string]int
+}
+
+type priceCount struct {
+	price *big.Int
+	count int
+}
+
+type checkpointKey struct {
+	chainID  uint64
+	contract string
+}
+
+func New() *Store {
+	return &Store{
+		blocks:       make(map[blockKey]model.Block),
+		transactions: make(map[txKey]model.Transaction),
+		owners:       make(map[ownerKey]model.NFTOwner),
+		snapshots:    make(map[checkpointKey][]snapshot),
+		floors:       make(map[floorKey]model.FloorPrice),
+		stats:        make(map[statsKey]*hourStats),
+		metadata:     make(map[ownerKey]model.TokenMetadata),
+		rarity:       make(map[checkpointKey]*rarity),
+		checkpoints:  make(map[checkpointKey]model.Checkpoint),
+		contracts:    make(map[checkpointKey]model.Contract),
+		logs:         make(map[logKey]bool),
+	}
+}
+
+func (s *Store) Commit(ctx context.Context, b *store.Batch) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, blk := range b.Blocks {
+		s.blocks[blockKey{blk.ChainID, blk.Number}] = blk
+	}
+	for _, tx := range b.Transactions {
+		k := txKey{tx.ChainID, tx.TxHash}
+		if _, ok := s.transactions[k]; !ok {
+			s.transactions[k] = tx
+		}
+	}
+	for i := range b.MarketItems {
+		it := &b.MarketItems[i]
+		if !s.record("market_item", it.ChainID, it.TxHash, it.LogIndex) || s.item(it.ChainID, it.Market, it.ItemID.Big()) != nil {
+			continue
+		}
+		s.lastID++
+		it.ID = s.lastID
+		s.marketItems = append(s.marketItems, *it)
+		if blk, ok := s.blocks[blockKey{it.ChainID, it.BlockNumber}]; ok {
+			s.stat(1, model.MarketActivityListing, it.ChainID, it.Market, it.NftContract, blk.Time, it.Price.Big(), it.Seller, "")
+		}
+	}
+	for i := range b.Sales {
+		sale := &b.Sales[i]
+		if !s.record("market_sale", sale.ChainID, sale.TxHash, sale.LogIndex) {
+			continue
+		}
+		if it := s.item(sale.ChainID, sale.Market, sale.ItemID.Big()); it != nil {
+			sale.Seller = it.Seller
+			it.Sold = true
+			it.Owner = sale.Buyer
+		}
+		s.lastID++
+		sale.ID = s.lastID
+		s.sales = append(s.sales, *sale)
+		if blk, ok := s.blocks[blockKey{sale.ChainID, sale.BlockNumber}]; ok {
+			s.stat(1, model.MarketActivitySale, sale.ChainID, sale.Market, sale.NftContract, blk.Time, sale.Price.Big(), sale.Seller, sale.Buyer)
+		}
+	}
+	for _, it := range b.MarketItems {
+		s.floor(floorKey{it.ChainID, it.Market, it.NftContract, it.BlockNumber})
+	}
+	for _, sale := range b.Sales {
+		s.floor(floorKey{sale.ChainID, sale.Market, sale.NftContract, sale.BlockNumber})
+	}
+	for _, t := range b.Transfers {
+		if s.record("nft_transfer", t.ChainID, t.TxHash, t.LogIndex) {
+			s.transfers = append(s.transfers, t)
+			s.own(t)
+		}
+	}
+	for _, a := range b.Approvals {
+		if s.record("nft_approval", a.ChainID, a.TxHash, a.LogIndex) {
+			s.approvals = append(s.approvals, a)
+		}
+	}
+	for _, a := range b.ApprovalsForAll {
+		if s.record("nft_approval_for_all", a.ChainID, a.TxHash, a.LogIndex) {
+			s.approvalsForAll = append(s.approvalsForAll, a)
+		}
+	}
+	for _, ev := range b.ContractEvents {
+		if s.record("contract_event", ev.ChainID, ev.TxHash, ev.LogIndex) {
+			s.contractEvents = append(s.contractEvents, ev)
+		}
+	}
+	for _, l := range b.UnknownLogs {
+		if s.record("unknown_log", l.ChainID, l.TxHash, l.LogIndex) {
+			s.unknownLogs = append(s.unknownLogs, l)
+		}
+	}
+	for _, cp := range b.Checkpoints {
+		cp.UpdatedAt = time.Now()
+		s.checkpoints[checkpointKey{cp.ChainID, cp.Contract}] = cp
+	}
+	return nil
+}
+
+// unmined returns a transaction the server sent as it was before it was
+// mined, for the nonce manager to follow it onto the new branch.
+func unmined(tx model.Transaction) model.Transaction {
+	tx.Status = model.TransactionStatusPending
+	tx.BlockNumber, tx.BlockHash, tx.TransactionIndex = 0, "", 0
+	tx.GasUsed, tx.EffectiveGasPrice = 0, model.BigInt{}
+	tx.UpdatedAt = time.Now()
+	return tx
+}
+
+// record marks the log of a record of table as recorded. It returns false
+// if it already was. s.mu must be held.
+func (s *Store) record(table string, chainID uint64, txHash string, logIndex uint) bool {
+	k := logKey{table, chainID, txHash, logIndex}
+	if s.logs[k] {
+		return false
+	}
+	s.logs[k] = true
+	return true
+}
+
+// stat adds a listing or sale mined at t to its hourly rollup, or removes it
+// if delta is -1. s.mu must be held.
+func (s *Store) stat(delta int, kind string, chainID uint64, market, nftContract string, t time.Time, price *big.Int, seller, buyer string) {
+	k := statsKey{chainID, kind, market, nftContract, t.UTC().Truncate(time.Hour)}
+	h := s.stats[k]
+	if h == nil {
+		h = &hourStats{prices: make(map[string]*priceCount), sellers: make(map[string]int), buyers: make(map[string]int)}
+		s.stats[k] = h
+	}
+	pc := h.prices[price.String()]
+	if pc == nil {
+		pc = &priceCount{price: new(big.Int).Set(price)}
+		h.prices[price.String()] = pc
+	}
+	if pc.count += delta; pc.count <= 0 {
+		delete(h.prices, price.String())
+	}
+	count := func(m map[string]int, account string) {
+		if m[account] += delta; m[account] <= 0 {
+			delete(m, account)
+		}
+	}
+	count(h.sellers, seller)
+	if kind == model.MarketActivitySale {
+		count(h.buyers, buyer)
+	}
+	if len(h.prices) == 0 {
+		delete(s.stats, k)
+	}
+}
+
+// own makes the recipient of t the owner of its token unless a later
+// transfer already moved it. s.mu must be held.
+func (s *Store) own(t model.NFTTransfer) {
+	k := ownerKeyOf(t.ChainID, t.Contract, t.TokenID.Big())
+	if o, ok := s.owners[k]; ok && (o.BlockNumber > t.BlockNumber || o.BlockNumber == t.BlockNumber && o.LogIndex >= t.LogIndex) {
+		return
+	}
+	s.owners[k] = model.NFTOwner{
+		ChainID:     t.ChainID,
+		Contract:    t.Contract,
+		TokenID:     t.TokenID,
+		Owner:       t.ToAddress,
+		BlockNumber: t.BlockNumber,
+		LogIndex:    t.LogIndex,
+		TxHash:      t.TxHash,
+		UpdatedAt:   time.Now(),
+	}
+}
+
+// floor records the floor price of a collection after a block. s.mu must be
+// held.
+func (s *Store) floor(k floorKey) {
+	fp := model.FloorPrice{ChainID: k.chainID, Market: k.market, NftContract: k.nftContract, BlockNumber: k.block}
+	if blk, ok := s.blocks[blockKey{k.chainID, k.block}]; ok {
+		fp.BlockTime = blk.Time
+	}
+	sold := make(map[string]bool)
+	for _, sale := range s.sales {
+		if sale.ChainID == k.chainID && sale.Market == k.market && sale.BlockNumber <= k.block {
+			sold[sale.ItemID.String()] = true
+		}
+	}
+	for _, it := range s.marketItems {
+		if it.ChainID != k.chainID || it.Market != k.market || it.NftContract != k.nftContract ||
+			it.BlockNumber > k.block || sold[it.ItemID.String()] {
+			continue
+		}
+		fp.Listed++
+		if fp.Floor == nil || it.Price.Cmp(fp.Floor.Big()) < 0 {
+			price := it.Price
+			fp.Floor = &price
+		}
+	}
+	s.floors[k] = fp
+}
+
+func (s *Store) Checkpoint(ctx context.Context, chainID uint64, contract string) (*model.Checkpoint, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	cp, ok := s.checkpoints[checkpointKey{chainID, contract}]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	return &cp, nil
+}
+
+func (s *Store) RecentBlocks(ctx context.Context, chainID uint64, limit int) ([]model.Block, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var blocks []model.Block
+	for k, blk := range s.blocks {
+		if k.chainID == chainID {
+			blocks = append(blocks, blk)
+		}
+	}
+	sort.Slice(blocks, func(i, j int) bool { return blocks[i].Number < blocks[j].Number })
+	if len(blocks) > limit {
+		blocks = blocks[len(blocks)-limit:]
+	}
+	return blocks, nil
+}
+
+func (s *Store) Rollback(ctx context.Context, chainID uint64, ancestor model.Block) (*store.Batch, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	removed := &store.Batch{FromBlock: ancestor.Number + 1}
+	orphaned := func(chain, number uint64) bool {
+		return chain == chainID && number > ancestor.Number
+	}
+
+	for k, blk := range s.blocks {
+		if orphaned(k.chainID, k.number) {
+			removed.Blocks = append(removed.Blocks, blk)
+			delete(s.blocks, k)
+		}
+	}
+
+	for k, tx := range s.transactions {
+		if !orphaned(k.chainID, tx.BlockNumber) {
+			continue
+		}
+		removed.Transactions = append(removed.Transactions, tx)
+		if tx.Sent {
+			s.transactions[k] = unmined(tx)
+		} else {
+			delete(s.transactions, k)
+		}
+	}
+
+	for k := range s.floors {
+		if orphaned(k.chainID, k.block) {
+			delete(s.floors, k)
+		}
+	}
+
+	marketItems := s.marketItems[:0]
+	for _, it := range s.marketItems {
+		if orphaned(it.ChainID, it.BlockNumber) {
+			removed.MarketItems = append(removed.MarketItems, it)
+			continue
+		}
+		marketItems = append(marketItems, it)
+	}
+	s.marketItems = marketItems
+
+	transfers := s.transfers[:0]
+	for _, t := range s.transfers {
+		if orphaned(t.ChainID, t.BlockNumber) {
+			removed.Transfers = append(removed.Transfers, t)
+			continue
+		}
+		transfers = append(transfers, t)
+	}
+	s.transfers = transfers
+	moved := make(map[ownerKey]bool)
+	for _, t := range removed.Transfers {
+		k := ownerKeyOf(t.ChainID, t.Contract, t.TokenID.Big())
+		if !moved[k] {
+			moved[k] = true
+			delete(s.owners, k)
+		}
+	}
+	for _, t := range s.transfers {
+		if moved[ownerKeyOf(t.ChainID, t.Contract, t.TokenID.Big())] {
+			s.own(t)
+		}
+	}
+	// Tokens with no transfer left were minted on the orphaned branch.
+	for k := range moved {
+		if _, ok := s.owners[k]; !ok {
+			delete(s.metadata, k)
+		}
+	}
+
+	approvals := s.approvals[:0]
+	for _, a := range s.approvals {
+		if orphaned(a.ChainID, a.BlockNumber) {
+			removed.Approvals = append(removed.Approvals, a)
+			continue
+		}
+		approvals = append(approvals, a)
+	}
+	s.approvals = approvals
+
+	approvalsForAll := s.approvalsForAll[:0]
+	for _, a := range s.approvalsForAll {
+		if orphaned(a.ChainID, a.BlockNumber) {
+			removed.ApprovalsForAll = append(removed.ApprovalsForAll, a)
+			continue
+		}
+		approvalsForAll = append(approvalsForAll, a)
+	}
+	s.approvalsForAll = approvalsForAll
+
+	sales := s.sales[:0]
+	for _, sale := range s.sales {
+		if orphaned(sale.ChainID, sale.BlockNumber) {
+			removed.Sales = append(removed.Sales, sale)
+			if it := s.item(sale.ChainID, sale.Market, sale.ItemID.Big()); it != nil {
+				it.Sold = false
+				it.Owner = store.UnsoldOwner
+			}
+			continue
+		}
+		sales = append(sales, sale)
+	}
+	s.sales = sales
+
+	contractEvents := s.contractEvents[:0]
+	for _, ev := range s.contractEvents {
+		if orphaned(ev.ChainID, ev.BlockNumber) {
+			removed.ContractEvents = append(removed.ContractEvents, ev)
+			continue
+		}
+		contractEvents = append(contractEvents, ev)
+	}
+	s.contractEvents = contractEvents
+
+	unknownLogs := s.unknownLogs[:0]
+	for _, l := range s.unknownLogs {
+		if orphaned(l.ChainID, l.BlockNumber) {
+			removed.UnknownLogs = append(removed.UnknownLogs, l)
+			continue
+		}
+		unknownLogs = append(unknownLogs, l)
+	}
+	s.unknownLogs = unknownLogs
+
+	for k, snaps := range s.snapshots {
+		i := sort.Search(len(snaps), func(i int) bool { return orphaned(k.chainID, snaps[i].BlockNumber) })
+		s.snapshots[k] = snaps[:i]
+	}
+
+	for k, cp := range s.checkpoints {
+		if orphaned(k.chainID, cp.BlockNumber) {
+			cp.BlockNumber = ancestor.Number
+			cp.BlockHash = ancestor.Hash
+			cp.UpdatedAt = time.Now()
+			s.checkpoints[k] = cp
+		}
+	}
+
+	times := make(map[uint64]time.Time, len(removed.Blocks))
+	for _, blk := range removed.Blocks {
+		times[blk.Number] = blk.Time
+	}
+	for _, it := range removed.MarketItems {
+		delete(s.logs, logKey{"market_item", it.ChainID, it.TxHash, it.LogIndex})
+		if t, ok := times[it.BlockNumber]; ok {
+			s.stat(-1, model.MarketActivityListing, it.ChainID, it.Market, it.NftContract, t, it.Price.Big(), it.Seller, "")
+		}
+	}
+	for _, sale := range removed.Sales {
+		delete(s.logs, logKey{"market_sale", sale.ChainID, sale.TxHash, sale.LogIndex})
+		if t, ok := times[sale.BlockNumber]; ok {
+			s.stat(-1, model.MarketActivitySale, sale.ChainID, sale.Market, sale.NftContract, t, sale.Price.Big(), sale.Seller, sale.Buyer)
+		}
+	}
+	for _, t := range removed.Transfers {
+		delete(s.logs, logKey{"nft_transfer", t.ChainID, t.TxHash, t.LogIndex})
+	}
+	for _, a := range removed.Approvals {
+		delete(s.logs, logKey{"nft_approval", a.ChainID, a.TxHash, a.LogIndex})
+	}
+	for _, a := range removed.ApprovalsForAll {
+		delete(s.logs, logKey{"nft_approval_for_all", a.ChainID, a.TxHash, a.LogIndex})
+	}
+	for _, ev := range removed.ContractEvents {
+		delete(s.logs, logKey{"contract_event", ev.ChainID, ev.TxHash, ev.LogIndex})
+	}
+	for _, l := range removed.UnknownLogs {
+		delete(s.logs, logKey{"unknown_log", l.ChainID, l.TxHash, l.LogIndex})
+	}
+
+	for _, blk := range removed.Blocks {
+		if blk.Number > removed.ToBlock {
+			removed.ToBlock = blk.Number
+		}
+	}
+	return removed, nil
+}
+
+func (s *Store) Contracts(ctx context.Context, chainID uint64) ([]model.Contract, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var contracts []model.Contract
+	for k, c := range s.contracts {
+		if k.chainID == chainID {
+			contracts = append(contracts, c)
+		}
+	}
+	sort.Slice(contracts, func(i, j int) bool { return contracts[i].Address < contracts[j].Address })
+	return contracts, nil
+}
+
+func (s *Store) SaveContract(ctx context.Context, c *model.Contract) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k := checkpointKey{c.ChainID, c.Address}
+	now := time.Now()
+	if old, ok := s.contracts[k]; ok {
+		c.CreatedAt = old.CreatedAt
+	} else {
+		c.CreatedAt = now
+	}
+	c.UpdatedAt = now
+	s.contracts[k] = *c
+	return nil
+}
+
+func (s *Store) DeleteContract(ctx context.Context, chainID uint64, address string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k := checkpointKey{chainID, address}
+	if _, ok := s.contracts[k]; !ok {
+		return store.ErrNotFound
+	}
+	delete(s.contracts, k)
+	return nil
+}
+
+func (s *Store) MarketItems(ctx context.Context, q store.MarketItemQuery) ([]model.MarketItem, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var items []model.MarketItem
+	for _, it := range s.marketItems {
+		if matchMarketItem(&q, &it) && s.hasTraits(&q, &it) {
+			items = append(items, it)
+		}
+	}
+
+	// less orders by the sort key, then by id.
+	less := func(a, b model.BigInt, aID, bID int) bool {
+		if c := a.Cmp(b.Big()); c != 0 {
+			return c < 0 != q.Desc
+		}
+		return aID != bID && aID < bID != q.Desc
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return less(q.SortKey(&items[i]), q.SortKey(&items[j]), items[i].ID, items[j].ID)
+	})
+	if q.After != nil {
+		i := sort.Search(len(items), func(i int) bool {
+			return less(q.After.Key, q.SortKey(&items[i]), q.After.ID, items[i].ID)
+		})
+		items = items[i:]
+	}
+	if q.Limit > 0 && len(items) > q.Limit {
+		items = items[:q.Limit]
+	}
+	return items, nil
+}
+
+func matchMarketItem(q *store.MarketItemQuery, it *model.MarketItem) bool {
+	switch {
+	case it.ChainID != q.ChainID,
+		q.Market != "" && it.Market != q.Market,
+		q.NftContract != "" && it.NftContract != q.NftContract,
+		q.Seller != "" && it.Seller != q.Seller,
+		q.Owner != "" && it.Owner != q.Owner,
+		q.Sold != nil && it.Sold != *q.Sold,
+		q.MinPrice != nil && it.Price.Cmp(q.MinPrice) < 0,
+		q.MaxPrice != nil && it.Price.Cmp(q.MaxPrice) > 0,
+		q.FromBlock != nil && it.BlockNumber < *q.FromBlock,
+		q.ToBlock != nil && it.BlockNumber > *q.ToBlock:
+		return false
+	}
+	return true
+}
+
+// hasTraits reports whether the token of it has the traits q selects.
+func (s *Store) hasTraits(q *store.MarketItemQuery, it *model.MarketItem) bool {
+	if len(q.Traits) == 0 {
+		return true
+	}
+	r := s.rarity[checkpointKey{it.ChainID, it.NftContract}]
+	if r == nil {
+		return false
+	}
+	traits := r.traits[it.TokenID.String()]
+	for typ, values := range q.Traits {
+		found := false
+		for _, t := range traits {
+			for _, v := range values {
+				found = found || t.TraitType == typ && t.Value == v
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *Store) MarketItem(ctx context.Context, chainID uint64, market string, itemID *big.Int) (*model.MarketItem, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	it := s.item(chainID, market, itemID)
+	if it == nil {
+		return nil, store.ErrNotFound
+	}
+	cp := *it
+	return &cp, nil
+}
+
+// item returns the stored market item, or nil. s.mu must be held.
+func (s *Store) item(chainID uint64, market string, itemID *big.Int) *model.MarketItem {
+	for i := range s.marketItems {
+		it := &s.marketItems[i]
+		if it.ChainID == chainID && it.Market == market && it.ItemID.Cmp(itemID) == 0 {
+			return it
+		}
+	}
+	return nil
+}
+
+func (s *Store) Sale(ctx context.Context, chainID uint64, market string, itemID *big.Int) (*model.MarketSale, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, sale := range s.sales {
+		if sale.ChainID == chainID && sale.Market == market && sale.ItemID.Cmp(itemID) == 0 {
+			return &sale, nil
+		}
+	}
+	return nil, store.ErrNotFound
+}
+
+func (s *Store) TokenOwner(ctx context.Context, chainID uint64, contract string, tokenID *big.Int) (*model.NFTOwner, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	o, ok := s.owners[ownerKeyOf(chainID, contract, tokenID)]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	return &o, nil
+}
+
+func (s *Store) OwnedTokens(ctx context.Context, q store.OwnedTokenQuery) ([]model.NFTOwner, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var tokens []model.NFTOwner
+	for _, o := range s.owners {
+		if o.ChainID != q.ChainID || o.Owner != q.Owner || q.Contract != "" && o.Contract != q.Contract {
+			continue
+		}
+		if q.After != nil {
+			n := q.After.Key.Uint64()
+			if o.BlockNumber < n || o.BlockNumber == n && o.LogIndex <= uint(q.After.ID) {
+				continue
+			}
+		}
+		tokens = append(tokens, o)
+	}
+	sort.Slice(tokens, func(i, j int) bool {
+		if tokens[i].BlockNumber != tokens[j].BlockNumber {
+			return tokens[i].BlockNumber < tokens[j].BlockNumber
+		}
+		return tokens[i].LogIndex < tokens[j].LogIndex
+	})
+	if q.Limit > 0 && len(tokens) > q.Limit {
+		tokens = tokens[:q.Limit]
+	}
+	return tokens, nil
+}
+
+func (s *Store) NFTBalances(ctx context.Context, chainID uint64, owner string) ([]model.NFTBalance, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	counts := make(map[string]int)
+	for _, o := range s.owners {
+		if o.ChainID == chainID && o.Owner == owner {
+			counts[o.Contract]++
+		}
+	}
+	balances := make([]model.NFTBalance, 0, len(counts))
+	for contract, n := range counts {
+		balances = append(balances, model.NFTBalance{Contract: contract, Balance: n})
+	}
+	sort.Slice(balances, func(i, j int) bool { return balances[i].Contract < balances[j].Contract })
+	return balances, nil
+}
+
+func (s *Store) TokenRecords(ctx context.Context, chainID uint64, contract string, tokenID *big.Int) (*store.Batch, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	b := &store.Batch{}
+	numbers := make(map[uint64]bool)
+	for _, t := range s.transfers {
+		if t.ChainID == chainID && t.Contract == contract && t.TokenID.Cmp(tokenID) == 0 {
+			b.Transfers = append(b.Transfers, t)
+			numbers[t.BlockNumber] = true
+		}
+	}
+	for _, a := range s.approvals {
+		if a.ChainID == chainID && a.Contract == contract && a.TokenID.Cmp(tokenID) == 0 {
+			b.Approvals = append(b.Approvals, a)
+			numbers[a.BlockNumber] = true
+		}
+	}
+	for _, it := range s.marketItems {
+		if it.ChainID == chainID && it.NftContract == contract && it.TokenID.Cmp(tokenID) == 0 {
+			b.MarketItems = append(b.MarketItems, it)
+			numbers[it.BlockNumber] = true
+		}
+	}
+	for _, sale := range s.sales {
+		if sale.ChainID == chainID && sale.NftContract == contract && sale.TokenID.Cmp(tokenID) == 0 {
+			b.Sales = append(b.Sales, sale)
+			numbers[sale.BlockNumber] = true
+		}
+	}
+	for n := range numbers {
+		if blk, ok := s.blocks[blockKey{chainID, n}]; ok {
+			b.Blocks = append(b.Blocks, blk)
+		}
+	}
+	sort.Slice(b.Blocks, func(i, j int) bool { return b.Blocks[i].Number < b.Blocks[j].Number })
+	sort.Slice(b.Transfers, func(i, j int) bool {
+		return chainOrder(b.Transfers[i].BlockNumber, b.Transfers[i].LogIndex, b.Transfers[j].BlockNumber, b.Transfers[j].LogIndex)
+	})
+	sort.Slice(b.Approvals, func(i, j int) bool {
+		return chainOrder(b.Approvals[i].BlockNumber, b.Approvals[i].LogIndex, b.Approvals[j].BlockNumber, b.Approvals[j].LogIndex)
+	})
+	sort.Slice(b.MarketItems, func(i, j int) bool {
+		return chainOrder(b.MarketItems[i].BlockNumber, b.MarketItems[i].LogIndex, b.MarketItems[j].BlockNumber, b.MarketItems[j].LogIndex)
+	})
+	sort.Slice(b.Sales, func(i, j int) bool {
+		return chainOrder(b.Sales[i].BlockNumber, b.Sales[i].LogIndex, b.Sales[j].BlockNumber, b.Sales[j].LogIndex)
+	})
+	return b, nil
+}
+
+func (s *Store) AccountRecords(ctx context.Context, chainID uint64, account string) (*store.Batch, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	b := &store.Batch{}
+	for _, t := range s.transfers {
+		if t.ChainID == chainID && (t.FromAddress == account || t.ToAddress == account) {
+			b.Transfers = append(b.Transfers, t)
+		}
+	}
+	for _, it := range s.marketItems {
+		if it.ChainID == chainID && it.Seller == account {
+			b.MarketItems = append(b.MarketItems, it)
+			if tx, ok := s.transactions[txKey{chainID, it.TxHash}]; ok {
+				b.Transactions = append(b.Transactions, tx)
+			}
+		}
+	}
+	for _, sale := range s.sales {
+		if sale.ChainID == chainID && (sale.Buyer == account || sale.Seller == account) {
+			b.Sales = append(b.Sales, sale)
+		}
+	}
+	sort.Slice(b.Transfers, func(i, j int) bool {
+		return chainOrder(b.Transfers[i].BlockNumber, b.Transfers[i].LogIndex, b.Transfers[j].BlockNumber, b.Transfers[j].LogIndex)
+	})
+	sort.Slice(b.MarketItems, func(i, j int) bool {
+		return chainOrder(b.MarketItems[i].BlockNumber, b.MarketItems[i].LogIndex, b.MarketItems[j].BlockNumber, b.MarketItems[j].LogIndex)
+	})
+	sort.Slice(b.Sales, func(i, j int) bool {
+		return chainOrder(b.Sales[i].BlockNumber, b.Sales[i].LogIndex, b.Sales[j].BlockNumber, b.Sales[j].LogIndex)
+	})
+	return b, nil
+}
+
+// chainOrder reports whether the log at block a and index ai precedes the log
+// at block b and index bi.
+func chainOrder(a uint64, ai uint, b uint64, bi uint) bool {
+	if a != b {
+		return a < b
+	}
+	return ai < bi
+}
+
+func (s *Store) OwnersAt(ctx context.Context, chainID uint64, contract string, block uint64) ([]model.NFTOwner, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.ownersAt(chainID, contract, block), nil
+}
+
+// ownersAt replays the transfers after the latest snapshot at or below block.
+// s.mu must be held.
+func (s *Store) ownersAt(chainID uint64, contract string, block uint64) []model.NFTOwner {
+	owners := make(map[string]model.NFTOwner)
+	replayFrom := uint64(0)
+	snaps := s.snapshots[checkpointKey{chainID, contract}]
+	if i := sort.Search(len(snaps), func(i int) bool { return snaps[i].BlockNumber > block }); i > 0 {
+		for _, o := range snaps[i-1].owners {
+			owners[o.TokenID.String()] = o
+		}
+		replayFrom = snaps[i-1].BlockNumber + 1
+	}
+
+	for _, t := range s.transfers {
+		if t.ChainID != chainID || t.Contract != contract || t.BlockNumber < replayFrom || t.BlockNumber > block {
+			continue
+		}
+		k := t.TokenID.String()
+		if o, ok := owners[k]; ok && (o.BlockNumber > t.BlockNumber || o.BlockNumber == t.BlockNumber && o.LogIndex >= t.LogIndex) {
+			continue
+		}
+		owners[k] = model.NFTOwner{
+			ChainID:     chainID,
+			Contract:    contract,
+			TokenID:     t.TokenID,
+			Owner:       t.ToAddress,
+			BlockNumber: t.BlockNumber,
+			LogIndex:    t.LogIndex,
+			TxHash:      t.TxHash,
+		}
+	}
+
+	list := make([]model.NFTOwner, 0, len(owners))
+	for _, o := range owners {
+		if o.Owner != store.UnsoldOwner {
+			list = append(list, o)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].TokenID.Cmp(list[j].TokenID.Big()) < 0 })
+	return list
+}
+
+func (s *Store) SaveSnapshot(ctx context.Context, chainID uint64, contract string, block uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k := checkpointKey{chainID, contract}
+	snaps := s.snapshots[k]
+	i := sort.Search(len(snaps), func(i int) bool { return snaps[i].BlockNumber >= block })
+	if i < len(snaps) && snaps[i].BlockNumber == block {
+		return nil
+	}
+	owners := s.ownersAt(chainID, contract, block)
+	snap := snapshot{
+		NFTSnapshot: model.NFTSnapshot{
+			ChainID:     chainID,
+			Contract:    contract,
+			BlockNumber: block,
+			Tokens:      len(owners),
+			CreatedAt:   time.Now(),
+		},
+		owners: owners,
+	}
+	snaps = append(snaps, snapshot{})
+	copy(snaps[i+1:], snaps[i:])
+	snaps[i] = snap
+	s.snapshots[k] = snaps
+	return nil
+}
+
+func (s *Store) LatestSnapshot(ctx context.Context, chainID uint64, contract string) (*model.NFTSnapshot, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	snaps := s.snapshots[checkpointKey{chainID, contract}]
+	if len(snaps) == 0 {
+		return nil, store.ErrNotFound
+	}
+	snap := snaps[len(snaps)-1].NFTSnapshot
+	return &snap, nil
+}
+
+func (s *Store) Transaction(ctx context.Context, chainID uint64, hash string) (*model.Transaction, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	tx, ok := s.transactions[txKey{chainID, hash}]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	return &tx, nil
+}
+
+func (s *Store) SaveTransaction(ctx context.Context, tx *model.Transaction) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k := txKey{tx.ChainID, tx.TxHash}
+	tx.Sent = true
+	tx.UpdatedAt = time.Now()
+	if old, ok := s.transactions[k]; ok {
+		tx.ID, tx.CreatedAt = old.ID, old.CreatedAt
+	} else {
+		s.lastID++
+		tx.ID = s.lastID
+		if tx.CreatedAt.IsZero() {
+			tx.CreatedAt = tx.UpdatedAt
+		}
+	}
+	s.transactions[k] = *tx
+	return nil
+}
+
+func (s *Store) SentTransactions(ctx context.Context, q store.SentTransactionQuery) ([]model.Transaction, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var txs []model.Transaction
+	for _, tx := range s.transactions {
+		switch {
+		case !tx.Sent,
+			tx.ChainID != q.ChainID,
+			q.From != "" && tx.FromAddress != q.From,
+			q.Nonce != nil && tx.Nonce != *q.Nonce,
+			q.Status != "" && tx.Status != q.Status:
+			continue
+		}
+		txs = append(txs, tx)
+	}
+	sort.Slice(txs, func(i, j int) bool {
+		if txs[i].Nonce != txs[j].Nonce {
+			return txs[i].Nonce < txs[j].Nonce
+		}
+		return txs[i].ID < txs[j].ID
+	})
+	return txs, nil
+}
+
+func (s *Store) Block(ctx context.Context, chainID uint64, number uint64) (*model.Block, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	blk, ok := s.blocks[blockKey{chainID, number}]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	return &blk, nil
+}
+
+func (s *Store) BlockAt(ctx context.Context, chainID uint64, t time.Time) (*model.Block, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var found *model.Block
+	for k, blk := range s.blocks {
+		if k.chainID != chainID || blk.Time.After(t) {
+			continue
+		}
+		if found == nil || blk.Number > found.Number {
+			blk := blk
+			found = &blk
+		}
+	}
+	if found == nil {
+		return nil, store.ErrNotFound
+	}
+	return found, nil
+}
+
+func (s *Store) Collections(ctx context.Context, chainID uint64, market string) ([]model.CollectionStats, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.collections(chainID, market, ""), nil
+}
+
+func (s *Store) Collection(ctx context.Context, chainID uint64, market, nftContract string) (*model.CollectionStats, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	cs := s.collections(chainID, market, nftContract)
+	if len(cs) == 0 {
+		return nil, store.ErrNotFound
+	}
+	return &cs[0], nil
+}
+
+// collections returns the listing state of the collections of a market, or
+// of nftContract only if it is set. s.mu must be held.
+func (s *Store) collections(chainID uint64, market, nftContract string) []model.CollectionStats {
+	byContract := make(map[string]*model.CollectionStats)
+	for _, it := range s.marketItems {
+		if it.ChainID != chainID || it.Market != market || nftContract != "" && it.NftContract != nftContract {
+			continue
+		}
+		c := byContract[it.NftContract]
+		if c == nil {
+			c = &model.CollectionStats{Market: market, NftContract: it.NftContract}
+			byContract[it.NftContract] = c
+		}
+		if it.Sold {
+			continue
+		}
+		c.Listed++
+		if c.Floor == nil || it.Price.Cmp(c.Floor.Big()) < 0 {
+			price := it.Price
+			c.Floor = &price
+		}
+	}
+	supply := make(map[string]int)
+	for _, o := range s.owners {
+		if o.ChainID == chainID && byContract[o.Contract] != nil && o.Owner != store.UnsoldOwner {
+			supply[o.Contract]++
+		}
+	}
+
+	cs := make([]model.CollectionStats, 0, len(byContract))
+	for contract, c := range byContract {
+		c.SetSupply(supply[contract])
+		cs = append(cs, *c)
+	}
+	sort.Slice(cs, func(i, j int) bool { return cs[i].NftContract < cs[j].NftContract })
+	return cs
+}
+
+func (s *Store) FloorHistory(ctx context.Context, q store.FloorHistoryQuery) ([]model.FloorPrice, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var history []model.FloorPrice
+	for k, fp := range s.floors {
+		if k.chainID != q.ChainID || k.market != q.Market || k.nftContract != q.NftContract {
+			continue
+		}
+		if !q.From.IsZero() && fp.BlockTime.Before(q.From) || !q.To.IsZero() && !fp.BlockTime.Before(q.To) {
+			continue
+		}
+		history = append(history, fp)
+	}
+	sort.Slice(history, func(i, j int) bool { return history[i].BlockNumber < history[j].BlockNumber })
+	return history, nil
+}
+
+func (s *Store) QueueMetadata(ctx context.Context, ms []model.TokenMetadata) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, m := range ms {
+		k := ownerKeyOf(m.ChainID, m.Contract, m.TokenID.Big())
+		if _, ok := s.metadata[k]; !ok {
+			m.CreatedAt, m.UpdatedAt = time.Now(), time.Now()
+			s.metadata[k] = m
+		}
+	}
+	return nil
+}
+
+func (s *Store) DueMetadata(ctx context.Context, chainID uint64, now time.Time, limit int) ([]model.TokenMetadata, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var due []model.TokenMetadata
+	for _, m := range s.metadata {
+		if m.ChainID == chainID && m.Status == model.MetadataPending && !m.NextAttemptAt.After(now) {
+			due = append(due, m)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].NextAttemptAt.Before(due[j].NextAttemptAt) })
+	if limit > 0 && len(due) > limit {
+		due = due[:limit]
+	}
+	return due, nil
+}
+
+func (s *Store) SaveMetadata(ctx context.Context, m *model.TokenMetadata) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m.UpdatedAt = time.Now()
+	s.metadata[ownerKeyOf(m.ChainID, m.Contract, m.TokenID.Big())] = *m
+	return nil
+}
+
+func (s *Store) TokenMetadata(ctx context.Context, chainID uint64, contract string, tokenID *big.Int) (*model.TokenMetadata, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	m, ok := s.metadata[ownerKeyOf(chainID, contract, tokenID)]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	return &m, nil
+}
+
+func (s *Store) CollectionMetadata(ctx context.Context, chainID uint64, contract string) ([]model.TokenMetadata, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var ms []model.TokenMetadata
+	for _, m := range s.metadata {
+		if m.ChainID == chainID && m.Contract == contract && m.Status == model.MetadataResolved {
+			ms = append(ms, m)
+		}
+	}
+	sort.Slice(ms, func(i, j int) bool { return ms[i].TokenID.Cmp(ms[j].TokenID.Big()) < 0 })
+	return ms, nil
+}
+
+func (s *Store) SaveRarity(ctx context.Context, chainID uint64, contract string, traits []model.TokenTrait, counts []model.TraitCount, tokens []model.TokenRarity, removed []model.BigInt) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k := checkpointKey{chainID, contract}
+	r := s.rarity[k]
+	if r == nil {
+		r = &rarity{traits: make(map[string][]model.TokenTrait), tokens: make(map[string]model.TokenRarity)}
+		s.rarity[k] = r
+	}
+	added := make(map[string]bool)
+	for _, t := range traits {
+		id := t.TokenID.String()
+		if _, ok := r.traits[id]; ok && !added[id] {
+			continue
+		}
+		added[id] = true
+		r.traits[id] = append(r.traits[id], t)
+	}
+	for _, t := range tokens {
+		r.tokens[t.TokenID.String()] = t
+	}
+	for _, id := range removed {
+		delete(r.traits, id.String())
+		delete(r.tokens, id.String())
+	}
+	r.counts = append([]model.TraitCount(nil), counts...)
+	sort.Slice(r.counts, func(i, j int) bool {
+		a, b := &r.counts[i], &r.counts[j]
+		if a.TraitType != b.TraitType {
+			return a.TraitType < b.TraitType
+		}
+		return a.Value < b.Value
+	})
+	return nil
+}
+
+func (s *Store) CollectionRarity(ctx context.Context, chainID uint64, contract string) ([]model.TokenRarity, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	r := s.rarity[checkpointKey{chainID, contract}]
+	if r == nil {
+		return nil, nil
+	}
+	rs := make([]model.TokenRarity, 0, len(r.tokens))
+	for _, t := range r.tokens {
+		rs = append(rs, t)
+	}
+	sort.Slice(rs, func(i, j int) bool { return rs[i].TokenID.Big().Cmp(rs[j].TokenID.Big()) < 0 })
+	return rs, nil
+}
+
+func (s *Store) TraitCounts(ctx context.Context, chainID uint64, contract string) ([]model.TraitCount, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	r := s.rarity[checkpointKey{chainID, contract}]
+	if r == nil {
+		return nil, nil
+	}
+	return append([]model.TraitCount(nil), r.counts...), nil
+}
+
+func (s *Store) TokenRarity(ctx context.Context, chainID uint64, contract string, tokenID *big.Int) (*model.TokenRarity, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	r := s.rarity[checkpointKey{chainID, contract}]
+	if r == nil {
+		return nil, store.ErrNotFound
+	}
+	t, ok := r.tokens[tokenID.String()]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	return &t, nil
+}
+
+func (s *Store) SaveMintJob(ctx context.Context, j *model.MintJob) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	j.UpdatedAt = time.Now()
+	for i := range s.mintJobs {
+		if s.mintJobs[i].ID == j.ID {
+			s.mintJobs[i] = *j
+			return nil
+		}
+	}
+	s.lastID++
+	j.ID, j.CreatedAt = s.lastID, j.UpdatedAt
+	s.mintJobs = append(s.mintJobs, *j)
+	return nil
+}
+
+func (s *Store) MintJob(ctx context.Context, chainID uint64, id int) (*model.MintJob, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, j := range s.mintJobs {
+		if j.ChainID == chainID && j.ID == id {
+			return &j, nil
+		}
+	}
+	return nil, store.ErrNotFound
+}
+
+func (s *Store) MintJobs(ctx context.Context, chainID uint64, status model.MintStatus) ([]model.MintJob, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var jobs []model.MintJob
+	for _, j := range s.mintJobs {
+		if j.ChainID == chainID && (status == "" || j.Status == status) {
+			jobs = append(jobs, j)
+		}
+	}
+	return jobs, nil
+}
+
+func (s *Store) MarketStats(ctx context.Context, q store.MarketStatsQuery) ([]model.MarketStats, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	// bucket merges the hourly rollups of a bucket.
+	type bucket struct {
+		prices  map[string]*priceCount
+		sellers map[string]bool
+		buyers  map[string]bool
+	}
+	buckets := make(map[time.Time]map[string]*bucket)
+	for k, h := range s.stats {
+		if k.chainID != q.ChainID || q.Market != "" && k.market != q.Market ||
+			q.NftContract != "" && k.nftContract != q.NftContract ||
+			k.hour.Before(q.From) || !k.hour.Before(q.To) {
+			continue
+		}
+		start := q.Interval.Truncate(k.hour)
+		if buckets[start] == nil {
+			buckets[start] = make(map[string]*bucket)
+		}
+		b := buckets[start][k.kind]
+		if b == nil {
+			b = &bucket{prices: make(map[string]*priceCount), sellers: make(map[string]bool), buyers: make(map[string]bool)}
+			buckets[start][k.kind] = b
+		}
+		for key, pc := range h.prices {
+			if b.prices[key] == nil {
+				b.prices[key] = &priceCount{price: pc.price}
+			}
+			b.prices[key].count += pc.count
+		}
+		for a := range h.sellers {
+			b.sellers[a] = true
+		}
+		for a := range h.buyers {
+			b.buyers[a] = true
+		}
+	}
+
+	stats := make([]model.MarketStats, 0, len(buckets))
+	for start, kinds := range buckets {
+		st := model.MarketStats{Start: start}
+		for kind, b := range kinds {
+			if kind == model.MarketActivitySale {
+				st.Sales = priceStats(b.prices, len(b.buyers), len(b.sellers))
+			} else {
+				st.Listings = priceStats(b.prices, 0, len(b.sellers))
+			}
+		}
+		stats = append(stats, st)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Start.Before(stats[j].Start) })
+	return stats, nil
+}
+
+// priceStats summarizes a price histogram.
+func priceStats(prices map[string]*priceCount, buyers, sellers int) model.PriceStats {
+	st := model.PriceStats{Buyers: buyers, Sellers: sellers}
+	sorted := make([]*priceCount, 0, len(prices))
+	volume := new(big.Int)
+	for _, pc := range prices {
+		sorted = append(sorted, pc)
+		st.Count += pc.count
+		volume.Add(volume, new(big.Int).Mul(pc.price, big.NewInt(int64(pc.count))))
+	}
+	if st.Count == 0 {
+		return st
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].price.Cmp(sorted[j].price) < 0 })
+	st.Volume = model.NewBigInt(volume)
+	st.Min = model.NewBigInt(sorted[0].price)
+	st.Max = model.NewBigInt(sorted[len(sorted)-1].price)
+	st.Avg = model.NewBigInt(new(big.Int).Quo(volume, big.NewInt(int64(st.Count))))
+	below := 0
+	for _, pc := range sorted {
+		if below += pc.count; 2*below >= st.Count {
+			st.Median = model.NewBigInt(pc.price)
+			break
+		}
+	}
+	return st
+}
+
+func (s *Store) TotalVolume(ctx context.Context, chainID uint64) (*big.Int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	total := big.NewInt(0)
+	for i := range s.marketItems {
+		if s.marketItems[i].ChainID == chainID {
+			total.Add(total, s.marketItems[i].Price.Big())
+		}
+	}
+	return total, nil
+}
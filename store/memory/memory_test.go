@@ -0,0 +1,301 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+
+	"blockchain.com/indexer/model"
+	"blockchain.com/indexer/store"
+)
+
+const testChainID = 1337
+
+var day = time.Date(2021, 11, 2, 0, 0, 0, 0, time.UTC)
+
+// batch returns a batch of blocks from through to, one every 20 minutes from
+// day, with a listing at price by seller in each.
+func batch(from, to uint64, price func(n uint64) int64, seller func(n uint64) string) *store.Batch {
+	b := &store.Batch{FromBlock: from, ToBlock: to}
+	for n := from; n <= to; n++ {
+		b.Blocks = append(b.Blocks, model.Block{
+			ChainID: testChainID,
+			Number:  n,
+			Hash:    fmt.Sprintf("0x%x", n),
+			Time:    day.Add(time.Duration(n) * 20 * time.Minute),
+		})
+		b.MarketItems = append(b.MarketItems, model.MarketItem{
+			ChainID:     testChainID,
+			Market:      "market",
+			ItemID:      model.NewBigInt(new(big.Int).SetUint64(n)),
+			NftContract: "nft",
+			TokenID:     model.NewBigInt(new(big.Int).SetUint64(n)),
+			Seller:      seller(n),
+			Owner:       store.UnsoldOwner,
+			Price:       model.NewBigInt(big.NewInt(price(n))),
+			BlockNumber: n,
+			TxHash:      fmt.Sprintf("0x%x", n),
+		})
+	}
+	return b
+}
+
+func TestMarketStats(t *testing.T) {
+	ctx := context.Background()
+	s := New()
+	// Blocks 0-8 span hours 0-2, three listings an hour, by two sellers.
+	price := func(n uint64) int64 { return int64(n%3+1) * 100 }
+	seller := func(n uint64) string { return fmt.Sprint("seller", n%2) }
+	for i := 0; i < 2; i++ {
+		// Committing twice must not count twice.
+		if err := s.Commit(ctx, batch(0, 8, price, seller)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stats := func(interval store.StatsInterval) []model.MarketStats {
+		t.Helper()
+		st, err := s.MarketStats(ctx, store.MarketStatsQuery{
+			ChainID: testChainID, Market: "market", Interval: interval, From: day, To: day.AddDate(0, 0, 1),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return st
+	}
+	check := func(name string, got model.PriceStats, count int, volume, min, max, avg, median int64, sellers int) {
+		t.Helper()
+		if got.Count != count || got.Volume.Int64() != volume || got.Min.Int64() != min || got.Max.Int64() != max ||
+			got.Avg.Int64() != avg || got.Median.Int64() != median || got.Sellers != sellers {
+			t.Errorf("%s: %+v, want count %d, volume %d, min %d, max %d, avg %d, median %d, %d sellers",
+				name, got, count, volume, min, max, avg, median, sellers)
+		}
+	}
+
+	hours := stats(store.IntervalHour)
+	if len(hours) != 3 {
+		t.Fatalf("%d hourly buckets, want 3", len(hours))
+	}
+	for i, h := range hours {
+		if !h.Start.Equal(day.Add(time.Duration(i) * time.Hour)) {
+			t.Errorf("bucket %d starts at %v", i, h.Start)
+		}
+		check(fmt.Sprint("hour ", i), h.Listings, 3, 600, 100, 300, 200, 200, 2)
+	}
+	days := stats(store.IntervalDay)
+	if len(days) != 1 {
+		t.Fatalf("%d daily buckets, want 1", len(days))
+	}
+	check("day", days[0].Listings, 9, 1800, 100, 300, 200, 200, 2)
+
+	// Orphaning blocks 5-8 takes their listings out of hours 1 and 2.
+	ancestor := model.Block{ChainID: testChainID, Number: 4, Hash: "0x4", Time: day.Add(80 * time.Minute)}
+	if _, err := s.Rollback(ctx, testChainID, ancestor); err != nil {
+		t.Fatal(err)
+	}
+	hours = stats(store.IntervalHour)
+	if len(hours) != 2 {
+		t.Fatalf("%d hourly buckets after the rollback, want 2", len(hours))
+	}
+	check("hour 0 after the rollback", hours[0].Listings, 3, 600, 100, 300, 200, 200, 2)
+	// Blocks 3 and 4 remain: prices 100 and 200, an even count, so the
+	// median is the lower middle price.
+	check("hour 1 after the rollback", hours[1].Listings, 2, 300, 100, 200, 150, 100, 2)
+	check("day after the rollback", stats(store.IntervalDay)[0].Listings, 5, 900, 100, 300, 180, 200, 2)
+
+	// The canonical branch lists again in the orphaned hours.
+	if err := s.Commit(ctx, batch(5, 6, func(uint64) int64 { return 1000 }, func(uint64) string { return "seller2" })); err != nil {
+		t.Fatal(err)
+	}
+	check("day on the new branch", stats(store.IntervalDay)[0].Listings, 7, 2900, 100, 1000, 414, 200, 3)
+}
+
+func TestCommitTwice(t *testing.T) {
+	ctx := context.Background()
+	s := New()
+	b := batch(1, 3, func(uint64) int64 { return 100 }, func(uint64) string { return "seller" })
+	b.Transfers = []model.NFTTransfer{{ChainID: testChainID, Contract: "nft", TokenID: model.NewBigInt(big.NewInt(1)), ToAddress: "a", BlockNumber: 1, TxHash: "0x1"}}
+	b.Sales = []model.MarketSale{{ChainID: testChainID, Market: "market", ItemID: model.NewBigInt(big.NewInt(1)), NftContract: "nft", Buyer: "buyer", Price: model.NewBigInt(big.NewInt(100)), BlockNumber: 2, TxHash: "0x2", LogIndex: 1}}
+	for i := 0; i < 2; i++ {
+		if err := s.Commit(ctx, b); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := len(s.marketItems); n != 3 {
+		t.Errorf("%d market items, want 3", n)
+	}
+	if n := len(s.sales); n != 1 {
+		t.Errorf("%d sales, want 1", n)
+	}
+	if n := len(s.transfers); n != 1 {
+		t.Errorf("%d transfers, want 1", n)
+	}
+	if vol, _ := s.TotalVolume(ctx, testChainID); vol.Int64() != 300 {
+		t.Errorf("total volume %v, want 300", vol)
+	}
+}
+
+func TestRollbackSentTransactions(t *testing.T) {
+	ctx := context.Background()
+	s := New()
+	sent := &model.Transaction{ChainID: testChainID, TxHash: "0xsent", FromAddress: "minter", Nonce: 4, Status: model.TransactionStatusPending}
+	if err := s.SaveTransaction(ctx, sent); err != nil {
+		t.Fatal(err)
+	}
+	// The indexer records the sent transaction once mined, along with one
+	// of the same sender that the server did not send.
+	b := &store.Batch{Transactions: []model.Transaction{
+		{ChainID: testChainID, TxHash: "0xsent", FromAddress: "minter", Nonce: 4, BlockNumber: 7, Status: model.TransactionStatusSuccess},
+		{ChainID: testChainID, TxHash: "0xother", FromAddress: "minter", Nonce: 5, BlockNumber: 7, Status: model.TransactionStatusSuccess},
+	}}
+	if err := s.Commit(ctx, b); err != nil {
+		t.Fatal(err)
+	}
+	sent.Status, sent.BlockNumber, sent.BlockHash, sent.GasUsed = model.TransactionStatusSuccess, 7, "0x7", 21000
+	if err := s.SaveTransaction(ctx, sent); err != nil {
+		t.Fatal(err)
+	}
+
+	txs, err := s.SentTransactions(ctx, store.SentTransactionQuery{ChainID: testChainID, From: "minter"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(txs) != 1 || txs[0].TxHash != "0xsent" {
+		t.Fatalf("sent transactions %v, want only 0xsent", txs)
+	}
+
+	removed, err := s.Rollback(ctx, testChainID, model.Block{ChainID: testChainID, Number: 6, Hash: "0x6"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(removed.Transactions); n != 2 {
+		t.Errorf("%d transactions removed, want 2", n)
+	}
+	if _, err := s.Transaction(ctx, testChainID, "0xother"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("indexed transaction: %v, want it removed", err)
+	}
+	tx, err := s.Transaction(ctx, testChainID, "0xsent")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tx.Status != model.TransactionStatusPending || tx.BlockNumber != 0 || tx.BlockHash != "" || tx.GasUsed != 0 || !tx.Sent {
+		t.Errorf("sent transaction %+v, want it back to pending", tx)
+	}
+}
+
+// transfers returns a batch of blocks from through to of a branch, each
+// moving a token of "nft" to an owner of the branch, some moving a second
+// one, and some putting one on sale.
+func transfers(branch string, from, to uint64) *store.Batch {
+	b := &store.Batch{FromBlock: from, ToBlock: to}
+	transfer := func(n, token uint64, logIndex uint, owner string) {
+		b.Transfers = append(b.Transfers, model.NFTTransfer{
+			ChainID:     testChainID,
+			Contract:    "nft",
+			TokenID:     model.NewBigInt(new(big.Int).SetUint64(token)),
+			ToAddress:   owner,
+			BlockNumber: n,
+			LogIndex:    logIndex,
+			TxHash:      fmt.Sprintf("0x%s%x", branch, n),
+		})
+	}
+	for n := from; n <= to; n++ {
+		b.Blocks = append(b.Blocks, model.Block{ChainID: testChainID, Number: n, Hash: fmt.Sprintf("0x%s%x", branch, n)})
+		transfer(n, n%5+1, 0, fmt.Sprint(branch, n%3))
+		if n%4 == 0 {
+			transfer(n, (n+2)%5+1, 1, fmt.Sprint(branch, "-second"))
+		}
+		if n%7 == 0 {
+			transfer(n, (n+3)%5+1, 2, store.UnsoldOwner)
+		}
+	}
+	return b
+}
+
+func TestOwnersAt(t *testing.T) {
+	ctx := context.Background()
+	// s takes snapshots; replay has none and replays every transfer.
+	s, replay := New(), New()
+	commit := func(b *store.Batch) {
+		t.Helper()
+		for _, st := range []*Store{s, replay} {
+			if err := st.Commit(ctx, b); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	owners := func(st *Store, block uint64) string {
+		t.Helper()
+		got, err := st.OwnersAt(ctx, testChainID, "nft", block)
+		if err != nil {
+			t.Fatal(err)
+		}
+		list := make([]string, 0, len(got))
+		for _, o := range got {
+			list = append(list, fmt.Sprintf("%v:%s@%d.%d", o.TokenID, o.Owner, o.BlockNumber, o.LogIndex))
+		}
+		return fmt.Sprint(list)
+	}
+	// check compares the owners at every block up to the head, before, at
+	// and between the snapshots, with a full replay.
+	check := func(stage string, head uint64) {
+		t.Helper()
+		for n := uint64(0); n <= head+1; n++ {
+			if got, want := owners(s, n), owners(replay, n); got != want {
+				t.Errorf("%s: owners at %d\n%s\nwant\n%s", stage, n, got, want)
+			}
+		}
+	}
+	latest := func(want uint64) {
+		t.Helper()
+		snap, err := s.LatestSnapshot(ctx, testChainID, "nft")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if snap.BlockNumber != want {
+			t.Errorf("latest snapshot at %d, want %d", snap.BlockNumber, want)
+		}
+	}
+
+	if _, err := s.LatestSnapshot(ctx, testChainID, "nft"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("latest snapshot: %v, want not found", err)
+	}
+	commit(transfers("a", 1, 20))
+	for _, n := range []uint64{12, 5, 16} {
+		if err := s.SaveSnapshot(ctx, testChainID, "nft", n); err != nil {
+			t.Fatal(err)
+		}
+	}
+	latest(16)
+	if got := owners(s, 0); got != "[]" {
+		t.Errorf("owners at 0: %s, want none", got)
+	}
+	check("snapshots at 5, 12 and 16", 20)
+
+	// The snapshots above the fork are dropped with the blocks they hold.
+	for _, st := range []*Store{s, replay} {
+		if _, err := st.Rollback(ctx, testChainID, model.Block{ChainID: testChainID, Number: 12, Hash: "0xac"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	latest(12)
+	check("rolled back to 12", 12)
+	commit(transfers("b", 13, 24))
+	check("new branch", 24)
+
+	for _, st := range []*Store{s, replay} {
+		if _, err := st.Rollback(ctx, testChainID, model.Block{ChainID: testChainID, Number: 8, Hash: "0xa8"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	latest(5)
+	commit(transfers("c", 9, 18))
+	if err := s.SaveSnapshot(ctx, testChainID, "nft", 14); err != nil {
+		t.Fatal(err)
+	}
+	latest(14)
+	check("rolled back to 8", 18)
+}
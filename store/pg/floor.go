@@ -0,0 +1,134 @@
+package pg
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+
+	"blockchain.com/indexer/model"
+	"blockchain.com/indexer/store"
+)
+
+// floorSQL records the floor price of the collections of @markets after
+// every block from @from to @to with listings or sales of them.
+const floorSQL = `
+INSERT INTO floor_price (chain_id, market, nft_contract, block_number, block_time, floor, listed)
+SELECT e.chain_id, e.market, e.nft_contract, e.block_number, b.time, f.floor, f.listed
+FROM (
+	SELECT chain_id, market, nft_contract, block_number FROM market_item
+	WHERE chain_id = @chain AND market IN @markets AND block_number BETWEEN @from AND @to
+	UNION
+	SELECT chain_id, market, nft_contract, block_number FROM market_sale
+	WHERE chain_id = @chain AND market IN @markets AND block_number BETWEEN @from AND @to
+) e
+JOIN block b ON b.chain_id = e.chain_id AND b.number = e.block_number
+CROSS JOIN LATERAL (
+	SELECT MIN(i.price) AS floor, COUNT(*) AS listed
+	FROM market_item i
+	WHERE i.chain_id = e.chain_id AND i.market = e.market AND i.nft_contract = e.nft_contract
+		AND i.block_number <= e.block_number
+		AND NOT EXISTS (
+			SELECT 1 FROM market_sale s
+			WHERE s.chain_id = i.chain_id AND s.market = i.market AND s.item_id = i.item_id
+				AND s.block_number <= e.block_number)
+) f
+ON CONFLICT (chain_id, market, nft_contract, block_number)
+DO UPDATE SET block_time = excluded.block_time, floor = excluded.floor, listed = excluded.listed`
+
+// floor records the floor prices of the collections listed or sold in b,
+// once its items and sales are written.
+func floor(tx *gorm.DB, b *store.Batch) error {
+	if len(b.MarketItems) == 0 && len(b.Sales) == 0 {
+		return nil
+	}
+	var (
+		chainID  uint64
+		from, to uint64
+		markets  []string
+		seen     = make(map[string]bool)
+	)
+	add := func(chain uint64, market string, block uint64) {
+		if len(markets) == 0 || block < from {
+			from = block
+		}
+		if len(markets) == 0 || block > to {
+			to = block
+		}
+		chainID = chain
+		if !seen[market] {
+			seen[market] = true
+			markets = append(markets, market)
+		}
+	}
+	for _, it := range b.MarketItems {
+		add(it.ChainID, it.Market, it.BlockNumber)
+	}
+	for _, sale := range b.Sales {
+		add(sale.ChainID, sale.Market, sale.BlockNumber)
+	}
+	return tx.Exec(floorSQL, map[string]interface{}{
+		"chain":   chainID,
+		"markets": markets,
+		"from":    from,
+		"to":      to,
+	}).Error
+}
+
+// collectionsSQL computes the listing state of the collections of @market,
+// or of @contract only.
+const collectionsSQL = `
+SELECT i.market, i.nft_contract,
+	MIN(i.price) FILTER (WHERE NOT i.sold) AS floor,
+	COUNT(*) FILTER (WHERE NOT i.sold) AS listed,
+	(SELECT COUNT(*) FROM nft_owner o
+	 WHERE o.chain_id = i.chain_id AND o.contract = i.nft_contract AND o.owner <> @burned) AS supply
+FROM market_item i
+WHERE i.chain_id = @chain AND i.market = @market AND (@contract = '' OR i.nft_contract = @contract)
+GROUP BY i.chain_id, i.market, i.nft_contract
+ORDER BY i.nft_contract`
+
+func (s *Store) collections(ctx context.Context, chainID uint64, market, nftContract string) ([]model.CollectionStats, error) {
+	var cs []model.CollectionStats
+	err := s.db.WithContext(ctx).Raw(collectionsSQL, map[string]interface{}{
+		"chain":    chainID,
+		"market":   market,
+		"contract": nftContract,
+		"burned":   store.UnsoldOwner,
+	}).Scan(&cs).Error
+	if err != nil {
+		return nil, err
+	}
+	for i := range cs {
+		cs[i].SetSupply(cs[i].Supply)
+	}
+	return cs, nil
+}
+
+func (s *Store) Collections(ctx context.Context, chainID uint64, market string) ([]model.CollectionStats, error) {
+	return s.collections(ctx, chainID, market, "")
+}
+
+func (s *Store) Collection(ctx context.Context, chainID uint64, market, nftContract string) (*model.CollectionStats, error) {
+	cs, err := s.collections(ctx, chainID, market, nftContract)
+	if err != nil {
+		return nil, err
+	}
+	if len(cs) == 0 {
+		return nil, store.ErrNotFound
+	}
+	return &cs[0], nil
+}
+
+func (s *Store) FloorHistory(ctx context.Context, q store.FloorHistoryQuery) ([]model.FloorPrice, error) {
+	db := s.db.WithContext(ctx).
+		Where("chain_id = ? AND market = ? AND nft_contract = ?", q.ChainID, q.Market, q.NftContract)
+	if !q.From.IsZero() {
+		db = db.Where("block_time >= ?", q.From)
+	}
+	if !q.To.IsZero() {
+		db = db.Where("block_time < ?", q.To)
+	}
+	var history []model.FloorPrice
+	err := db.Order("block_number").Find(&history).Error
+	return history, err
+}
@@ -0,0 +1,51 @@
+package pg
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"blockchain.com/indexer/model"
+	"blockchain.com/indexer/store"
+)
+
+func (s *Store) QueueMetadata(ctx context.Context, ms []model.TokenMetadata) error {
+	if len(ms) == 0 {
+		return nil
+	}
+	return insert(s.db.WithContext(ctx), &ms, len(ms))
+}
+
+func (s *Store) DueMetadata(ctx context.Context, chainID uint64, now time.Time, limit int) ([]model.TokenMetadata, error) {
+	db := s.db.WithContext(ctx).
+		Where("chain_id = ? AND status = ? AND next_attempt_at <= ?", chainID, model.MetadataPending, now)
+	if limit > 0 {
+		db = db.Limit(limit)
+	}
+	var due []model.TokenMetadata
+	err := db.Order("next_attempt_at").Find(&due).Error
+	return due, err
+}
+
+func (s *Store) SaveMetadata(ctx context.Context, m *model.TokenMetadata) error {
+	m.UpdatedAt = time.Now()
+	return s.db.WithContext(ctx).Clauses(clause.OnConflict{UpdateAll: true}).Create(m).Error
+}
+
+func (s *Store) TokenMetadata(ctx context.Context, chainID uint64, contract string, tokenID *big.Int) (*model.TokenMetadata, error) {
+	var m model.TokenMetadata
+	err := s.db.WithContext(ctx).
+		Where("chain_id = ? AND contract = ? AND token_id = ?", chainID, contract, model.NewBigInt(tokenID)).
+		First(&m).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, store.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
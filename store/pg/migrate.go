@@ -0,0 +1,97 @@
+package pg
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// migrationLock is the advisory lock key held while migrating, so replicas
+// starting together do not apply the same migration twice.
+const migrationLock = 20211101
+
+type migration struct {
+	version int
+	name    string
+	sql     string
+}
+
+// Migrate applies every migration in migrations/ that has not been applied
+// yet. Migration files are named <version>_<name>.sql and run in version
+// order, each in its own transaction.
+func Migrate(db *gorm.DB) error {
+	if err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migration (
+		version    INTEGER PRIMARY KEY,
+		name       TEXT NOT NULL,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	)`).Error; err != nil {
+		return err
+	}
+
+	ms, err := loadMigrations()
+	if err != nil {
+		return err
+	}
+
+	for _, m := range ms {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", migrationLock).Error; err != nil {
+				return err
+			}
+
+			var applied int64
+			if err := tx.Table("schema_migration").Where("version = ?", m.version).Count(&applied).Error; err != nil {
+				return err
+			}
+			if applied > 0 {
+				return nil
+			}
+
+			if err := tx.Exec(m.sql).Error; err != nil {
+				return err
+			}
+			log.Printf("pg: applied migration %04d_%s", m.version, m.name)
+			return tx.Exec("INSERT INTO schema_migration (version, name) VALUES (?, ?)", m.version, m.name).Error
+		})
+		if err != nil {
+			return fmt.Errorf("migration %04d_%s: %w", m.version, m.name, err)
+		}
+	}
+	return nil
+}
+
+func loadMigrations() ([]migration, error) {
+	files, err := fs.Glob(migrations, "migrations/*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	var ms []migration
+	for _, f := range files {
+		base := strings.TrimSuffix(strings.TrimPrefix(f, "migrations/"), ".sql")
+		parts := strings.SplitN(base, "_", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid migration file name %q", f)
+		}
+		version, err := strconv.Atoi(parts[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version in %q", f)
+		}
+		sql, err := migrations.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		ms = append(ms, migration{version: version, name: parts[1], sql: string(sql)})
+	}
+	sort.Slice(ms, func(i, j int) bool { return ms[i].version < ms[j].version })
+	return ms, nil
+}
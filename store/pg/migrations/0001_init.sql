@@ -0,0 +1,97 @@
+CREATE TABLE IF NOT EXISTS "transaction" (
+	id           SERIAL PRIMARY KEY,
+	from_address TEXT NOT NULL,
+	to_address   TEXT NOT NULL,
+	created_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+	updated_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+	deleted_at   TIMESTAMPTZ
+);
+
+CREATE TABLE block (
+	chain_id    BIGINT NOT NULL,
+	number      BIGINT NOT NULL,
+	hash        TEXT NOT NULL,
+	parent_hash TEXT NOT NULL,
+	time        TIMESTAMPTZ NOT NULL,
+	PRIMARY KEY (chain_id, number)
+);
+
+CREATE TABLE checkpoint (
+	chain_id     BIGINT NOT NULL,
+	contract     TEXT NOT NULL,
+	block_number BIGINT NOT NULL,
+	block_hash   TEXT NOT NULL,
+	updated_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+	PRIMARY KEY (chain_id, contract)
+);
+
+CREATE TABLE market_item (
+	id           BIGSERIAL PRIMARY KEY,
+	chain_id     BIGINT NOT NULL,
+	market       TEXT NOT NULL,
+	item_id      NUMERIC(78) NOT NULL,
+	nft_contract TEXT NOT NULL,
+	token_id     NUMERIC(78) NOT NULL,
+	seller       TEXT NOT NULL,
+	owner        TEXT NOT NULL,
+	price        NUMERIC(78) NOT NULL,
+	sold         BOOLEAN NOT NULL,
+	block_number BIGINT NOT NULL,
+	block_hash   TEXT NOT NULL,
+	tx_hash      TEXT NOT NULL,
+	log_index    INTEGER NOT NULL,
+	created_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+	UNIQUE (chain_id, tx_hash, log_index)
+);
+CREATE INDEX market_item_block_idx ON market_item (chain_id, block_number);
+CREATE INDEX market_item_nft_idx ON market_item (chain_id, nft_contract, token_id);
+
+CREATE TABLE nft_transfer (
+	id           BIGSERIAL PRIMARY KEY,
+	chain_id     BIGINT NOT NULL,
+	contract     TEXT NOT NULL,
+	from_address TEXT NOT NULL,
+	to_address   TEXT NOT NULL,
+	token_id     NUMERIC(78) NOT NULL,
+	block_number BIGINT NOT NULL,
+	block_hash   TEXT NOT NULL,
+	tx_hash      TEXT NOT NULL,
+	log_index    INTEGER NOT NULL,
+	created_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+	UNIQUE (chain_id, tx_hash, log_index)
+);
+CREATE INDEX nft_transfer_block_idx ON nft_transfer (chain_id, block_number);
+CREATE INDEX nft_transfer_token_idx ON nft_transfer (chain_id, contract, token_id);
+
+CREATE TABLE nft_approval (
+	id           BIGSERIAL PRIMARY KEY,
+	chain_id     BIGINT NOT NULL,
+	contract     TEXT NOT NULL,
+	owner        TEXT NOT NULL,
+	approved     TEXT NOT NULL,
+	token_id     NUMERIC(78) NOT NULL,
+	block_number BIGINT NOT NULL,
+	block_hash   TEXT NOT NULL,
+	tx_hash      TEXT NOT NULL,
+	log_index    INTEGER NOT NULL,
+	created_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+	UNIQUE (chain_id, tx_hash, log_index)
+);
+CREATE INDEX nft_approval_block_idx ON nft_approval (chain_id, block_number);
+CREATE INDEX nft_approval_token_idx ON nft_approval (chain_id, contract, token_id);
+
+CREATE TABLE nft_approval_for_all (
+	id           BIGSERIAL PRIMARY KEY,
+	chain_id     BIGINT NOT NULL,
+	contract     TEXT NOT NULL,
+	owner        TEXT NOT NULL,
+	operator     TEXT NOT NULL,
+	approved     BOOLEAN NOT NULL,
+	block_number BIGINT NOT NULL,
+	block_hash   TEXT NOT NULL,
+	tx_hash      TEXT NOT NULL,
+	log_index    INTEGER NOT NULL,
+	created_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+	UNIQUE (chain_id, tx_hash, log_index)
+);
+CREATE INDEX nft_approval_for_all_block_idx ON nft_approval_for_all (chain_id, block_number);
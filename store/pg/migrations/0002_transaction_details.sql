@@ -0,0 +1,17 @@
+ALTER TABLE "transaction"
+	ADD COLUMN chain_id            BIGINT NOT NULL DEFAULT 0,
+	ADD COLUMN tx_hash             TEXT NOT NULL DEFAULT '',
+	ADD COLUMN block_number        BIGINT NOT NULL DEFAULT 0,
+	ADD COLUMN block_hash          TEXT NOT NULL DEFAULT '',
+	ADD COLUMN transaction_index   INTEGER NOT NULL DEFAULT 0,
+	ADD COLUMN nonce               BIGINT NOT NULL DEFAULT 0,
+	ADD COLUMN value               NUMERIC(78) NOT NULL DEFAULT 0,
+	ADD COLUMN gas_used            BIGINT NOT NULL DEFAULT 0,
+	ADD COLUMN effective_gas_price NUMERIC(78) NOT NULL DEFAULT 0,
+	ADD COLUMN status              TEXT NOT NULL DEFAULT 'pending',
+	ADD COLUMN method_selector     TEXT NOT NULL DEFAULT '',
+	ADD COLUMN method_name         TEXT NOT NULL DEFAULT '';
+
+CREATE UNIQUE INDEX transaction_chain_tx_hash_key ON "transaction" (chain_id, tx_hash);
+CREATE INDEX transaction_block_idx ON "transaction" (chain_id, block_number);
+CREATE INDEX transaction_from_idx ON "transaction" (chain_id, from_address);
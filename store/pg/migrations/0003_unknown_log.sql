@@ -0,0 +1,15 @@
+CREATE TABLE unknown_log (
+	id           BIGSERIAL PRIMARY KEY,
+	chain_id     BIGINT NOT NULL,
+	contract     TEXT NOT NULL,
+	topics       TEXT NOT NULL,
+	data         TEXT NOT NULL,
+	reason       TEXT NOT NULL,
+	block_number BIGINT NOT NULL,
+	block_hash   TEXT NOT NULL,
+	tx_hash      TEXT NOT NULL,
+	log_index    INTEGER NOT NULL,
+	created_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+	UNIQUE (chain_id, tx_hash, log_index)
+);
+CREATE INDEX unknown_log_block_idx ON unknown_log (chain_id, block_number);
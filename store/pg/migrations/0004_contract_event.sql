@@ -0,0 +1,17 @@
+CREATE TABLE contract_event (
+	id           BIGSERIAL PRIMARY KEY,
+	chain_id     BIGINT NOT NULL,
+	contract     TEXT NOT NULL,
+	abi          TEXT NOT NULL,
+	event        TEXT NOT NULL,
+	signature    TEXT NOT NULL,
+	args         JSONB NOT NULL,
+	block_number BIGINT NOT NULL,
+	block_hash   TEXT NOT NULL,
+	tx_hash      TEXT NOT NULL,
+	log_index    INTEGER NOT NULL,
+	created_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+	UNIQUE (chain_id, tx_hash, log_index)
+);
+CREATE INDEX contract_event_block_idx ON contract_event (chain_id, block_number);
+CREATE INDEX contract_event_event_idx ON contract_event (chain_id, contract, event);
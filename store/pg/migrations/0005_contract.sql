@@ -0,0 +1,10 @@
+CREATE TABLE contract (
+	chain_id    BIGINT NOT NULL,
+	address     TEXT NOT NULL,
+	abi         TEXT NOT NULL,
+	start_block BIGINT NOT NULL,
+	label       TEXT NOT NULL DEFAULT '',
+	created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+	updated_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+	PRIMARY KEY (chain_id, address)
+);
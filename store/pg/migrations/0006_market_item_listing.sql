@@ -0,0 +1,5 @@
+-- Indexes backing the market item listing API.
+CREATE UNIQUE INDEX market_item_item_idx ON market_item (chain_id, market, item_id);
+CREATE INDEX market_item_seller_idx ON market_item (chain_id, seller);
+CREATE INDEX market_item_owner_idx ON market_item (chain_id, owner);
+CREATE INDEX market_item_price_idx ON market_item (chain_id, price, id);
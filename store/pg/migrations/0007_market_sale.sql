@@ -0,0 +1,21 @@
+CREATE TABLE market_sale (
+	id           BIGSERIAL PRIMARY KEY,
+	chain_id     BIGINT NOT NULL,
+	market       TEXT NOT NULL,
+	item_id      NUMERIC(78) NOT NULL,
+	nft_contract TEXT NOT NULL,
+	token_id     NUMERIC(78) NOT NULL,
+	seller       TEXT NOT NULL,
+	buyer        TEXT NOT NULL,
+	price        NUMERIC(78) NOT NULL,
+	block_number BIGINT NOT NULL,
+	block_hash   TEXT NOT NULL,
+	tx_hash      TEXT NOT NULL,
+	log_index    INTEGER NOT NULL,
+	created_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+	UNIQUE (chain_id, tx_hash, log_index)
+);
+CREATE INDEX market_sale_block_idx ON market_sale (chain_id, block_number);
+CREATE INDEX market_sale_item_idx ON market_sale (chain_id, market, item_id);
+CREATE INDEX market_sale_buyer_idx ON market_sale (chain_id, buyer);
+CREATE INDEX market_sale_seller_idx ON market_sale (chain_id, seller);
@@ -0,0 +1,19 @@
+CREATE TABLE nft_owner (
+	chain_id     BIGINT NOT NULL,
+	contract     TEXT NOT NULL,
+	token_id     NUMERIC(78) NOT NULL,
+	owner        TEXT NOT NULL,
+	block_number BIGINT NOT NULL,
+	log_index    INTEGER NOT NULL,
+	tx_hash      TEXT NOT NULL,
+	updated_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+	PRIMARY KEY (chain_id, contract, token_id)
+);
+CREATE INDEX nft_owner_owner_idx ON nft_owner (chain_id, owner, block_number, log_index);
+
+-- Project the transfers indexed so far.
+INSERT INTO nft_owner (chain_id, contract, token_id, owner, block_number, log_index, tx_hash)
+SELECT DISTINCT ON (chain_id, contract, token_id)
+	chain_id, contract, token_id, to_address, block_number, log_index, tx_hash
+FROM nft_transfer
+ORDER BY chain_id, contract, token_id, block_number DESC, log_index DESC;
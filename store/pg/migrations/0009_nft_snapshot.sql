@@ -0,0 +1,27 @@
+CREATE TABLE nft_snapshot (
+	chain_id     BIGINT NOT NULL,
+	contract     TEXT NOT NULL,
+	block_number BIGINT NOT NULL,
+	tokens       INTEGER NOT NULL,
+	created_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+	PRIMARY KEY (chain_id, contract, block_number)
+);
+
+CREATE TABLE nft_snapshot_owner (
+	chain_id       BIGINT NOT NULL,
+	contract       TEXT NOT NULL,
+	snapshot_block BIGINT NOT NULL,
+	token_id       NUMERIC(78) NOT NULL,
+	owner          TEXT NOT NULL,
+	block_number   BIGINT NOT NULL,
+	log_index      INTEGER NOT NULL,
+	tx_hash        TEXT NOT NULL,
+	PRIMARY KEY (chain_id, contract, snapshot_block, token_id),
+	FOREIGN KEY (chain_id, contract, snapshot_block)
+		REFERENCES nft_snapshot (chain_id, contract, block_number) ON DELETE CASCADE
+);
+
+-- Replaying the transfers after a snapshot scans them by contract and block.
+CREATE INDEX nft_transfer_contract_block_idx ON nft_transfer (chain_id, contract, block_number);
+
+CREATE INDEX block_time_idx ON block (chain_id, time);
@@ -0,0 +1,2 @@
+-- Token histories look sales up by token.
+CREATE INDEX market_sale_token_idx ON market_sale (chain_id, nft_contract, token_id);
@@ -0,0 +1,27 @@
+CREATE TABLE market_stats_hour (
+	chain_id     BIGINT NOT NULL,
+	kind         TEXT NOT NULL,
+	market       TEXT NOT NULL,
+	nft_contract TEXT NOT NULL,
+	hour         TIMESTAMPTZ NOT NULL,
+	count        INTEGER NOT NULL,
+	volume       NUMERIC(78) NOT NULL,
+	min_price    NUMERIC(78) NOT NULL,
+	max_price    NUMERIC(78) NOT NULL,
+	PRIMARY KEY (chain_id, kind, market, nft_contract, hour)
+);
+CREATE INDEX market_stats_hour_idx ON market_stats_hour (chain_id, hour);
+
+-- Roll up the listings and sales indexed so far.
+INSERT INTO market_stats_hour (chain_id, kind, market, nft_contract, hour, count, volume, min_price, max_price)
+SELECT i.chain_id, 'listing', i.market, i.nft_contract, date_trunc('hour', b.time, 'UTC'),
+	COUNT(*), SUM(i.price), MIN(i.price), MAX(i.price)
+FROM market_item i
+JOIN block b ON b.chain_id = i.chain_id AND b.number = i.block_number
+GROUP BY 1, 2, 3, 4, 5
+UNION ALL
+SELECT s.chain_id, 'sale', s.market, s.nft_contract, date_trunc('hour', b.time, 'UTC'),
+	COUNT(*), SUM(s.price), MIN(s.price), MAX(s.price)
+FROM market_sale s
+JOIN block b ON b.chain_id = s.chain_id AND b.number = s.block_number
+GROUP BY 1, 2, 3, 4, 5;
@@ -0,0 +1,34 @@
+CREATE TABLE floor_price (
+	chain_id     BIGINT NOT NULL,
+	market       TEXT NOT NULL,
+	nft_contract TEXT NOT NULL,
+	block_number BIGINT NOT NULL,
+	block_time   TIMESTAMPTZ NOT NULL,
+	floor        NUMERIC(78),
+	listed       INTEGER NOT NULL,
+	PRIMARY KEY (chain_id, market, nft_contract, block_number)
+);
+CREATE INDEX floor_price_block_idx ON floor_price (chain_id, block_number);
+
+-- Floor prices look up the items of a collection listed up to a block.
+CREATE INDEX market_item_collection_idx ON market_item (chain_id, market, nft_contract, block_number);
+
+-- Record the floor prices of the listings and sales indexed so far.
+INSERT INTO floor_price (chain_id, market, nft_contract, block_number, block_time, floor, listed)
+SELECT e.chain_id, e.market, e.nft_contract, e.block_number, b.time, f.floor, f.listed
+FROM (
+	SELECT chain_id, market, nft_contract, block_number FROM market_item
+	UNION
+	SELECT chain_id, market, nft_contract, block_number FROM market_sale
+) e
+JOIN block b ON b.chain_id = e.chain_id AND b.number = e.block_number
+CROSS JOIN LATERAL (
+	SELECT MIN(i.price) AS floor, COUNT(*) AS listed
+	FROM market_item i
+	WHERE i.chain_id = e.chain_id AND i.market = e.market AND i.nft_contract = e.nft_contract
+		AND i.block_number <= e.block_number
+		AND NOT EXISTS (
+			SELECT 1 FROM market_sale s
+			WHERE s.chain_id = i.chain_id AND s.market = i.market AND s.item_id = i.item_id
+				AND s.block_number <= e.block_number)
+) f;
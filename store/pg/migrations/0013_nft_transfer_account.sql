@@ -0,0 +1,3 @@
+-- Account reports look transfers up by sender and recipient.
+CREATE INDEX nft_transfer_from_idx ON nft_transfer (chain_id, from_address);
+CREATE INDEX nft_transfer_to_idx ON nft_transfer (chain_id, to_address);
@@ -0,0 +1,27 @@
+CREATE TABLE token_metadata (
+	chain_id        BIGINT NOT NULL,
+	contract        TEXT NOT NULL,
+	token_id        NUMERIC(78) NOT NULL,
+	uri             TEXT NOT NULL,
+	status          TEXT NOT NULL,
+	name            TEXT NOT NULL,
+	description     TEXT NOT NULL,
+	image           TEXT NOT NULL,
+	attributes      JSONB NOT NULL,
+	raw             TEXT NOT NULL,
+	error           TEXT NOT NULL,
+	attempts        INTEGER NOT NULL,
+	next_attempt_at TIMESTAMPTZ NOT NULL,
+	resolved_at     TIMESTAMPTZ,
+	created_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+	updated_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+	PRIMARY KEY (chain_id, contract, token_id)
+);
+CREATE INDEX token_metadata_due_idx ON token_metadata (chain_id, next_attempt_at) WHERE status = 'pending';
+
+-- Queue the tokens minted so far.
+INSERT INTO token_metadata (chain_id, contract, token_id, uri, status, name, description, image,
+	attributes, raw, error, attempts, next_attempt_at)
+SELECT DISTINCT chain_id, contract, token_id, '', 'pending', '', '', '', '[]', '', '', 0, now()
+FROM nft_transfer
+WHERE from_address = '0x0000000000000000000000000000000000000000';
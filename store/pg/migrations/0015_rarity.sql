@@ -0,0 +1,32 @@
+CREATE TABLE token_trait (
+	chain_id   BIGINT NOT NULL,
+	contract   TEXT NOT NULL,
+	token_id   NUMERIC(78) NOT NULL,
+	trait_type TEXT NOT NULL,
+	value      TEXT NOT NULL,
+	PRIMARY KEY (chain_id, contract, token_id, trait_type, value)
+);
+-- Trait filters on market items look tokens up by trait.
+CREATE INDEX token_trait_value_idx ON token_trait (chain_id, contract, trait_type, value);
+
+CREATE TABLE trait_count (
+	chain_id   BIGINT NOT NULL,
+	contract   TEXT NOT NULL,
+	trait_type TEXT NOT NULL,
+	value      TEXT NOT NULL,
+	count      INTEGER NOT NULL,
+	frequency  DOUBLE PRECISION NOT NULL,
+	PRIMARY KEY (chain_id, contract, trait_type, value)
+);
+
+CREATE TABLE token_rarity (
+	chain_id    BIGINT NOT NULL,
+	contract    TEXT NOT NULL,
+	token_id    NUMERIC(78) NOT NULL,
+	score       DOUBLE PRECISION NOT NULL,
+	statistical DOUBLE PRECISION NOT NULL,
+	rank        INTEGER NOT NULL,
+	supply      INTEGER NOT NULL,
+	updated_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+	PRIMARY KEY (chain_id, contract, token_id)
+);
@@ -0,0 +1,21 @@
+CREATE TABLE mint_job (
+	id           SERIAL PRIMARY KEY,
+	chain_id     BIGINT NOT NULL,
+	market       TEXT NOT NULL,
+	nft_contract TEXT NOT NULL,
+	sender       TEXT NOT NULL,
+	token_uri    TEXT NOT NULL,
+	price        NUMERIC(78) NOT NULL,
+	step         TEXT NOT NULL,
+	status       TEXT NOT NULL,
+	error        TEXT NOT NULL,
+	token_id     NUMERIC(78),
+	item_id      NUMERIC(78),
+	listing_fee  NUMERIC(78),
+	mint_tx      TEXT NOT NULL,
+	approve_tx   TEXT NOT NULL,
+	list_tx      TEXT NOT NULL,
+	created_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+	updated_at   TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX mint_job_status_idx ON mint_job (chain_id, status, id);
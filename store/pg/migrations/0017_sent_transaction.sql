@@ -0,0 +1,9 @@
+ALTER TABLE "transaction"
+	ADD COLUMN gas_limit                BIGINT NOT NULL DEFAULT 0,
+	ADD COLUMN max_fee_per_gas          NUMERIC(78),
+	ADD COLUMN max_priority_fee_per_gas NUMERIC(78),
+	ADD COLUMN input                    TEXT NOT NULL DEFAULT '',
+	ADD COLUMN replaced_by              TEXT NOT NULL DEFAULT '';
+
+-- The nonce manager looks up the transactions of its sender by nonce.
+CREATE INDEX transaction_from_nonce_idx ON "transaction" (chain_id, from_address, nonce);
@@ -0,0 +1,50 @@
+-- Hourly price histograms and participants, from which medians and unique
+-- buyers and sellers of longer buckets are computed without the records.
+CREATE TABLE market_stats_price (
+	chain_id     BIGINT NOT NULL,
+	kind         TEXT NOT NULL,
+	market       TEXT NOT NULL,
+	nft_contract TEXT NOT NULL,
+	hour         TIMESTAMPTZ NOT NULL,
+	price        NUMERIC(78) NOT NULL,
+	count        INTEGER NOT NULL,
+	PRIMARY KEY (chain_id, kind, market, nft_contract, hour, price)
+);
+CREATE INDEX market_stats_price_idx ON market_stats_price (chain_id, hour);
+
+CREATE TABLE market_stats_account (
+	chain_id     BIGINT NOT NULL,
+	kind         TEXT NOT NULL,
+	market       TEXT NOT NULL,
+	nft_contract TEXT NOT NULL,
+	hour         TIMESTAMPTZ NOT NULL,
+	role         TEXT NOT NULL,
+	account      TEXT NOT NULL,
+	PRIMARY KEY (chain_id, kind, market, nft_contract, hour, role, account)
+);
+CREATE INDEX market_stats_account_idx ON market_stats_account (chain_id, hour);
+
+-- Roll up the listings and sales indexed so far.
+INSERT INTO market_stats_price (chain_id, kind, market, nft_contract, hour, price, count)
+SELECT i.chain_id, 'listing', i.market, i.nft_contract, date_trunc('hour', b.time, 'UTC'), i.price, COUNT(*)
+FROM market_item i
+JOIN block b ON b.chain_id = i.chain_id AND b.number = i.block_number
+GROUP BY 1, 2, 3, 4, 5, 6
+UNION ALL
+SELECT s.chain_id, 'sale', s.market, s.nft_contract, date_trunc('hour', b.time, 'UTC'), s.price, COUNT(*)
+FROM market_sale s
+JOIN block b ON b.chain_id = s.chain_id AND b.number = s.block_number
+GROUP BY 1, 2, 3, 4, 5, 6;
+
+INSERT INTO market_stats_account (chain_id, kind, market, nft_contract, hour, role, account)
+SELECT DISTINCT i.chain_id, 'listing', i.market, i.nft_contract, date_trunc('hour', b.time, 'UTC'), 'seller', i.seller
+FROM market_item i
+JOIN block b ON b.chain_id = i.chain_id AND b.number = i.block_number
+UNION
+SELECT s.chain_id, 'sale', s.market, s.nft_contract, date_trunc('hour', b.time, 'UTC'), 'seller', s.seller
+FROM market_sale s
+JOIN block b ON b.chain_id = s.chain_id AND b.number = s.block_number
+UNION
+SELECT s.chain_id, 'sale', s.market, s.nft_contract, date_trunc('hour', b.time, 'UTC'), 'buyer', s.buyer
+FROM market_sale s
+JOIN block b ON b.chain_id = s.chain_id AND b.number = s.block_number;
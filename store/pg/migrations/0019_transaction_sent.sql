@@ -0,0 +1,10 @@
+-- Transactions the server sent, as opposed to those indexed from the events
+-- they emitted. The nonce manager only follows these, so that an indexed
+-- transaction of the same sender cannot pass for one of its own.
+ALTER TABLE "transaction" ADD COLUMN sent BOOLEAN NOT NULL DEFAULT false;
+
+-- Only the server records a gas limit.
+UPDATE "transaction" SET sent = true WHERE gas_limit > 0;
+
+DROP INDEX transaction_from_nonce_idx;
+CREATE INDEX transaction_from_nonce_idx ON "transaction" (chain_id, from_address, nonce) WHERE sent;
@@ -0,0 +1,3 @@
+-- The first indexed event of a transaction. Transactions the server sent
+-- have none.
+ALTER TABLE "transaction" ADD COLUMN log_index INTEGER;
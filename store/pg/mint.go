@@ -0,0 +1,42 @@
+package pg
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+
+	"blockchain.com/indexer/model"
+	"blockchain.com/indexer/store"
+)
+
+func (s *Store) SaveMintJob(ctx context.Context, j *model.MintJob) error {
+	j.UpdatedAt = time.Now()
+	if j.ID == 0 {
+		return s.db.WithContext(ctx).Create(j).Error
+	}
+	return s.db.WithContext(ctx).Save(j).Error
+}
+
+func (s *Store) MintJob(ctx context.Context, chainID uint64, id int) (*model.MintJob, error) {
+	var j model.MintJob
+	err := s.db.WithContext(ctx).Where("chain_id = ? AND id = ?", chainID, id).First(&j).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, store.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &j, nil
+}
+
+func (s *Store) MintJobs(ctx context.Context, chainID uint64, status model.MintStatus) ([]model.MintJob, error) {
+	db := s.db.WithContext(ctx).Where("chain_id = ?", chainID)
+	if status != "" {
+		db = db.Where("status = ?", status)
+	}
+	var jobs []model.MintJob
+	err := db.Order("id").Find(&jobs).Error
+	return jobs, err
+}
@@ -0,0 +1,679 @@
+package pg
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"blockchain.com/indexer/model"
+	"blockchain.com/indexer/store"
+)
+
+// insertBatchSize is the number of rows per INSERT statement.
+const insertBatchSize = 500
+
+// Store is a store.Store backed by Postgres.
+type Store struct {
+	db *gorm.DB
+}
+
+func New(db *gorm.DB) *Store {
+	return &Store{db: db}
+}
+
+func (s *Store) Commit(ctx context.Context, b *store.Batch) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if len(b.Blocks) > 0 {
+			if err := tx.Clauses(clause.OnConflict{UpdateAll: true}).Create(&b.Blocks).Error; err != nil {
+				return err
+			}
+		}
+
+		// Records are unique per log and transactions per hash, so a batch
+		// committed twice is a no-op.
+		if err := insert(tx, &b.Transactions, len(b.Transactions)); err != nil {
+			return err
+		}
+		if err := insert(tx, &b.MarketItems, len(b.MarketItems)); err != nil {
+			return err
+		}
+		for i := range b.Sales {
+			if err := sell(tx, &b.Sales[i]); err != nil {
+				return err
+			}
+		}
+		if err := insert(tx, &b.Sales, len(b.Sales)); err != nil {
+			return err
+		}
+		if len(b.MarketItems) > 0 || len(b.Sales) > 0 {
+			if err := restatBlocks(tx, b.Blocks); err != nil {
+				return err
+			}
+		}
+		if err := floor(tx, b); err != nil {
+			return err
+		}
+		if err := insert(tx, &b.Transfers, len(b.Transfers)); err != nil {
+			return err
+		}
+		if err := own(tx, b.Transfers); err != nil {
+			return err
+		}
+		if err := insert(tx, &b.Approvals, len(b.Approvals)); err != nil {
+			return err
+		}
+		if err := insert(tx, &b.ApprovalsForAll, len(b.ApprovalsForAll)); err != nil {
+			return err
+		}
+		if err := insert(tx, &b.ContractEvents, len(b.ContractEvents)); err != nil {
+			return err
+		}
+		if err := insert(tx, &b.UnknownLogs, len(b.UnknownLogs)); err != nil {
+			return err
+		}
+
+		for i := range b.Checkpoints {
+			b.Checkpoints[i].UpdatedAt = time.Now()
+		}
+		if len(b.Checkpoints) > 0 {
+			err := tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "chain_id"}, {Name: "contract"}},
+				DoUpdates: clause.AssignmentColumns([]string{"block_number", "block_hash", "updated_at"}),
+			}).Create(&b.Checkpoints).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func insert(tx *gorm.DB, rows interface{}, n int) error {
+	if n == 0 {
+		return nil
+	}
+	return tx.Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(rows, insertBatchSize).Error
+}
+
+// newerTransfer keeps an owner row when the incoming transfer is older, so
+// batches of different contracts can commit in any order and a batch can be
+// committed twice.
+var newerTransfer = clause.Where{Exprs: []clause.Expression{clause.Expr{
+	SQL: "(nft_owner.block_number, nft_owner.log_index) < (excluded.block_number, excluded.log_index)",
+}}}
+
+// own moves the tokens of transfers to their recipients.
+func own(tx *gorm.DB, transfers []model.NFTTransfer) error {
+	// A statement cannot update a row twice, so only the last transfer of
+	// each token is written.
+	last := make(map[string]int)
+	var owners []model.NFTOwner
+	for _, t := range transfers {
+		o := model.NFTOwner{
+			ChainID:     t.ChainID,
+			Contract:    t.Contract,
+			TokenID:     t.TokenID,
+			Owner:       t.ToAddress,
+			BlockNumber: t.BlockNumber,
+			LogIndex:    t.LogIndex,
+			TxHash:      t.TxHash,
+			UpdatedAt:   time.Now(),
+		}
+		k := t.Contract + "/" + t.TokenID.String()
+		if i, ok := last[k]; ok {
+			owners[i] = o
+			continue
+		}
+		last[k] = len(owners)
+		owners = append(owners, o)
+	}
+	if len(owners) == 0 {
+		return nil
+	}
+	return tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "chain_id"}, {Name: "contract"}, {Name: "token_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"owner", "block_number", "log_index", "tx_hash", "updated_at"}),
+		Where:     newerTransfer,
+	}).CreateInBatches(&owners, insertBatchSize).Error
+}
+
+// reown recomputes the owners of the tokens moved by removed transfers from
+// the transfers that remain. Tokens with no transfer left were minted on the
+// orphaned branch: their metadata is removed.
+func reown(tx *gorm.DB, removed []model.NFTTransfer) error {
+	seen := make(map[string]bool)
+	for _, t := range removed {
+		k := t.Contract + "/" + t.TokenID.String()
+		if seen[k] {
+			continue
+		}
+		seen[k] = true
+
+		const token = "chain_id = ? AND contract = ? AND token_id = ?"
+		if err := tx.Where(token, t.ChainID, t.Contract, t.TokenID).Delete(&model.NFTOwner{}).Error; err != nil {
+			return err
+		}
+		var prev []model.NFTTransfer
+		err := tx.Where(token, t.ChainID, t.Contract, t.TokenID).Order("block_number DESC, log_index DESC").Limit(1).Find(&prev).Error
+		if err != nil {
+			return err
+		}
+		if len(prev) == 0 {
+			if err := tx.Where(token, t.ChainID, t.Contract, t.TokenID).Delete(&model.TokenMetadata{}).Error; err != nil {
+				return err
+			}
+			continue
+		}
+		if err := own(tx, prev); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sell fills in the seller of sale and marks its item sold to the buyer.
+func sell(tx *gorm.DB, sale *model.MarketSale) error {
+	var it model.MarketItem
+	err := tx.Where("chain_id = ? AND market = ? AND item_id = ?", sale.ChainID, sale.Market, sale.ItemID).First(&it).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil // listed before the market was indexed
+	}
+	if err != nil {
+		return err
+	}
+	sale.Seller = it.Seller
+	return tx.Model(&it).Updates(map[string]interface{}{"sold": true, "owner": sale.Buyer}).Error
+}
+
+func (s *Store) Checkpoint(ctx context.Context, chainID uint64, contract string) (*model.Checkpoint, error) {
+	var cp model.Checkpoint
+	err := s.db.WithContext(ctx).Where("chain_id = ? AND contract = ?", chainID, contract).First(&cp).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, store.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &cp, nil
+}
+
+func (s *Store) RecentBlocks(ctx context.Context, chainID uint64, limit int) ([]model.Block, error) {
+	var blocks []model.Block
+	err := s.db.WithContext(ctx).Where("chain_id = ?", chainID).Order("number DESC").Limit(limit).Find(&blocks).Error
+	if err != nil {
+		return nil, err
+	}
+	for i, j := 0, len(blocks)-1; i < j; i, j = i+1, j-1 {
+		blocks[i], blocks[j] = blocks[j], blocks[i]
+	}
+	return blocks, nil
+}
+
+func (s *Store) Rollback(ctx context.Context, chainID uint64, ancestor model.Block) (*store.Batch, error) {
+	removed := &store.Batch{FromBlock: ancestor.Number + 1}
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("chain_id = ? AND number > ?", chainID, ancestor.Number).Order("number").Find(&removed.Blocks).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("chain_id = ? AND number > ?", chainID, ancestor.Number).Delete(&model.Block{}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("chain_id = ? AND block_number > ?", chainID, ancestor.Number).Find(&removed.Transactions).Error; err != nil {
+			return err
+		}
+		// Transactions the server sent go back to pending, for the nonce
+		// manager to follow them onto the new branch.
+		err := tx.Model(&model.Transaction{}).
+			Where("chain_id = ? AND block_number > ? AND sent", chainID, ancestor.Number).
+			Updates(map[string]interface{}{
+				"status":              model.TransactionStatusPending,
+				"block_number":        0,
+				"block_hash":          "",
+				"transaction_index":   0,
+				"gas_used":            0,
+				"effective_gas_price": 0,
+				"updated_at":          time.Now(),
+			}).Error
+		if err != nil {
+			return err
+		}
+		if err := tx.Where("chain_id = ? AND block_number > ? AND NOT sent", chainID, ancestor.Number).Delete(&model.Transaction{}).Error; err != nil {
+			return err
+		}
+
+		if err := remove(tx, chainID, ancestor.Number, &removed.MarketItems, &model.MarketItem{}); err != nil {
+			return err
+		}
+		if err := remove(tx, chainID, ancestor.Number, &removed.Sales, &model.MarketSale{}); err != nil {
+			return err
+		}
+		for _, sale := range removed.Sales {
+			err := tx.Model(&model.MarketItem{}).
+				Where("chain_id = ? AND market = ? AND item_id = ?", sale.ChainID, sale.Market, sale.ItemID).
+				Updates(map[string]interface{}{"sold": false, "owner": store.UnsoldOwner}).Error
+			if err != nil {
+				return err
+			}
+		}
+		if err := remove(tx, chainID, ancestor.Number, &removed.Transfers, &model.NFTTransfer{}); err != nil {
+			return err
+		}
+		if err := reown(tx, removed.Transfers); err != nil {
+			return err
+		}
+		if err := remove(tx, chainID, ancestor.Number, &removed.Approvals, &model.NFTApproval{}); err != nil {
+			return err
+		}
+		if err := remove(tx, chainID, ancestor.Number, &removed.ApprovalsForAll, &model.NFTApprovalForAll{}); err != nil {
+			return err
+		}
+		if err := remove(tx, chainID, ancestor.Number, &removed.ContractEvents, &model.ContractEvent{}); err != nil {
+			return err
+		}
+		if err := remove(tx, chainID, ancestor.Number, &removed.UnknownLogs, &model.UnknownLog{}); err != nil {
+			return err
+		}
+
+		if err := tx.Where("chain_id = ? AND block_number > ?", chainID, ancestor.Number).Delete(&model.NFTSnapshot{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("chain_id = ? AND block_number > ?", chainID, ancestor.Number).Delete(&model.FloorPrice{}).Error; err != nil {
+			return err
+		}
+		if err := restatBlocks(tx, removed.Blocks); err != nil {
+			return err
+		}
+
+		return tx.Model(&model.Checkpoint{}).
+			Where("chain_id = ? AND block_number > ?", chainID, ancestor.Number).
+			Updates(map[string]interface{}{
+				"block_number": ancestor.Number,
+				"block_hash":   ancestor.Hash,
+				"updated_at":   time.Now(),
+			}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if n := len(removed.Blocks); n > 0 {
+		removed.ToBlock = removed.Blocks[n-1].Number
+	}
+	return removed, nil
+}
+
+// remove loads into rows and then deletes the records of table above block
+// number after.
+func remove(tx *gorm.DB, chainID, after uint64, rows interface{}, table interface{}) error {
+	const where = "chain_id = ? AND block_number > ?"
+	if err := tx.Where(where, chainID, after).Order("block_number, log_index").Find(rows).Error; err != nil {
+		return err
+	}
+	return tx.Where(where, chainID, after).Delete(table).Error
+}
+
+func (s *Store) Contracts(ctx context.Context, chainID uint64) ([]model.Contract, error) {
+	var contracts []model.Contract
+	err := s.db.WithContext(ctx).Where("chain_id = ?", chainID).Order("address").Find(&contracts).Error
+	return contracts, err
+}
+
+func (s *Store) SaveContract(ctx context.Context, c *model.Contract) error {
+	c.UpdatedAt = time.Now()
+	return s.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "chain_id"}, {Name: "address"}},
+		DoUpdates: clause.AssignmentColumns([]string{"abi", "start_block", "label", "updated_at"}),
+	}).Create(c).Error
+}
+
+func (s *Store) DeleteContract(ctx context.Context, chainID uint64, address string) error {
+	res := s.db.WithContext(ctx).Where("chain_id = ? AND address = ?", chainID, address).Delete(&model.Contract{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return store.ErrNotFound
+	}
+	return nil
+}
+
+func (s *Store) MarketItems(ctx context.Context, q store.MarketItemQuery) ([]model.MarketItem, error) {
+	db := s.db.WithContext(ctx).Where("chain_id = ?", q.ChainID)
+	for col, v := range map[string]string{
+		"market":       q.Market,
+		"nft_contract": q.NftContract,
+		"seller":       q.Seller,
+		"owner":        q.Owner,
+	} {
+		if v != "" {
+			db = db.Where(col+" = ?", v)
+		}
+	}
+	if q.Sold != nil {
+		db = db.Where("sold = ?", *q.Sold)
+	}
+	if q.MinPrice != nil {
+		db = db.Where("price >= ?", model.NewBigInt(q.MinPrice))
+	}
+	if q.MaxPrice != nil {
+		db = db.Where("price <= ?", model.NewBigInt(q.MaxPrice))
+	}
+	if q.FromBlock != nil {
+		db = db.Where("block_number >= ?", *q.FromBlock)
+	}
+	if q.ToBlock != nil {
+		db = db.Where("block_number <= ?", *q.ToBlock)
+	}
+	for typ, values := range q.Traits {
+		db = db.Where(`EXISTS (SELECT 1 FROM token_trait t
+			WHERE t.chain_id = market_item.chain_id AND t.contract = market_item.nft_contract
+				AND t.token_id = market_item.token_id AND t.trait_type = ? AND t.value IN ?)`, typ, values)
+	}
+
+	// The sort column comes from a fixed set, never from the request.
+	col := string(store.SortByItemID)
+	switch q.Sort {
+	case store.SortByPrice, store.SortByBlockNumber:
+		col = string(q.Sort)
+	}
+	dir, cmp := "ASC", ">"
+	if q.Desc {
+		dir, cmp = "DESC", "<"
+	}
+	if q.After != nil {
+		db = db.Where("("+col+", id) "+cmp+" (?, ?)", q.After.Key, q.After.ID)
+	}
+	db = db.Order(col + " " + dir).Order("id " + dir)
+	if q.Limit > 0 {
+		db = db.Limit(q.Limit)
+	}
+
+	var items []model.MarketItem
+	err := db.Find(&items).Error
+	return items, err
+}
+
+func (s *Store) MarketItem(ctx context.Context, chainID uint64, market string, itemID *big.Int) (*model.MarketItem, error) {
+	var it model.MarketItem
+	err := s.db.WithContext(ctx).
+		Where("chain_id = ? AND market = ? AND item_id = ?", chainID, market, model.NewBigInt(itemID)).
+		First(&it).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, store.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &it, nil
+}
+
+func (s *Store) Sale(ctx context.Context, chainID uint64, market string, itemID *big.Int) (*model.MarketSale, error) {
+	var sale model.MarketSale
+	err := s.db.WithContext(ctx).
+		Where("chain_id = ? AND market = ? AND item_id = ?", chainID, market, model.NewBigInt(itemID)).
+		First(&sale).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, store.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &sale, nil
+}
+
+func (s *Store) TokenOwner(ctx context.Context, chainID uint64, contract string, tokenID *big.Int) (*model.NFTOwner, error) {
+	var o model.NFTOwner
+	err := s.db.WithContext(ctx).
+		Where("chain_id = ? AND contract = ? AND token_id = ?", chainID, contract, model.NewBigInt(tokenID)).
+		First(&o).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, store.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &o, nil
+}
+
+func (s *Store) OwnedTokens(ctx context.Context, q store.OwnedTokenQuery) ([]model.NFTOwner, error) {
+	db := s.db.WithContext(ctx).Where("chain_id = ? AND owner = ?", q.ChainID, q.Owner)
+	if q.Contract != "" {
+		db = db.Where("contract = ?", q.Contract)
+	}
+	if q.After != nil {
+		db = db.Where("(block_number, log_index) > (?, ?)", q.After.Key.Uint64(), q.After.ID)
+	}
+	if q.Limit > 0 {
+		db = db.Limit(q.Limit)
+	}
+	var tokens []model.NFTOwner
+	err := db.Order("block_number, log_index").Find(&tokens).Error
+	return tokens, err
+}
+
+func (s *Store) NFTBalances(ctx context.Context, chainID uint64, owner string) ([]model.NFTBalance, error) {
+	var balances []model.NFTBalance
+	err := s.db.WithContext(ctx).Model(&model.NFTOwner{}).
+		Select("contract, COUNT(*) AS balance").
+		Where("chain_id = ? AND owner = ?", chainID, owner).
+		Group("contract").Order("contract").
+		Scan(&balances).Error
+	return balances, err
+}
+
+func (s *Store) TokenRecords(ctx context.Context, chainID uint64, contract string, tokenID *big.Int) (*store.Batch, error) {
+	b := &store.Batch{}
+	id := model.NewBigInt(tokenID)
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		token := "chain_id = ? AND contract = ? AND token_id = ?"
+		if err := tx.Where(token, chainID, contract, id).Order("block_number, log_index").Find(&b.Transfers).Error; err != nil {
+			return err
+		}
+		if err := tx.Where(token, chainID, contract, id).Order("block_number, log_index").Find(&b.Approvals).Error; err != nil {
+			return err
+		}
+		item := "chain_id = ? AND nft_contract = ? AND token_id = ?"
+		if err := tx.Where(item, chainID, contract, id).Order("block_number, log_index").Find(&b.MarketItems).Error; err != nil {
+			return err
+		}
+		if err := tx.Where(item, chainID, contract, id).Order("block_number, log_index").Find(&b.Sales).Error; err != nil {
+			return err
+		}
+
+		var numbers []uint64
+		for _, t := range b.Transfers {
+			numbers = append(numbers, t.BlockNumber)
+		}
+		for _, a := range b.Approvals {
+			numbers = append(numbers, a.BlockNumber)
+		}
+		for _, it := range b.MarketItems {
+			numbers = append(numbers, it.BlockNumber)
+		}
+		for _, sale := range b.Sales {
+			numbers = append(numbers, sale.BlockNumber)
+		}
+		if len(numbers) == 0 {
+			return nil
+		}
+		return tx.Where("chain_id = ? AND number IN ?", chainID, numbers).Order("number").Find(&b.Blocks).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func (s *Store) AccountRecords(ctx context.Context, chainID uint64, account string) (*store.Batch, error) {
+	b := &store.Batch{}
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("chain_id = ? AND (from_address = ? OR to_address = ?)", chainID, account, account).
+			Order("block_number, log_index").Find(&b.Transfers).Error
+		if err != nil {
+			return err
+		}
+		err = tx.Where("chain_id = ? AND seller = ?", chainID, account).
+			Order("block_number, log_index").Find(&b.MarketItems).Error
+		if err != nil {
+			return err
+		}
+		err = tx.Where("chain_id = ? AND (buyer = ? OR seller = ?)", chainID, account, account).
+			Order("block_number, log_index").Find(&b.Sales).Error
+		if err != nil {
+			return err
+		}
+		if len(b.MarketItems) == 0 {
+			return nil
+		}
+		hashes := make([]string, len(b.MarketItems))
+		for i, it := range b.MarketItems {
+			hashes[i] = it.TxHash
+		}
+		return tx.Where("chain_id = ? AND tx_hash IN ?", chainID, hashes).Find(&b.Transactions).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// ownersAt selects the holders of @contract after @block from the snapshot
+// at @base and the transfers after it. @base is -1 without a snapshot.
+const ownersAt = `
+SELECT chain_id, contract, token_id, owner, block_number, log_index, tx_hash FROM (
+	SELECT DISTINCT ON (token_id) * FROM (
+		SELECT chain_id, contract, token_id, owner, block_number, log_index, tx_hash
+		FROM nft_snapshot_owner
+		WHERE chain_id = @chain AND contract = @contract AND snapshot_block = @base
+		UNION ALL
+		SELECT chain_id, contract, token_id, to_address, block_number, log_index, tx_hash
+		FROM nft_transfer
+		WHERE chain_id = @chain AND contract = @contract AND block_number > @base AND block_number <= @block
+	) h
+	ORDER BY token_id, block_number DESC, log_index DESC
+) o
+WHERE owner <> @burned
+ORDER BY token_id`
+
+// ownersAtArgs returns the arguments of ownersAt.
+func ownersAtArgs(tx *gorm.DB, chainID uint64, contract string, block uint64) (map[string]interface{}, error) {
+	var base []int64
+	err := tx.Model(&model.NFTSnapshot{}).
+		Where("chain_id = ? AND contract = ? AND block_number <= ?", chainID, contract, block).
+		Order("block_number DESC").Limit(1).Pluck("block_number", &base).Error
+	if err != nil {
+		return nil, err
+	}
+	args := map[string]interface{}{
+		"chain":    chainID,
+		"contract": contract,
+		"block":    block,
+		"base":     int64(-1),
+		"burned":   store.UnsoldOwner,
+	}
+	if len(base) > 0 {
+		args["base"] = base[0]
+	}
+	return args, nil
+}
+
+func (s *Store) OwnersAt(ctx context.Context, chainID uint64, contract string, block uint64) ([]model.NFTOwner, error) {
+	var owners []model.NFTOwner
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		args, err := ownersAtArgs(tx, chainID, contract, block)
+		if err != nil {
+			return err
+		}
+		return tx.Raw(ownersAt, args).Scan(&owners).Error
+	})
+	return owners, err
+}
+
+func (s *Store) SaveSnapshot(ctx context.Context, chainID uint64, contract string, block uint64) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		args, err := ownersAtArgs(tx, chainID, contract, block)
+		if err != nil {
+			return err
+		}
+		if args["base"] == int64(block) {
+			return nil
+		}
+
+		snap := &model.NFTSnapshot{ChainID: chainID, Contract: contract, BlockNumber: block}
+		if err := tx.Create(snap).Error; err != nil {
+			return err
+		}
+		res := tx.Exec(`
+INSERT INTO nft_snapshot_owner (chain_id, contract, snapshot_block, token_id, owner, block_number, log_index, tx_hash)
+SELECT chain_id, contract, @block, token_id, owner, block_number, log_index, tx_hash FROM (`+ownersAt+`) a`, args)
+		if res.Error != nil {
+			return res.Error
+		}
+		return tx.Model(snap).Update("tokens", res.RowsAffected).Error
+	})
+}
+
+func (s *Store) LatestSnapshot(ctx context.Context, chainID uint64, contract string) (*model.NFTSnapshot, error) {
+	var snap model.NFTSnapshot
+	err := s.db.WithContext(ctx).
+		Where("chain_id = ? AND contract = ?", chainID, contract).
+		Order("block_number DESC").First(&snap).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, store.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &snap, nil
+}
+
+func (s *Store) Transaction(ctx context.Context, chainID uint64, hash string) (*model.Transaction, error) {
+	var tx model.Transaction
+	err := s.db.WithContext(ctx).Where("chain_id = ? AND tx_hash = ?", chainID, hash).First(&tx).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, store.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &tx, nil
+}
+
+func (s *Store) Block(ctx context.Context, chainID uint64, number uint64) (*model.Block, error) {
+	var blk model.Block
+	err := s.db.WithContext(ctx).Where("chain_id = ? AND number = ?", chainID, number).First(&blk).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, store.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &blk, nil
+}
+
+func (s *Store) BlockAt(ctx context.Context, chainID uint64, t time.Time) (*model.Block, error) {
+	var blk model.Block
+	err := s.db.WithContext(ctx).Where("chain_id = ? AND time <= ?", chainID, t).Order("number DESC").First(&blk).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, store.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &blk, nil
+}
+
+func (s *Store) TotalVolume(ctx context.Context, chainID uint64) (*big.Int, error) {
+	var total model.BigInt
+	err := s.db.WithContext(ctx).Raw("SELECT COALESCE(SUM(price), 0) FROM market_item WHERE chain_id = ?", chainID).Row().Scan(&total)
+	if err != nil {
+		return nil, err
+	}
+	return total.Big(), nil
+}
@@ -0,0 +1,85 @@
+package pg
+
+import (
+	"context"
+	"errors"
+	"math/big"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"blockchain.com/indexer/model"
+	"blockchain.com/indexer/store"
+)
+
+func (s *Store) CollectionMetadata(ctx context.Context, chainID uint64, contract string) ([]model.TokenMetadata, error) {
+	var ms []model.TokenMetadata
+	err := s.db.WithContext(ctx).
+		Where("chain_id = ? AND contract = ? AND status = ?", chainID, contract, model.MetadataResolved).
+		Order("token_id").
+		Find(&ms).Error
+	return ms, err
+}
+
+func (s *Store) SaveRarity(ctx context.Context, chainID uint64, contract string, traits []model.TokenTrait, counts []model.TraitCount, tokens []model.TokenRarity, removed []model.BigInt) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if len(removed) > 0 {
+			const where = "chain_id = ? AND contract = ? AND token_id IN ?"
+			if err := tx.Where(where, chainID, contract, removed).Delete(&model.TokenTrait{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Where(where, chainID, contract, removed).Delete(&model.TokenRarity{}).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Where("chain_id = ? AND contract = ?", chainID, contract).Delete(&model.TraitCount{}).Error; err != nil {
+			return err
+		}
+		if err := insert(tx, &counts, len(counts)); err != nil {
+			return err
+		}
+		// The traits of a token never change once its metadata resolved.
+		if err := insert(tx, &traits, len(traits)); err != nil {
+			return err
+		}
+		if len(tokens) == 0 {
+			return nil
+		}
+		return tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "chain_id"}, {Name: "contract"}, {Name: "token_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"score", "statistical", "rank", "supply", "updated_at"}),
+		}).CreateInBatches(&tokens, insertBatchSize).Error
+	})
+}
+
+func (s *Store) CollectionRarity(ctx context.Context, chainID uint64, contract string) ([]model.TokenRarity, error) {
+	var rs []model.TokenRarity
+	err := s.db.WithContext(ctx).
+		Where("chain_id = ? AND contract = ?", chainID, contract).
+		Order("token_id").
+		Find(&rs).Error
+	return rs, err
+}
+
+func (s *Store) TraitCounts(ctx context.Context, chainID uint64, contract string) ([]model.TraitCount, error) {
+	var counts []model.TraitCount
+	err := s.db.WithContext(ctx).
+		Where("chain_id = ? AND contract = ?", chainID, contract).
+		Order("trait_type").Order("value").
+		Find(&counts).Error
+	return counts, err
+}
+
+func (s *Store) TokenRarity(ctx context.Context, chainID uint64, contract string, tokenID *big.Int) (*model.TokenRarity, error) {
+	var r model.TokenRarity
+	err := s.db.WithContext(ctx).
+		Where("chain_id = ? AND contract = ? AND token_id = ?", chainID, contract, model.NewBigInt(tokenID)).
+		First(&r).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, store.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
@@ -0,0 +1,46 @@
+package pg
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm/clause"
+
+	"blockchain.com/indexer/model"
+	"blockchain.com/indexer/store"
+)
+
+// sentColumns are the columns the server sets on the transactions it sends.
+var sentColumns = []string{
+	"block_number", "block_hash", "transaction_index", "from_address", "to_address", "nonce", "value",
+	"gas_used", "effective_gas_price", "status", "method_selector", "method_name",
+	"gas_limit", "max_fee_per_gas", "max_priority_fee_per_gas", "input", "replaced_by", "sent", "updated_at",
+}
+
+func (s *Store) SaveTransaction(ctx context.Context, tx *model.Transaction) error {
+	tx.Sent = true
+	tx.UpdatedAt = time.Now()
+	if tx.CreatedAt.IsZero() {
+		tx.CreatedAt = tx.UpdatedAt
+	}
+	return s.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "chain_id"}, {Name: "tx_hash"}},
+		DoUpdates: clause.AssignmentColumns(sentColumns),
+	}).Create(tx).Error
+}
+
+func (s *Store) SentTransactions(ctx context.Context, q store.SentTransactionQuery) ([]model.Transaction, error) {
+	db := s.db.WithContext(ctx).Where("chain_id = ? AND sent", q.ChainID)
+	if q.From != "" {
+		db = db.Where("from_address = ?", q.From)
+	}
+	if q.Nonce != nil {
+		db = db.Where("nonce = ?", *q.Nonce)
+	}
+	if q.Status != "" {
+		db = db.Where("status = ?", q.Status)
+	}
+	var txs []model.Transaction
+	err := db.Order("nonce").Order("id").Find(&txs).Error
+	return txs, err
+}
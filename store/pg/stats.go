@@ -0,0 +1,221 @@
+package pg
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+
+	"blockchain.com/indexer/model"
+	"blockchain.com/indexer/store"
+)
+
+// restatSQL rebuilds the hourly rollups of the listings and sales mined in
+// [@from, @to), which are whole hours: totals, price histograms and
+// participants.
+var restatSQL = []string{`
+INSERT INTO market_stats_hour (chain_id, kind, market, nft_contract, hour, count, volume, min_price, max_price)
+SELECT i.chain_id, 'listing', i.market, i.nft_contract, date_trunc('hour', b.time, 'UTC'),
+	COUNT(*), SUM(i.price), MIN(i.price), MAX(i.price)
+FROM market_item i
+JOIN block b ON b.chain_id = i.chain_id AND b.number = i.block_number
+WHERE i.chain_id = @chain AND b.time >= @from AND b.time < @to
+GROUP BY 1, 2, 3, 4, 5
+UNION ALL
+SELECT s.chain_id, 'sale', s.market, s.nft_contract, date_trunc('hour', b.time, 'UTC'),
+	COUNT(*), SUM(s.price), MIN(s.price), MAX(s.price)
+FROM market_sale s
+JOIN block b ON b.chain_id = s.chain_id AND b.number = s.block_number
+WHERE s.chain_id = @chain AND b.time >= @from AND b.time < @to
+GROUP BY 1, 2, 3, 4, 5`, `
+INSERT INTO market_stats_price (chain_id, kind, market, nft_contract, hour, price, count)
+SELECT i.chain_id, 'listing', i.market, i.nft_contract, date_trunc('hour', b.time, 'UTC'), i.price, COUNT(*)
+FROM market_item i
+JOIN block b ON b.chain_id = i.chain_id AND b.number = i.block_number
+WHERE i.chain_id = @chain AND b.time >= @from AND b.time < @to
+GROUP BY 1, 2, 3, 4, 5, 6
+UNION ALL
+SELECT s.chain_id, 'sale', s.market, s.nft_contract, date_trunc('hour', b.time, 'UTC'), s.price, COUNT(*)
+FROM market_sale s
+JOIN block b ON b.chain_id = s.chain_id AND b.number = s.block_number
+WHERE s.chain_id = @chain AND b.time >= @from AND b.time < @to
+GROUP BY 1, 2, 3, 4, 5, 6`, `
+INSERT INTO market_stats_account (chain_id, kind, market, nft_contract, hour, role, account)
+SELECT i.chain_id, 'listing', i.market, i.nft_contract, date_trunc('hour', b.time, 'UTC'), 'seller', i.seller
+FROM market_item i
+JOIN block b ON b.chain_id = i.chain_id AND b.number = i.block_number
+WHERE i.chain_id = @chain AND b.time >= @from AND b.time < @to
+UNION
+SELECT s.chain_id, 'sale', s.market, s.nft_contract, date_trunc('hour', b.time, 'UTC'), 'seller', s.seller
+FROM market_sale s
+JOIN block b ON b.chain_id = s.chain_id AND b.number = s.block_number
+WHERE s.chain_id = @chain AND b.time >= @from AND b.time < @to
+UNION
+SELECT s.chain_id, 'sale', s.market, s.nft_contract, date_trunc('hour', b.time, 'UTC'), 'buyer', s.buyer
+FROM market_sale s
+JOIN block b ON b.chain_id = s.chain_id AND b.number = s.block_number
+WHERE s.chain_id = @chain AND b.time >= @from AND b.time < @to`,
+}
+
+// statsTables are the tables of the hourly rollups.
+var statsTables = []string{"market_stats_hour", "market_stats_price", "market_stats_account"}
+
+// statsLock is the advisory lock key held while rebuilding rollups, so
+// batches of different groups touching the same hour do not rebuild it
+// concurrently.
+const statsLock = 20211102
+
+// restat rebuilds the rollups of the hours from from up to and including the
+// hour of to, from the records mined in them. Only the hours touched by a
+// batch or a rollback are rebuilt; rebuilding rather than adding keeps a
+// batch committed twice from counting twice.
+func restat(tx *gorm.DB, chainID uint64, from, to time.Time) error {
+	if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", statsLock).Error; err != nil {
+		return err
+	}
+	from, to = from.UTC().Truncate(time.Hour), to.UTC().Truncate(time.Hour).Add(time.Hour)
+	for _, table := range statsTables {
+		err := tx.Exec("DELETE FROM "+table+" WHERE chain_id = ? AND hour >= ? AND hour < ?", chainID, from, to).Error
+		if err != nil {
+			return err
+		}
+	}
+	args := map[string]interface{}{"chain": chainID, "from": from, "to": to}
+	for _, q := range restatSQL {
+		if err := tx.Exec(q, args).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// restatBlocks rebuilds the rollups of the hours of blocks, which hold the
+// listings and sales of a batch or a rollback.
+func restatBlocks(tx *gorm.DB, blocks []model.Block) error {
+	if len(blocks) == 0 {
+		return nil
+	}
+	from, to := blocks[0].Time, blocks[0].Time
+	for _, blk := range blocks[1:] {
+		if blk.Time.Before(from) {
+			from = blk.Time
+		}
+		if blk.Time.After(to) {
+			to = blk.Time
+		}
+	}
+	return restat(tx, blocks[0].ChainID, from, to)
+}
+
+// statsSQL aggregates the hourly rollups into buckets.
+const statsSQL = `
+SELECT date_trunc(@interval, hour, 'UTC') AS start, kind,
+	SUM(count) AS count, SUM(volume) AS volume, MIN(min_price) AS min, MAX(max_price) AS max,
+	div(SUM(volume), SUM(count)) AS avg
+FROM market_stats_hour
+WHERE chain_id = @chain AND hour >= @from AND hour < @to
+	AND (@market = '' OR market = @market) AND (@contract = '' OR nft_contract = @contract)
+GROUP BY 1, 2`
+
+// medianSQL merges the hourly price histograms of every bucket and takes
+// the first price at or past half of the count, as percentile_disc(0.5)
+// does on the records.
+const medianSQL = `
+WITH prices AS (
+	SELECT date_trunc(@interval, hour, 'UTC') AS start, kind, price, SUM(count) AS count
+	FROM market_stats_price
+	WHERE chain_id = @chain AND hour >= @from AND hour < @to
+		AND (@market = '' OR market = @market) AND (@contract = '' OR nft_contract = @contract)
+	GROUP BY 1, 2, 3
+), cumulative AS (
+	SELECT start, kind, price,
+		SUM(count) OVER (PARTITION BY start, kind ORDER BY price) AS below,
+		SUM(count) OVER (PARTITION BY start, kind) AS total
+	FROM prices
+)
+SELECT start, kind, MIN(price) AS median
+FROM cumulative
+WHERE 2 * below >= total
+GROUP BY 1, 2`
+
+// participantsSQL counts the distinct buyers and sellers of every bucket
+// from the hourly participants.
+const participantsSQL = `
+SELECT date_trunc(@interval, hour, 'UTC') AS start, kind,
+	COUNT(DISTINCT account) FILTER (WHERE role = 'buyer') AS buyers,
+	COUNT(DISTINCT account) FILTER (WHERE role = 'seller') AS sellers
+FROM market_stats_account
+WHERE chain_id = @chain AND hour >= @from AND hour < @to
+	AND (@market = '' OR market = @market) AND (@contract = '' OR nft_contract = @contract)
+GROUP BY 1, 2`
+
+func (s *Store) MarketStats(ctx context.Context, q store.MarketStatsQuery) ([]model.MarketStats, error) {
+	args := map[string]interface{}{
+		"interval": string(q.Interval),
+		"chain":    q.ChainID,
+		"from":     q.From,
+		"to":       q.To,
+		"market":   q.Market,
+		"contract": q.NftContract,
+	}
+	var totals []struct {
+		Start time.Time
+		Kind  string
+		model.PriceStats
+	}
+	var medians []struct {
+		Start  time.Time
+		Kind   string
+		Median model.BigInt
+	}
+	var participants []struct {
+		Start   time.Time
+		Kind    string
+		Buyers  int
+		Sellers int
+	}
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Raw(statsSQL, args).Scan(&totals).Error; err != nil {
+			return err
+		}
+		if err := tx.Raw(medianSQL, args).Scan(&medians).Error; err != nil {
+			return err
+		}
+		return tx.Raw(participantsSQL, args).Scan(&participants).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	buckets := make(map[time.Time]*model.MarketStats)
+	bucket := func(start time.Time, kind string) *model.PriceStats {
+		start = start.UTC()
+		st := buckets[start]
+		if st == nil {
+			st = &model.MarketStats{Start: start}
+			buckets[start] = st
+		}
+		if kind == model.MarketActivitySale {
+			return &st.Sales
+		}
+		return &st.Listings
+	}
+	for _, t := range totals {
+		*bucket(t.Start, t.Kind) = t.PriceStats
+	}
+	for _, m := range medians {
+		bucket(m.Start, m.Kind).Median = m.Median
+	}
+	for _, pa := range participants {
+		p := bucket(pa.Start, pa.Kind)
+		p.Buyers, p.Sellers = pa.Buyers, pa.Sellers
+	}
+
+	stats := make([]model.MarketStats, 0, len(buckets))
+	for _, st := range buckets {
+		stats = append(stats, *st)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Start.Before(stats[j].Start) })
+	return stats, nil
+}
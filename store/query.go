@@ -0,0 +1,148 @@
+package store
+
+import (
+	"math/big"
+	"time"
+
+	"blockchain.com/indexer/model"
+)
+
+// MarketItemSort is the order of MarketItems results. Ties are broken by
+// record id.
+type MarketItemSort string
+
+const (
+	SortByItemID      MarketItemSort = "item_id"
+	SortByPrice       MarketItemSort = "price"
+	SortByBlockNumber MarketItemSort = "block_number"
+)
+
+// MarketItemQuery selects market items. Zero fields do not filter.
+type MarketItemQuery struct {
+	ChainID     uint64
+	Market      string
+	NftContract string
+	Seller      string
+	Owner       string
+	Sold        *bool
+
+	// MinPrice and MaxPrice bound the price in wei, inclusive.
+	MinPrice *big.Int
+	MaxPrice *big.Int
+
+	// FromBlock and ToBlock bound the creation block, inclusive.
+	FromBlock *uint64
+	ToBlock   *uint64
+
+	// Traits selects the items whose token has, for every trait type, one
+	// of the listed values.
+	Traits map[string][]string
+
+	Sort MarketItemSort
+	Desc bool
+
+	// After resumes the listing after the last item of a previous page.
+	After *Cursor
+
+	Limit int
+}
+
+// Cursor is the position of a record in a sorted listing: the value of the
+// sort key and the record id.
+type Cursor struct {
+	Key model.BigInt
+	ID  int
+}
+
+// SortKey returns the value of it that q sorts on.
+func (q *MarketItemQuery) SortKey(it *model.MarketItem) model.BigInt {
+	switch q.Sort {
+	case SortByPrice:
+		return it.Price
+	case SortByBlockNumber:
+		return model.NewBigInt(new(big.Int).SetUint64(it.BlockNumber))
+	default:
+		return it.ItemID
+	}
+}
+
+// OwnedTokenQuery selects the tokens of an account, in the order they were
+// acquired. The cursor key is the block number and the cursor id the log
+// index of the acquiring Transfer.
+type OwnedTokenQuery struct {
+	ChainID  uint64
+	Owner    string
+	Contract string
+
+	After *Cursor
+	Limit int
+}
+
+// StatsInterval is the bucket size of market statistics.
+type StatsInterval string
+
+const (
+	IntervalHour StatsInterval = "hour"
+	IntervalDay  StatsInterval = "day"
+	IntervalWeek StatsInterval = "week"
+)
+
+// Truncate returns the start of the bucket of t in UTC. Weeks start on
+// Monday, as Postgres date_trunc has them.
+func (i StatsInterval) Truncate(t time.Time) time.Time {
+	t = t.UTC()
+	switch i {
+	case IntervalHour:
+		return t.Truncate(time.Hour)
+	case IntervalWeek:
+		day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+		return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+	default:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	}
+}
+
+// Next returns the start of the bucket after the one starting at t.
+func (i StatsInterval) Next(t time.Time) time.Time {
+	switch i {
+	case IntervalHour:
+		return t.Add(time.Hour)
+	case IntervalWeek:
+		return t.AddDate(0, 0, 7)
+	default:
+		return t.AddDate(0, 0, 1)
+	}
+}
+
+// MarketStatsQuery selects the listings and sales of a market, or of one NFT
+// contract on it, mined in [From, To). From and To are bucket starts.
+type MarketStatsQuery struct {
+	ChainID     uint64
+	Market      string
+	NftContract string
+
+	Interval StatsInterval
+	From     time.Time
+	To       time.Time
+}
+
+// FloorHistoryQuery selects the floor prices of an NFT contract on a market
+// set by blocks mined in [From, To). Zero bounds do not filter.
+type FloorHistoryQuery struct {
+	ChainID     uint64
+	Market      string
+	NftContract string
+
+	From time.Time
+	To   time.Time
+}
+
+// SentTransactionQuery selects the transactions the server sent from an
+// account, ordered by nonce then by sending order; transactions only indexed
+// are left out. Zero fields do not filter.
+type SentTransactionQuery struct {
+	ChainID uint64
+	From    string
+	Nonce   *uint64
+	Status  model.TransactionStatus
+}
@@ -0,0 +1,210 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"blockchain.com/indexer/model"
+)
+
+var ErrNotFound = errors.New("not found")
+
+// UnsoldOwner is the owner of a market item until it is sold: NFTMarket lists
+// items with the zero address as owner.
+var UnsoldOwner = common.Address{}.Hex()
+
+// Batch holds the records decoded from a contiguous block range.
+type Batch struct {
+	FromBlock uint64
+	ToBlock   uint64
+
+	Blocks       []model.Block
+	Transactions []model.Transaction
+
+	MarketItems []model.MarketItem
+	Approvals   []model.NFTApproval
+
+	// Transfers also move their tokens in the owner projection on commit.
+	Transfers []model.NFTTransfer
+
+	ApprovalsForAll []model.NFTApprovalForAll
+
+	// Sales are inferred market sales. Commit fills in the seller from the
+	// sold item and marks the item sold to the buyer.
+	Sales []model.MarketSale
+
+	// ContractEvents are logs decoded with a registered ABI that have no
+	// dedicated record type.
+	ContractEvents []model.ContractEvent
+
+	// UnknownLogs are logs of the indexed contracts that did not decode.
+	UnknownLogs []model.UnknownLog
+
+	// Checkpoints advance the sync position of the contracts covered by the
+	// batch. They are written in the same transaction as the records.
+	Checkpoints []model.Checkpoint
+}
+
+// Store persists indexed contract events and serves the read side of the API.
+type Store interface {
+	// Commit atomically writes every record and checkpoint of the batch, and
+	// the floor price of every collection listed or sold in it.
+	Commit(ctx context.Context, b *Batch) error
+
+	// Checkpoint returns the sync position of a contract, or ErrNotFound if
+	// it has never been indexed.
+	Checkpoint(ctx context.Context, chainID uint64, contract string) (*model.Checkpoint, error)
+
+	// RecentBlocks returns up to limit of the highest indexed blocks of a
+	// chain in ascending order.
+	RecentBlocks(ctx context.Context, chainID uint64, limit int) ([]model.Block, error)
+
+	// Rollback atomically removes every record, block, snapshot and floor
+	// price above ancestor, and the metadata of tokens minted above it, and
+	// moves checkpoints that are past it back to ancestor. Transactions the
+	// server sent are kept, back to pending. It returns the removed records.
+	Rollback(ctx context.Context, chainID uint64, ancestor model.Block) (*Batch, error)
+
+	// Contracts returns the watch list of a chain.
+	Contracts(ctx context.Context, chainID uint64) ([]model.Contract, error)
+
+	// SaveContract adds a contract to the watch list or updates it.
+	SaveContract(ctx context.Context, c *model.Contract) error
+
+	// DeleteContract removes a contract from the watch list, or returns
+	// ErrNotFound if it is not on it.
+	DeleteContract(ctx context.Context, chainID uint64, address string) error
+
+	// MarketItems returns the market items selected by q.
+	MarketItems(ctx context.Context, q MarketItemQuery) ([]model.MarketItem, error)
+
+	// MarketItem returns an item of a market, or ErrNotFound.
+	MarketItem(ctx context.Context, chainID uint64, market string, itemID *big.Int) (*model.MarketItem, error)
+
+	// Sale returns the sale of a market item, or ErrNotFound if it is unsold.
+	Sale(ctx context.Context, chainID uint64, market string, itemID *big.Int) (*model.MarketSale, error)
+
+	// TokenOwner returns the current owner of a token, or ErrNotFound if it
+	// was never transferred.
+	TokenOwner(ctx context.Context, chainID uint64, contract string, tokenID *big.Int) (*model.NFTOwner, error)
+
+	// OwnedTokens returns the tokens selected by q.
+	OwnedTokens(ctx context.Context, q OwnedTokenQuery) ([]model.NFTOwner, error)
+
+	// NFTBalances returns the number of tokens of every contract an account
+	// owns, by contract.
+	NFTBalances(ctx context.Context, chainID uint64, owner string) ([]model.NFTBalance, error)
+
+	// TokenRecords returns the Transfers, Approvals, market items and sales
+	// of a token, in chain order, with the Blocks they were mined in.
+	TokenRecords(ctx context.Context, chainID uint64, contract string, tokenID *big.Int) (*Batch, error)
+
+	// AccountRecords returns the Transfers from and to an account, the
+	// market items it listed, the sales it bought or sold and the
+	// Transactions that created its market items, in chain order.
+	AccountRecords(ctx context.Context, chainID uint64, account string) (*Batch, error)
+
+	// OwnersAt returns the tokens of a contract that had an owner after
+	// block, ordered by token id. It starts from the latest snapshot at or
+	// below block and replays the transfers after it.
+	OwnersAt(ctx context.Context, chainID uint64, contract string, block uint64) ([]model.NFTOwner, error)
+
+	// SaveSnapshot materializes the holders of a contract at block. Saving
+	// an existing snapshot is a no-op.
+	SaveSnapshot(ctx context.Context, chainID uint64, contract string, block uint64) error
+
+	// LatestSnapshot returns the latest snapshot of a contract, or
+	// ErrNotFound.
+	LatestSnapshot(ctx context.Context, chainID uint64, contract string) (*model.NFTSnapshot, error)
+
+	// Transaction returns an indexed transaction, or ErrNotFound.
+	Transaction(ctx context.Context, chainID uint64, hash string) (*model.Transaction, error)
+
+	// SaveTransaction records a transaction sent by the server, marking it
+	// Sent, or updates it. A transaction already indexed is updated in
+	// place.
+	SaveTransaction(ctx context.Context, tx *model.Transaction) error
+
+	// SentTransactions returns the transactions selected by q.
+	SentTransactions(ctx context.Context, q SentTransactionQuery) ([]model.Transaction, error)
+
+	// Block returns an indexed block, or ErrNotFound.
+	Block(ctx context.Context, chainID uint64, number uint64) (*model.Block, error)
+
+	// BlockAt returns the last indexed block mined at or before t, or
+	// ErrNotFound. Blocks are indexed for every block with records, so no
+	// record lies between it and the block that was the head at t.
+	BlockAt(ctx context.Context, chainID uint64, t time.Time) (*model.Block, error)
+
+	// Collections returns the listing state of every NFT contract ever
+	// listed on a market, ordered by contract.
+	Collections(ctx context.Context, chainID uint64, market string) ([]model.CollectionStats, error)
+
+	// Collection returns the listing state of an NFT contract on a market, or
+	// ErrNotFound if it was never listed there.
+	Collection(ctx context.Context, chainID uint64, market, nftContract string) (*model.CollectionStats, error)
+
+	// FloorHistory returns the floor prices selected by q in block order.
+	FloorHistory(ctx context.Context, q FloorHistoryQuery) ([]model.FloorPrice, error)
+
+	// QueueMetadata adds pending metadata for the tokens of ms that have
+	// none yet.
+	QueueMetadata(ctx context.Context, ms []model.TokenMetadata) error
+
+	// DueMetadata returns up to limit pending metadata due at now, the
+	// longest due first.
+	DueMetadata(ctx context.Context, chainID uint64, now time.Time, limit int) ([]model.TokenMetadata, error)
+
+	// SaveMetadata writes the metadata of a token.
+	SaveMetadata(ctx context.Context, m *model.TokenMetadata) error
+
+	// TokenMetadata returns the metadata of a token, or ErrNotFound if it
+	// was never queued.
+	TokenMetadata(ctx context.Context, chainID uint64, contract string, tokenID *big.Int) (*model.TokenMetadata, error)
+
+	// CollectionMetadata returns the resolved metadata of every token of a
+	// contract, ordered by token id.
+	CollectionMetadata(ctx context.Context, chainID uint64, contract string) ([]model.TokenMetadata, error)
+
+	// SaveRarity replaces the trait counts of a contract, writes the
+	// rarities of tokens, replacing those already saved, and the traits of
+	// tokens not scored before, and deletes the traits and rarities of the
+	// removed tokens. Other tokens keep their rarity.
+	SaveRarity(ctx context.Context, chainID uint64, contract string, traits []model.TokenTrait, counts []model.TraitCount, tokens []model.TokenRarity, removed []model.BigInt) error
+
+	// CollectionRarity returns the rarity of every scored token of a
+	// contract, ordered by token id.
+	CollectionRarity(ctx context.Context, chainID uint64, contract string) ([]model.TokenRarity, error)
+
+	// TraitCounts returns the trait counts of a contract, ordered by trait
+	// type and value.
+	TraitCounts(ctx context.Context, chainID uint64, contract string) ([]model.TraitCount, error)
+
+	// TokenRarity returns the rarity of a token, or ErrNotFound if it was
+	// never scored.
+	TokenRarity(ctx context.Context, chainID uint64, contract string, tokenID *big.Int) (*model.TokenRarity, error)
+
+	// SaveMintJob creates a mint job, assigning its id, or updates it.
+	SaveMintJob(ctx context.Context, j *model.MintJob) error
+
+	// MintJob returns a mint job, or ErrNotFound.
+	MintJob(ctx context.Context, chainID uint64, id int) (*model.MintJob, error)
+
+	// MintJobs returns the mint jobs of a chain with the given status, or
+	// all of them if status is empty, in creation order.
+	MintJobs(ctx context.Context, chainID uint64, status model.MintStatus) ([]model.MintJob, error)
+
+	// MarketStats returns the statistics of every bucket selected by q with
+	// listings or sales, in time order. They are merged from hourly rollups
+	// that Commit and Rollback keep up to date; the rollups hold price
+	// histograms and participants, so medians and unique counts are exact.
+	MarketStats(ctx context.Context, q MarketStatsQuery) ([]model.MarketStats, error)
+
+	// TotalVolume returns the sum of the listing prices of all market items
+	// of a chain.
+	TotalVolume(ctx context.Context, chainID uint64) (*big.Int, error)
+}
@@ -0,0 +1,222 @@
+// Package wallet reports the marketplace activity and realized profit and
+// loss of an account from its indexed listings, sales and transfers.
+package wallet
+
+import (
+	"context"
+	"math/big"
+	"sort"
+
+	"blockchain.com/indexer/model"
+	"blockchain.com/indexer/store"
+)
+
+// Summary totals the activity of an account over every token.
+type Summary struct {
+	Account string `json:"account"`
+	Minted  int    `json:"minted"`
+	Bought  int    `json:"bought"`
+	Sold    int    `json:"sold"`
+	Listed  int    `json:"listed"`
+
+	Spent       model.Amount `json:"spent"`
+	Received    model.Amount `json:"received"`
+	ListingFees model.Amount `json:"listing_fees"`
+	RealizedPnL model.Amount `json:"realized_pnl"`
+}
+
+// Token is the activity of an account on one token. The realized profit of
+// a sale is its price less the cost basis, the price the account paid for
+// the token or zero if it minted or was given it, and the listing fee of
+// the sold item.
+type Token struct {
+	Contract string       `json:"contract"`
+	TokenID  model.BigInt `json:"token_id"`
+	Minted   bool         `json:"minted"`
+	Bought   int          `json:"bought"`
+	Sold     int          `json:"sold"`
+	Listed   int          `json:"listed"`
+
+	Spent       model.Amount `json:"spent"`
+	Received    model.Amount `json:"received"`
+	ListingFees model.Amount `json:"listing_fees"`
+	RealizedPnL model.Amount `json:"realized_pnl"`
+
+	// Held reports whether the account holds the token, listed tokens
+	// included, and CostBasis what it paid for it.
+	Held      bool          `json:"held"`
+	CostBasis *model.Amount `json:"cost_basis,omitempty"`
+}
+
+// Report is the activity of an account with its per-token breakdown, tokens
+// ordered by first activity.
+type Report struct {
+	Summary
+	Tokens []Token `json:"tokens"`
+}
+
+// Build reports the activity of account from the records of s.
+func Build(ctx context.Context, s store.Store, chainID uint64, account string) (*Report, error) {
+	b, err := s.AccountRecords(ctx, chainID, account)
+	if err != nil {
+		return nil, err
+	}
+	return Compute(account, b), nil
+}
+
+type tokenKey struct {
+	contract string
+	tokenID  string
+}
+
+type itemKey struct {
+	market string
+	itemID string
+}
+
+// event is a record of the account on a token, at its position in the chain.
+type event struct {
+	block uint64
+	index uint
+	token tokenKey
+	apply func(t *token)
+}
+
+// token accumulates the activity on a token.
+type token struct {
+	Token
+	spent, received, fees, pnl *big.Int
+
+	// basis is the cost of the held token.
+	basis *big.Int
+}
+
+// Compute reports the activity of account from its records, as returned by
+// Store.AccountRecords. The listing fee of an item is the value of the
+// transaction that listed it, which the market requires to equal its
+// listing price.
+func Compute(account string, b *store.Batch) *Report {
+	txValue := make(map[string]*big.Int, len(b.Transactions))
+	for _, tx := range b.Transactions {
+		txValue[tx.TxHash] = tx.Value.Big()
+	}
+	itemFees := make(map[itemKey]*big.Int)
+	listings := make(map[string]string) // listing tx hash to market
+	sold := make(map[string]map[uint]bool)
+
+	var events []event
+	for _, it := range b.MarketItems {
+		it := it
+		fee := new(big.Int)
+		if v, ok := txValue[it.TxHash]; ok {
+			fee.Set(v)
+		}
+		itemFees[itemKey{it.Market, it.ItemID.String()}] = fee
+		listings[it.TxHash] = it.Market
+		events = append(events, event{it.BlockNumber, it.LogIndex, tokenKey{it.NftContract, it.TokenID.String()}, func(t *token) {
+			t.Listed++
+			t.fees.Add(t.fees, fee)
+		}})
+	}
+	for _, sale := range b.Sales {
+		sale := sale
+		if sold[sale.TxHash] == nil {
+			sold[sale.TxHash] = make(map[uint]bool)
+		}
+		sold[sale.TxHash][sale.LogIndex] = true
+		price := sale.Price.Big()
+		fee := itemFees[itemKey{sale.Market, sale.ItemID.String()}]
+		events = append(events, event{sale.BlockNumber, sale.LogIndex, tokenKey{sale.NftContract, sale.TokenID.String()}, func(t *token) {
+			if sale.Seller == account {
+				t.Sold++
+				t.received.Add(t.received, price)
+				t.pnl.Add(t.pnl, price)
+				t.pnl.Sub(t.pnl, t.basis)
+				if fee != nil {
+					t.pnl.Sub(t.pnl, fee)
+				}
+				t.Held, t.basis = false, new(big.Int)
+			}
+			if sale.Buyer == account {
+				t.Bought++
+				t.spent.Add(t.spent, price)
+				t.Held, t.basis = true, new(big.Int).Set(price)
+			}
+		}})
+	}
+	for _, tr := range b.Transfers {
+		tr := tr
+		switch {
+		case sold[tr.TxHash][tr.LogIndex]:
+			// Reported by the sale.
+			continue
+		case tr.FromAddress == account && listings[tr.TxHash] == tr.ToAddress:
+			// Held by the market while listed.
+			continue
+		}
+		events = append(events, event{tr.BlockNumber, tr.LogIndex, tokenKey{tr.Contract, tr.TokenID.String()}, func(t *token) {
+			if tr.FromAddress == account {
+				t.Held, t.basis = false, new(big.Int)
+			}
+			if tr.ToAddress == account {
+				t.Held, t.basis = true, new(big.Int)
+				if tr.FromAddress == store.UnsoldOwner {
+					t.Minted = true
+				}
+			}
+		}})
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		if events[i].block != events[j].block {
+			return events[i].block < events[j].block
+		}
+		return events[i].index < events[j].index
+	})
+
+	tokens := make(map[tokenKey]*token)
+	var order []*token
+	for _, e := range events {
+		t := tokens[e.token]
+		if t == nil {
+			tokenID, _ := model.ParseBigInt(e.token.tokenID)
+			t = &token{
+				Token: Token{Contract: e.token.contract, TokenID: tokenID},
+				spent: new(big.Int), received: new(big.Int), fees: new(big.Int), pnl: new(big.Int),
+				basis: new(big.Int),
+			}
+			tokens[e.token] = t
+			order = append(order, t)
+		}
+		e.apply(t)
+	}
+
+	r := &Report{Summary: Summary{Account: account}, Tokens: make([]Token, 0, len(order))}
+	spent, received, fees, pnl := new(big.Int), new(big.Int), new(big.Int), new(big.Int)
+	for _, t := range order {
+		t.Spent = model.NewAmount(t.spent)
+		t.Received = model.NewAmount(t.received)
+		t.ListingFees = model.NewAmount(t.fees)
+		t.RealizedPnL = model.NewAmount(t.pnl)
+		if t.Held {
+			basis := model.NewAmount(t.basis)
+			t.CostBasis = &basis
+		}
+		r.Tokens = append(r.Tokens, t.Token)
+
+		if t.Minted {
+			r.Minted++
+		}
+		r.Bought += t.Bought
+		r.Sold += t.Sold
+		r.Listed += t.Listed
+		spent.Add(spent, t.spent)
+		received.Add(received, t.received)
+		fees.Add(fees, t.fees)
+		pnl.Add(pnl, t.pnl)
+	}
+	r.Spent = model.NewAmount(spent)
+	r.Received = model.NewAmount(received)
+	r.ListingFees = model.NewAmount(fees)
+	r.RealizedPnL = model.NewAmount(pnl)
+	return r
+}
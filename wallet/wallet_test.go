@@ -0,0 +1,285 @@
+package wallet
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"blockchain.com/indexer/model"
+	"blockchain.com/indexer/store"
+	"blockchain.com/indexer/store/memory"
+)
+
+const testChainID = 1337
+
+var (
+	testMarket = common.HexToAddress("0x00000000000000000000000000000000000000aa").Hex()
+	testNFT    = common.HexToAddress("0x00000000000000000000000000000000000000bb").Hex()
+
+	alice = common.HexToAddress("0x00000000000000000000000000000000000000a1").Hex()
+	bob   = common.HexToAddress("0x00000000000000000000000000000000000000b0").Hex()
+	carol = common.HexToAddress("0x00000000000000000000000000000000000000c0").Hex()
+)
+
+// activity builds the records of market activity, one transaction a block.
+type activity struct {
+	b      store.Batch
+	block  uint64
+	tokens map[int64]int64 // item id to token id
+}
+
+func newActivity() *activity {
+	return &activity{tokens: make(map[int64]int64)}
+}
+
+// tx records a transaction from from carrying value and returns its hash.
+func (a *activity) tx(from, to string, value int64) string {
+	a.block++
+	hash := common.BigToHash(new(big.Int).SetUint64(a.block)).Hex()
+	a.b.Transactions = append(a.b.Transactions, model.Transaction{
+		ChainID:     testChainID,
+		TxHash:      hash,
+		BlockNumber: a.block,
+		FromAddress: from,
+		ToAddress:   to,
+		Value:       model.NewBigInt(big.NewInt(value)),
+		Status:      model.TransactionStatusSuccess,
+	})
+	return hash
+}
+
+func (a *activity) transfer(hash string, index uint, from, to string, token int64) {
+	a.b.Transfers = append(a.b.Transfers, model.NFTTransfer{
+		ChainID:     testChainID,
+		Contract:    testNFT,
+		TokenID:     model.NewBigInt(big.NewInt(token)),
+		FromAddress: from,
+		ToAddress:   to,
+		BlockNumber: a.block,
+		TxHash:      hash,
+		LogIndex:    index,
+	})
+}
+
+func (a *activity) mint(to string, token int64) {
+	a.transfer(a.tx(to, testNFT, 0), 0, store.UnsoldOwner, to, token)
+}
+
+func (a *activity) give(from, to string, token int64) {
+	a.transfer(a.tx(from, testNFT, 0), 0, from, to, token)
+}
+
+// list lists token as item at price, paying fee.
+func (a *activity) list(seller string, item, token, price, fee int64) {
+	hash := a.tx(seller, testMarket, fee)
+	a.transfer(hash, 0, seller, testMarket, token)
+	a.tokens[item] = token
+	a.b.MarketItems = append(a.b.MarketItems, model.MarketItem{
+		ChainID:     testChainID,
+		Market:      testMarket,
+		ItemID:      model.NewBigInt(big.NewInt(item)),
+		NftContract: testNFT,
+		TokenID:     model.NewBigInt(big.NewInt(token)),
+		Seller:      seller,
+		Owner:       store.UnsoldOwner,
+		Price:       model.NewBigInt(big.NewInt(price)),
+		BlockNumber: a.block,
+		TxHash:      hash,
+		LogIndex:    1,
+	})
+}
+
+// buy buys item for price.
+func (a *activity) buy(buyer string, item, price int64) {
+	hash := a.tx(buyer, testMarket, price)
+	a.transfer(hash, 0, testMarket, buyer, a.tokens[item])
+	a.b.Sales = append(a.b.Sales, model.MarketSale{
+		ChainID:     testChainID,
+		Market:      testMarket,
+		ItemID:      model.NewBigInt(big.NewInt(item)),
+		NftContract: testNFT,
+		TokenID:     model.NewBigInt(big.NewInt(a.tokens[item])),
+		Buyer:       buyer,
+		Price:       model.NewBigInt(big.NewInt(price)),
+		BlockNumber: a.block,
+		TxHash:      hash,
+	})
+}
+
+// tokenWant is the expected report of a token, amounts in wei and basis -1
+// when the token is not held.
+type tokenWant struct {
+	token                      int64
+	minted                     bool
+	bought, sold, listed       int
+	spent, received, fees, pnl int64
+	basis                      int64
+}
+
+func TestBuild(t *testing.T) {
+	tests := []struct {
+		name    string
+		records func(a *activity)
+		want    []tokenWant
+	}{
+		{
+			name: "minted and sold",
+			records: func(a *activity) {
+				a.mint(alice, 1)
+				a.list(alice, 1, 1, 100, 10)
+				a.buy(bob, 1, 100)
+			},
+			want: []tokenWant{{token: 1, minted: true, sold: 1, listed: 1, received: 100, fees: 10, pnl: 90, basis: -1}},
+		},
+		{
+			name: "bought and resold",
+			records: func(a *activity) {
+				a.mint(bob, 1)
+				a.list(bob, 1, 1, 150, 10)
+				a.buy(alice, 1, 150)
+				a.list(alice, 2, 1, 200, 10)
+				a.buy(carol, 2, 200)
+			},
+			want: []tokenWant{{token: 1, bought: 1, sold: 1, listed: 1, spent: 150, received: 200, fees: 10, pnl: 40, basis: -1}},
+		},
+		{
+			name: "resold at a loss",
+			records: func(a *activity) {
+				a.mint(bob, 1)
+				a.list(bob, 1, 1, 300, 10)
+				a.buy(alice, 1, 300)
+				a.list(alice, 2, 1, 100, 10)
+				a.buy(carol, 2, 100)
+			},
+			want: []tokenWant{{token: 1, bought: 1, sold: 1, listed: 1, spent: 300, received: 100, fees: 10, pnl: -210, basis: -1}},
+		},
+		{
+			name: "bought and held",
+			records: func(a *activity) {
+				a.mint(bob, 1)
+				a.list(bob, 1, 1, 150, 10)
+				a.buy(alice, 1, 150)
+			},
+			want: []tokenWant{{token: 1, bought: 1, spent: 150, basis: 150}},
+		},
+		{
+			name: "listed and unsold",
+			records: func(a *activity) {
+				a.mint(alice, 1)
+				a.list(alice, 1, 1, 100, 10)
+			},
+			want: []tokenWant{{token: 1, minted: true, listed: 1, fees: 10, basis: 0}},
+		},
+		{
+			name: "given and sold",
+			records: func(a *activity) {
+				a.mint(bob, 1)
+				a.give(bob, alice, 1)
+				a.list(alice, 1, 1, 100, 10)
+				a.buy(carol, 1, 100)
+			},
+			want: []tokenWant{{token: 1, sold: 1, listed: 1, received: 100, fees: 10, pnl: 90, basis: -1}},
+		},
+		{
+			name: "bought and given away",
+			records: func(a *activity) {
+				a.mint(bob, 1)
+				a.list(bob, 1, 1, 150, 10)
+				a.buy(alice, 1, 150)
+				a.give(alice, carol, 1)
+			},
+			want: []tokenWant{{token: 1, bought: 1, spent: 150, basis: -1}},
+		},
+		{
+			name: "bought back after a sale",
+			records: func(a *activity) {
+				a.mint(alice, 1)
+				a.list(alice, 1, 1, 100, 10)
+				a.buy(bob, 1, 100)
+				a.list(bob, 2, 1, 120, 10)
+				a.buy(alice, 2, 120)
+			},
+			want: []tokenWant{{token: 1, minted: true, bought: 1, sold: 1, listed: 1, spent: 120, received: 100, fees: 10, pnl: 90, basis: 120}},
+		},
+		{
+			name: "several tokens",
+			records: func(a *activity) {
+				a.mint(alice, 2)
+				a.mint(bob, 1)
+				a.list(bob, 1, 1, 50, 10)
+				a.buy(alice, 1, 50)
+				a.list(alice, 2, 2, 80, 10)
+				a.buy(carol, 2, 80)
+			},
+			want: []tokenWant{
+				{token: 2, minted: true, sold: 1, listed: 1, received: 80, fees: 10, pnl: 70, basis: -1},
+				{token: 1, bought: 1, spent: 50, basis: 50},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			a := newActivity()
+			tt.records(a)
+			s := memory.New()
+			if err := s.Commit(ctx, &a.b); err != nil {
+				t.Fatal(err)
+			}
+			r, err := Build(ctx, s, testChainID, alice)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(r.Tokens) != len(tt.want) {
+				t.Fatalf("%d tokens, want %d", len(r.Tokens), len(tt.want))
+			}
+			var sum tokenWant
+			minted := 0
+			for i, want := range tt.want {
+				if got := tokenReport(&r.Tokens[i]); got != want {
+					t.Errorf("token %d:\n got %+v\nwant %+v", i, got, want)
+				}
+				if want.minted {
+					minted++
+				}
+				sum.bought += want.bought
+				sum.sold += want.sold
+				sum.listed += want.listed
+				sum.spent += want.spent
+				sum.received += want.received
+				sum.fees += want.fees
+				sum.pnl += want.pnl
+			}
+			got := fmt.Sprint(r.Minted, r.Bought, r.Sold, r.Listed,
+				r.Spent.Big(), r.Received.Big(), r.ListingFees.Big(), r.RealizedPnL.Big())
+			want := fmt.Sprint(minted, sum.bought, sum.sold, sum.listed, sum.spent, sum.received, sum.fees, sum.pnl)
+			if got != want {
+				t.Errorf("summary %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+// tokenReport returns the report of a token in the form of tokenWant.
+func tokenReport(t *Token) tokenWant {
+	w := tokenWant{
+		token:    t.TokenID.Int64(),
+		minted:   t.Minted,
+		bought:   t.Bought,
+		sold:     t.Sold,
+		listed:   t.Listed,
+		spent:    t.Spent.Big().Int64(),
+		received: t.Received.Big().Int64(),
+		fees:     t.ListingFees.Big().Int64(),
+		pnl:      t.RealizedPnL.Big().Int64(),
+		basis:    -1,
+	}
+	if t.Held {
+		w.basis = t.CostBasis.Big().Int64()
+	}
+	return w
+}
@@ -0,0 +1,146 @@
+// Package watchlist manages the contracts the indexer follows. The list is
+// seeded from configuration, persisted in the store and can be changed while
+// the indexer runs.
+package watchlist
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"blockchain.com/indexer/abiregistry"
+	"blockchain.com/indexer/indexer"
+	"blockchain.com/indexer/model"
+	"blockchain.com/indexer/store"
+)
+
+var ErrInvalidContract = errors.New("watchlist: invalid contract")
+
+// Indexer is the part of indexer.Indexer driven by the watch list.
+type Indexer interface {
+	AddContract(c indexer.Contract)
+	RemoveContract(addr common.Address)
+}
+
+type Watchlist struct {
+	chainID  uint64
+	store    store.Store
+	registry *abiregistry.Registry
+	indexer  Indexer
+}
+
+func New(chainID uint64, s store.Store, registry *abiregistry.Registry, ix Indexer) *Watchlist {
+	return &Watchlist{
+		chainID:  chainID,
+		store:    s,
+		registry: registry,
+		indexer:  ix,
+	}
+}
+
+// LoadFile reads a JSON array of contracts.
+func LoadFile(path string) ([]model.Contract, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var contracts []model.Contract
+	if err := json.Unmarshal(data, &contracts); err != nil {
+		return nil, fmt.Errorf("%v: %w", path, err)
+	}
+	return contracts, nil
+}
+
+// Load adds the configured contracts that are not on the stored watch list
+// yet and starts indexing the whole list. Contracts changed at runtime keep
+// their stored settings.
+func (w *Watchlist) Load(ctx context.Context, seeds []model.Contract) error {
+	stored, err := w.store.Contracts(ctx, w.chainID)
+	if err != nil {
+		return err
+	}
+	known := make(map[string]bool, len(stored))
+	for _, c := range stored {
+		known[c.Address] = true
+	}
+
+	for _, c := range seeds {
+		c := c
+		if err := w.validate(&c); err != nil {
+			return err
+		}
+		if known[c.Address] {
+			continue
+		}
+		if err := w.store.SaveContract(ctx, &c); err != nil {
+			return err
+		}
+		stored = append(stored, c)
+	}
+
+	for _, c := range stored {
+		if err := w.registry.Bind(common.HexToAddress(c.Address), c.ABI); err != nil {
+			return err
+		}
+		w.indexer.AddContract(indexer.Contract{
+			Address:    common.HexToAddress(c.Address),
+			StartBlock: c.StartBlock,
+		})
+	}
+	return nil
+}
+
+// List returns the watch list.
+func (w *Watchlist) List(ctx context.Context) ([]model.Contract, error) {
+	return w.store.Contracts(ctx, w.chainID)
+}
+
+// Add puts a contract on the watch list, or updates it, and starts
+// indexing it from its start block.
+func (w *Watchlist) Add(ctx context.Context, c *model.Contract) error {
+	if err := w.validate(c); err != nil {
+		return err
+	}
+	if err := w.store.SaveContract(ctx, c); err != nil {
+		return err
+	}
+	if err := w.registry.Bind(common.HexToAddress(c.Address), c.ABI); err != nil {
+		return err
+	}
+	w.indexer.AddContract(indexer.Contract{
+		Address:    common.HexToAddress(c.Address),
+		StartBlock: c.StartBlock,
+	})
+	return nil
+}
+
+// Remove takes a contract off the watch list. Its indexed records are kept.
+func (w *Watchlist) Remove(ctx context.Context, address string) error {
+	if !common.IsHexAddress(address) {
+		return fmt.Errorf("%w: invalid address %q", ErrInvalidContract, address)
+	}
+	addr := common.HexToAddress(address)
+	if err := w.store.DeleteContract(ctx, w.chainID, addr.Hex()); err != nil {
+		return err
+	}
+	w.indexer.RemoveContract(addr)
+	w.registry.Unbind(addr)
+	return nil
+}
+
+// validate checks c and normalizes its address and chain.
+func (w *Watchlist) validate(c *model.Contract) error {
+	if !common.IsHexAddress(c.Address) {
+		return fmt.Errorf("%w: invalid address %q", ErrInvalidContract, c.Address)
+	}
+	if _, ok := w.registry.ABI(c.ABI); !ok {
+		return fmt.Errorf("%w: unknown ABI %q", ErrInvalidContract, c.ABI)
+	}
+	c.ChainID = w.chainID
+	c.Address = common.HexToAddress(c.Address).Hex()
+	return nil
+}